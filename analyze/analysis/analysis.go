@@ -0,0 +1,46 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analysis holds the steps of the media analysis. Each step records its output in the
+// metadata of the input file and is skipped when it's already completed, so an analysis that
+// failed resumes from the failed step.
+package analysis
+
+import (
+	common "github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+)
+
+// DefaultFFprobePath is the ffprobe binary used by the content length step.
+const DefaultFFprobePath = "bin/ffprobe"
+
+// Run executes the analysis steps for the input file of the configuration in order,
+// stopping at the first failed step.
+func Run(genaiRunConfig *common.GenaiRunConfig, ffprobePath string) error {
+	if err := get_content_length(&genaiRunConfig.BasicRunConfig, ffprobePath); err != nil {
+		return err
+	}
+	steps := []func(*common.GenaiRunConfig) error{
+		get_content_type,
+		get_content_summary,
+		get_segment_summaries,
+		persist_analysis_result,
+		generate_embeddings,
+	}
+	for _, step := range steps {
+		if err := step(genaiRunConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
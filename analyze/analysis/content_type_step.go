@@ -15,7 +15,7 @@
 // Author: rrmcguinness (Ryan McGuinness)
 //         kingman (Charlie Wang)
 
-package main
+package analysis
 
 import (
 	"bytes"
@@ -23,22 +23,23 @@ import (
 	"strings"
 
 	"github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
 )
 
 const (
-	CONTENT_TYPE_STEP_MODEL            = "creative-flash"
+	CONTENT_TYPE_STEP_MODEL            = cloud.DefaultAgentModel
 	CONTENT_TYPE_ANALYSIS_START_OFFSET = 0
 	CONTENT_TYPE_ANALYSIS_END_OFFSET   = 30
 )
 
-func get_content_type(genaiRunConfig *common.GenaiRunConfig) {
+func get_content_type(genaiRunConfig *common.GenaiRunConfig) error {
 	stepConfig, err := common.NewGenaiStepConfig(common.CONTENT_TYPE_STEP, genaiRunConfig, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	stepConfig.StepLogic = getContentTypLogicFunc(stepConfig)
-	stepConfig.RunStep()
+	return stepConfig.Run()
 }
 
 func getContentTypLogicFunc(config *common.GenaiStepConfig) func() (string, error) {
@@ -64,7 +65,7 @@ func getContentTypLogicFunc(config *common.GenaiStepConfig) func() (string, erro
 		out, err := config.GenerateContentWithClippingInterval(generateContentConfig)
 
 		if err != nil {
-			log.Fatal(err)
+			return "", err
 		}
 
 		out = strings.TrimSpace(out)
@@ -15,28 +15,28 @@
 // Author: rrmcguinness (Ryan McGuinness)
 //         kingman (Charlie Wang)
 
-package main
+package analysis
 
 import (
 	"errors"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/model"
 	"google.golang.org/api/iterator"
 	"google.golang.org/genai"
 )
 
-func generate_embeddings(genaiRunConfig *common.GenaiRunConfig) {
+func generate_embeddings(genaiRunConfig *common.GenaiRunConfig) error {
 	stepConfig, err := common.NewGenaiStepConfig(common.EMBEDDING_STEP, genaiRunConfig, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	stepConfig.StepLogic = generateEmbeddingsLogicFunc(stepConfig)
-	stepConfig.RunStep()
+	return stepConfig.Run()
 }
 
 func generateEmbeddingsLogicFunc(config *common.GenaiStepConfig) func() (string, error) {
@@ -49,6 +49,9 @@ func generateEmbeddingsLogicFunc(config *common.GenaiStepConfig) func() (string,
 		bqMediaTable := config.GenaiRunConfig.CloudConfig.BigQueryDataSource.MediaTable
 		bqEmbeddingTable := config.GenaiRunConfig.CloudConfig.BigQueryDataSource.EmbeddingTable
 		bqClient := config.GenaiRunConfig.BigQueryClient
+		if bqClient == nil {
+			return "", common.ErrNoBigQueryClient
+		}
 
 		// 1. Query BigQuery for the persisted Media object
 		fqMediaTableName := strings.Replace(bqClient.Dataset(bqDataset).Table(bqMediaTable).FullyQualifiedName(), ":", ".", -1)
@@ -75,7 +78,7 @@ func generateEmbeddingsLogicFunc(config *common.GenaiStepConfig) func() (string,
 		numberOfSegments := len(media.Segments)
 		toInsert := make([]*model.SegmentEmbedding, 0, numberOfSegments)
 		embeddingModel := config.GenaiRunConfig.GenAIEmbedding
-		modelName := config.GenaiRunConfig.CloudConfig.EmbeddingModels["multi-lingual"].Model
+		modelName := config.GenaiRunConfig.CloudConfig.EmbeddingModels[cloud.DefaultEmbeddingModel].Model
 		for _, segment := range media.Segments {
 			segmentEmbedding := model.NewSegmentEmbedding(media.Id, segment.SequenceNumber, modelName)
 			contents := []*genai.Content{
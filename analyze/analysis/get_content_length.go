@@ -15,11 +15,10 @@
 // Author: rrmcguinness (Ryan McGuinness)
 //         kingman (Charlie Wang)
 
-package main
+package analysis
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"strconv"
@@ -53,21 +52,19 @@ func (config *ContentLengthCommandConfig) contentLengthStepLogic(inputFileFullPa
 	cmd.Stderr = os.Stderr
 	output, err := cmd.Output()
 	if err != nil {
-		log.Fatalf("error running ffprobe: %v", err)
+		return "", fmt.Errorf("error running ffprobe: %w", err)
 	}
 	content_length, err := extractVideoLengthToFullSeconds(output)
 	if err != nil {
-		log.Fatalf("error extracting video length: %v", err)
+		return "", fmt.Errorf("error extracting video length: %w", err)
 	}
 	return strconv.Itoa(content_length), nil
 }
 
-func get_content_length(basicRunConfig *common.BasicRunConfig) {
-	commandPath := common.Getenv("COMMAND_PATH", "bin/ffprobe")
-
+func get_content_length(basicRunConfig *common.BasicRunConfig, commandPath string) error {
 	config := NewContentLengthCommandConfig(basicRunConfig, common.CONTENT_LENGTH_STEP, commandPath, ContentLengthCmdArgs)
 
-	config.RunStep()
+	return config.Run()
 }
 
 func extractVideoLengthToFullSeconds(output []byte) (int, error) {
@@ -15,7 +15,7 @@
 // Author: rrmcguinness (Ryan McGuinness)
 //         kingman (Charlie Wang)
 
-package main
+package analysis
 
 import (
 	"bytes"
@@ -26,11 +26,12 @@ import (
 	"strconv"
 
 	"github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/model"
 )
 
 const (
-	CONTENT_SUMMARY_STEP_MODEL = "creative-flash"
+	CONTENT_SUMMARY_STEP_MODEL = cloud.DefaultAgentModel
 	maxRetries                 = 5
 	CHUNK_LENGTH_SEC           = 300
 )
@@ -118,10 +119,10 @@ func (c ChunkConfig) convertChunkTimeStampToTimeStamp(chunkTimeStamp string) str
 
 }
 
-func get_content_summary(genaiRunConfig *common.GenaiRunConfig) {
+func get_content_summary(genaiRunConfig *common.GenaiRunConfig) error {
 	contentSummaryConfig, err := getContentSummaryConfig(genaiRunConfig)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	if contentSummaryConfig.ContentLength > CHUNK_LENGTH_SEC && contentSummaryConfig.ContentLength-CHUNK_LENGTH_SEC > 60 {
 		chunkConfigs := make([]*ChunkConfig, 0)
@@ -144,36 +145,38 @@ func get_content_summary(genaiRunConfig *common.GenaiRunConfig) {
 				ChunkIndex:           chunkIndex,
 			}
 			chunkConfigs = append(chunkConfigs, chunkConfig)
-			get_content_summary_dynamic(genaiRunConfig, chunkConfig)
+			if err = get_content_summary_dynamic(genaiRunConfig, chunkConfig); err != nil {
+				return err
+			}
 		}
-		consolidate_chunk_summaries(genaiRunConfig, chunkConfigs, contentSummaryConfig)
+		return consolidate_chunk_summaries(genaiRunConfig, chunkConfigs, contentSummaryConfig)
 
 	} else {
-		get_content_summary_dynamic(genaiRunConfig, contentSummaryConfig)
+		return get_content_summary_dynamic(genaiRunConfig, contentSummaryConfig)
 	}
 }
 
-func consolidate_chunk_summaries(genaiRunConfig *common.GenaiRunConfig, chunkConfigs []*ChunkConfig, summaryConfig ContentSummaryConfigAPI) {
+func consolidate_chunk_summaries(genaiRunConfig *common.GenaiRunConfig, chunkConfigs []*ChunkConfig, summaryConfig ContentSummaryConfigAPI) error {
 	stepConfig, err := common.NewGenaiStepConfig(summaryConfig.getStepKey(), genaiRunConfig, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	stepConfig.StepLogic = func() (string, error) {
 		return consolidateChunkSummaries(genaiRunConfig, chunkConfigs, summaryConfig)
 	}
 
-	stepConfig.RunStep()
+	return stepConfig.Run()
 }
 
-func get_content_summary_dynamic(genaiRunConfig *common.GenaiRunConfig, summaryConfig ContentSummaryConfigAPI) {
+func get_content_summary_dynamic(genaiRunConfig *common.GenaiRunConfig, summaryConfig ContentSummaryConfigAPI) error {
 	stepConfig, err := common.NewGenaiStepConfig(summaryConfig.getStepKey(), genaiRunConfig, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	stepConfig.StepLogic = getContentSummaryLogicFunc(stepConfig, summaryConfig)
-	stepConfig.RunStep()
+	return stepConfig.Run()
 }
 
 func getContentSummaryConfig(genaiRunConfig *common.GenaiRunConfig) (*ContentSummaryConfig, error) {
@@ -15,7 +15,7 @@
 // Author: rrmcguinness (Ryan McGuinness)
 //         kingman (Charlie Wang)
 
-package main
+package analysis
 
 import (
 	"encoding/json"
@@ -28,14 +28,14 @@ import (
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/model"
 )
 
-func get_segment_summaries(genaiRunConfig *common.GenaiRunConfig) {
+func get_segment_summaries(genaiRunConfig *common.GenaiRunConfig) error {
 	stepConfig, err := common.NewGenaiStepConfig(common.SEGMENT_SUMMARY_STEP_PREFIX+"all", genaiRunConfig, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	stepConfig.StepLogic = getSegmentSummariesLogicFunc(stepConfig)
-	stepConfig.RunStep()
+	return stepConfig.Run()
 }
 
 func getSegmentSummariesLogicFunc(config *common.GenaiStepConfig) func() (string, error) {
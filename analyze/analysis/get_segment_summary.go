@@ -15,7 +15,7 @@
 // Author: rrmcguinness (Ryan McGuinness)
 //         kingman (Charlie Wang)
 
-package main
+package analysis
 
 import (
 	"bytes"
@@ -25,11 +25,12 @@ import (
 	"strings"
 
 	"github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/model"
 )
 
 const (
-	SEGMENT_SUMMARY_STEP_MODEL = "creative-flash"
+	SEGMENT_SUMMARY_STEP_MODEL = cloud.DefaultAgentModel
 )
 
 func get_segment_summary(genaiRunConfig *common.GenaiRunConfig, mediaSummary *model.MediaSummary, contentType string, segmentSequenceNumber int) (string, error) {
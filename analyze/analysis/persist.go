@@ -15,7 +15,7 @@
 // Author: rrmcguinness (Ryan McGuinness)
 //         kingman (Charlie Wang)
 
-package main
+package analysis
 
 import (
 	"encoding/json"
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/model"
 )
 
@@ -36,18 +37,19 @@ const (
 
 type InputObjects struct {
 	ContentLength  int
+	ContentType    string
 	ContentSummary *model.MediaSummary
 	Segments       []*model.Segment
 }
 
-func persist_analysis_result(genaiRunConfig *common.GenaiRunConfig) {
+func persist_analysis_result(genaiRunConfig *common.GenaiRunConfig) error {
 	stepConfig, err := common.NewGenaiStepConfig(common.PERSIST_STEP, genaiRunConfig, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	stepConfig.StepLogic = persistResultLogicFunc(stepConfig)
-	stepConfig.RunStep()
+	return stepConfig.Run()
 }
 
 func persistResultLogicFunc(config *common.GenaiStepConfig) func() (string, error) {
@@ -68,11 +70,16 @@ func persistResultLogicFunc(config *common.GenaiStepConfig) func() (string, erro
 			return t.Before(tt)
 		})
 
-		return writeToBigQuery(config.GenaiRunConfig, createPersistObj(inputObjects))
+		media := createPersistObj(inputObjects)
+		addProvenance(config.GenaiRunConfig.CloudConfig, media, inputObjects.ContentType)
+		return writeToBigQuery(config.GenaiRunConfig, media)
 	}
 }
 
 func writeToBigQuery(config *common.GenaiRunConfig, persistObj *model.Media) (string, error) {
+	if config.BigQueryClient == nil {
+		return "", common.ErrNoBigQueryClient
+	}
 	bqInserter := config.BigQueryClient.
 		Dataset(config.CloudConfig.BigQueryDataSource.DatasetName).
 		Table(config.CloudConfig.BigQueryDataSource.MediaTable).Inserter()
@@ -98,14 +105,28 @@ func createPersistObj(inputObjects *InputObjects) *model.Media {
 	return media
 }
 
+// addProvenance records the configuration version and the prompts and models of the analysis
+// steps with the media, so media analyzed with an outdated configuration can be reprocessed.
+func addProvenance(cloudConfig *cloud.Config, media *model.Media, contentType string) {
+	media.ConfigVersion = cloudConfig.Version()
+	media.ConfigHash = cloudConfig.Hash()
+	media.AnalysisSteps = []*model.AnalysisStep{
+		cloudConfig.NewAnalysisStep(common.CONTENT_TYPE_STEP, cloud.PromptContentType, contentType, CONTENT_TYPE_STEP_MODEL),
+		cloudConfig.NewAnalysisStep(common.CONTENT_SUMMARY_STEP, cloud.PromptSummary, contentType, CONTENT_SUMMARY_STEP_MODEL),
+		cloudConfig.NewAnalysisStep(strings.TrimSuffix(common.SEGMENT_SUMMARY_STEP_PREFIX, "_"), cloud.PromptSegment, contentType, SEGMENT_SUMMARY_STEP_MODEL),
+	}
+}
+
 func getInputObjects(config *common.GenaiRunConfig) (*InputObjects, error) {
 	inputParameter := []string{
 		common.CONTENT_LENGTH_STEP,
 		common.CONTENT_SUMMARY_STEP,
+		common.CONTENT_TYPE_STEP,
 	}
 	inputValues := config.BasicRunConfig.GetStepsOutput(inputParameter)
 	inpubObjects := &InputObjects{}
 	inpubObjects.ContentLength, _ = strconv.Atoi(inputValues[common.CONTENT_LENGTH_STEP])
+	inpubObjects.ContentType = inputValues[common.CONTENT_TYPE_STEP]
 	contentSummary := &model.MediaSummary{}
 	json.Unmarshal([]byte(inputValues[common.CONTENT_SUMMARY_STEP]), contentSummary)
 	inpubObjects.ContentSummary = contentSummary
@@ -20,18 +20,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"strings"
 
-	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
 )
 
 type BasicRunConfig struct {
-	InputFile     string
-	InputBucket   string
-	MountPoint    string
-	Ctx           context.Context
-	storageClient *storage.Client
+	InputFile      string
+	InputBucket    string
+	MountPoint     string
+	StorageBackend string
+	Ctx            context.Context
+	objectStore    objectstore.ObjectStore
 }
 
 func NewBasicRunConfig() (*BasicRunConfig, error) {
@@ -41,12 +43,19 @@ func NewBasicRunConfig() (*BasicRunConfig, error) {
 	}
 
 	mountPoint := Getenv("MOUNT_POINT", "/mnt")
+	return NewBasicRunConfigForObject(context.Background(), inputBucket, inputFile, mountPoint, Getenv("STORAGE_BACKEND", objectstore.BackendGCS)), nil
+}
+
+// NewBasicRunConfigForObject creates the run configuration of an input object, e.g. of a
+// notification received by a long-running worker rather than the INPUT_FILE of a job.
+func NewBasicRunConfigForObject(ctx context.Context, inputBucket, inputFile, mountPoint, storageBackend string) *BasicRunConfig {
 	return &BasicRunConfig{
-		InputBucket: inputBucket,
-		InputFile:   inputFile,
-		MountPoint:  mountPoint,
-		Ctx:         context.Background(),
-	}, nil
+		InputBucket:    inputBucket,
+		InputFile:      inputFile,
+		MountPoint:     mountPoint,
+		StorageBackend: storageBackend,
+		Ctx:            ctx,
+	}
 }
 
 func parseInputFileEnv() (string, string, error) {
@@ -72,12 +81,12 @@ func Getenv(key, fallback string) string {
 }
 
 func (config *BasicRunConfig) getRunSourceObjectMetadata() (map[string]string, error) {
-	storageClient := config.GetStorageClient()
-
-	bucket := storageClient.Bucket(config.InputBucket)
-	obj := bucket.Object(config.InputFile)
+	store := config.GetObjectStore()
+	if store == nil {
+		return nil, fmt.Errorf("no object store available for backend %s", config.StorageBackend)
+	}
 
-	attrs, err := obj.Attrs(config.Ctx)
+	attrs, err := store.Attrs(config.Ctx, config.InputBucket, config.InputFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get object attributes: %v", err)
 	}
@@ -85,18 +94,27 @@ func (config *BasicRunConfig) getRunSourceObjectMetadata() (map[string]string, e
 	return attrs.Metadata, nil
 }
 
-func (config *BasicRunConfig) GetStorageClient() *storage.Client {
-	if config.storageClient == nil {
-		config.storageClient, _ = storage.NewClient(config.Ctx)
+// GetObjectStore returns the object store of the job, by default the store of the STORAGE_BACKEND
+// environment variable, the local backend uses the mount point as its root so the input file
+// is read from the same directory by the commands.
+func (config *BasicRunConfig) GetObjectStore() objectstore.ObjectStore {
+	if config.objectStore == nil {
+		store, err := objectstore.New(config.Ctx, config.StorageBackend, config.MountPoint)
+		if err != nil {
+			log.Printf("error creating object store: %v", err)
+			return nil
+		}
+		config.objectStore = store
 	}
-	return config.storageClient
+	return config.objectStore
 }
 
-func (config *BasicRunConfig) SetStorageClient(storageClient *storage.Client) {
-	if config.storageClient != nil {
-		config.storageClient.Close()
+// SetObjectStore replaces the object store of the job, closing the previous store unless it's the same store.
+func (config *BasicRunConfig) SetObjectStore(store objectstore.ObjectStore) {
+	if config.objectStore != nil && config.objectStore != store {
+		_ = config.objectStore.Close()
 	}
-	config.storageClient = storageClient
+	config.objectStore = store
 }
 
 func (config *BasicRunConfig) GetStepStatusByKey(stepKey string) *StepStatus {
@@ -20,8 +20,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-
-	"cloud.google.com/go/storage"
 )
 
 type BasicStepConfig struct {
@@ -66,30 +64,39 @@ func (config *BasicStepConfig) setStepStatusToCompleted(output string) (string,
 }
 
 func (config *BasicStepConfig) UpdateGCSObjectMetadata(metadata map[string]string) (string, error) {
-	storageClient := config.BasicRunConfig.GetStorageClient()
-	objectUpdate := storage.ObjectAttrsToUpdate{
-		Metadata: metadata,
+	store := config.BasicRunConfig.GetObjectStore()
+	if store == nil {
+		return "", fmt.Errorf("no object store available for backend %s", config.BasicRunConfig.StorageBackend)
 	}
-	obj := storageClient.Bucket(config.BasicRunConfig.InputBucket).Object(config.BasicRunConfig.InputFile)
-	if _, err := obj.Update(config.BasicRunConfig.Ctx, objectUpdate); err != nil {
+	if _, err := store.UpdateMetadata(config.BasicRunConfig.Ctx, config.BasicRunConfig.InputBucket, config.BasicRunConfig.InputFile, metadata); err != nil {
 		return "", fmt.Errorf("failed to update object metadata: %v", err)
 	}
 	return config.StepKey, nil
 }
 
-func (config *BasicStepConfig) RunStep() {
+// Run executes the step unless it's already completed for the input file, then records its
+// output in the metadata of the input file.
+func (config *BasicStepConfig) Run() error {
 	if config.StepCompleted() {
 		log.Printf("Step %s already completed for %s/%s, skipping step", config.StepKey, config.BasicRunConfig.InputBucket, config.BasicRunConfig.InputFile)
-		return
+		return nil
 	}
 
 	output, err := config.StepLogic()
 
 	if err != nil {
-		log.Fatalf("error executing step %s: %v", config.StepKey, err)
+		return fmt.Errorf("error executing step %s: %w", config.StepKey, err)
 	}
 
 	if _, err := config.setStepStatusToCompleted(output); err != nil {
-		log.Fatalf("error setting step %s status to completed: %v", config.StepKey, err)
+		return fmt.Errorf("error setting step %s status to completed: %w", config.StepKey, err)
+	}
+	return nil
+}
+
+// RunStep runs the step and exits the process when it fails, for the steps running as jobs.
+func (config *BasicStepConfig) RunStep() {
+	if err := config.Run(); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -17,6 +17,7 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -31,6 +32,10 @@ const (
 	GENAI_INPUT_FILE_TYPE = "video/mp4"
 )
 
+// ErrNoBigQueryClient is returned by the steps using BigQuery when there's no client, e.g. when
+// a cassette is replayed offline.
+var ErrNoBigQueryClient = errors.New("no BigQuery client, BigQuery isn't available when replaying a cassette")
+
 type GenaiRunConfig struct {
 	BasicRunConfig
 	CloudConfig        *cloud.Config
@@ -40,7 +45,7 @@ type GenaiRunConfig struct {
 	GenAIClient        *genai.Client
 	GenAIContentCaches map[string]*genai.CachedContent
 	BigQueryClient     *bigquery.Client
-	GenAIEmbedding     *genai.Models
+	GenAIEmbedding     cloud.EmbeddingModel
 }
 
 func NewGenaiRunConfig() (*GenaiRunConfig, error) {
@@ -56,7 +61,16 @@ func NewGenaiRunConfig() (*GenaiRunConfig, error) {
 	if err != nil {
 		return nil, err
 	}
-	templateService := cloud.NewTemplateService(cloudConfig)
+	return NewGenaiRunConfigWithClients(basicRunConfig, cloudConfig, cloudClients, nil), nil
+}
+
+// NewGenaiRunConfigWithClients creates the run configuration of an input object with existing
+// cloud clients and templates, e.g. the ones shared by the executions of a long-running worker.
+// A nil template service creates the templates of the config.
+func NewGenaiRunConfigWithClients(basicRunConfig *BasicRunConfig, cloudConfig *cloud.Config, cloudClients *cloud.ServiceClients, templateService *cloud.TemplateService) *GenaiRunConfig {
+	if templateService == nil {
+		templateService = cloud.NewTemplateService(cloudConfig)
+	}
 
 	meter := otel.Meter("github.com/GoogleCloudPlatform/media-search-solution")
 	config := &GenaiRunConfig{
@@ -67,11 +81,10 @@ func NewGenaiRunConfig() (*GenaiRunConfig, error) {
 		Meter:           meter,
 		GenAIClient:     cloudClients.GenAIClient,
 		BigQueryClient:  cloudClients.BiqQueryClient,
-		GenAIEmbedding:  cloudClients.EmbeddingModels["multi-lingual"],
+		GenAIEmbedding:  cloudClients.EmbeddingModels[cloud.DefaultEmbeddingModel],
 	}
-	config.SetStorageClient(cloudClients.StorageClient)
-	return config, nil
-
+	config.SetObjectStore(cloudClients.ObjectStore)
+	return config
 }
 
 func loadCloudConfig() (_ *cloud.Config, err error) {
@@ -90,6 +103,9 @@ func loadCloudConfig() (_ *cloud.Config, err error) {
 
 	config := cloud.NewConfig()
 	cloud.LoadConfig(&config)
+	if err = config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
 
 	return config, err
 }
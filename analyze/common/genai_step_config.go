@@ -103,7 +103,7 @@ func (config *GenaiStepConfig) loadGenaiContentCacheFromMetadata(cacheMetaDataKe
 
 func (config *GenaiStepConfig) createGenaiContentCache(modelName string, contents []*genai.Content, systemInstruction *genai.Content) (*genai.CachedContent, error) {
 	model := config.GenaiRunConfig.AgentModels[modelName]
-	return config.GenaiRunConfig.GenAIClient.Caches.Create(config.BasicRunConfig.Ctx, model.ModelName, &genai.CreateCachedContentConfig{
+	return model.CreateCachedContent(config.BasicRunConfig.Ctx, &genai.CreateCachedContentConfig{
 		Contents:          contents,
 		SystemInstruction: systemInstruction,
 	})
@@ -0,0 +1,151 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Author: rrmcguinness (Ryan McGuinness)
+//         kingman (Charlie Wang)
+
+// Package proxy generates the low resolution proxy of a media file with ffmpeg.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	common "github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+)
+
+const (
+	DefaultCommandPath        = "bin/ffmpeg"
+	DefaultOutputFormat       = ".mp4"
+	DefaultTargetWidth        = "740"
+	DefaultArgsStringTemplate = "-analyzeduration 0 -probesize 5000000 -y -hide_banner -i %s -filter:v scale=w=%s:h=trunc(ow/a/2)*2 -f mp4 %s"
+	TempFilePrefix            = "ffmpeg-output-"
+	ScratchDirPrefix          = "proxy-"
+	DiskBudgetCheckInterval   = time.Second
+)
+
+// ProxyCommandConfig is the step writing the proxy of the input file to the output folder of the mount point.
+type ProxyCommandConfig struct {
+	common.CommandStepConfig
+	OutputFolder string
+	TargetWidth  string
+	OutputFormat string
+	Scratch      cor.ScratchConfig
+}
+
+func NewProxyCommandConfig(basicRunConfig *common.BasicRunConfig, stepKey, commandPath, argsStringTemplate, outputFolder, targetWidth, outputFormat string) *ProxyCommandConfig {
+	commandStepConfig := common.NewCommandStepConfig(basicRunConfig, stepKey, commandPath, argsStringTemplate, nil)
+	config := &ProxyCommandConfig{
+		CommandStepConfig: *commandStepConfig,
+		OutputFolder:      outputFolder,
+		TargetWidth:       targetWidth,
+		OutputFormat:      outputFormat,
+	}
+	commandStepConfig.CommandLogic = config.proxyStepLogic
+	return config
+}
+
+func (config *ProxyCommandConfig) proxyStepLogic(inputFileFullPath string) (string, error) {
+	info, err := os.Stat(inputFileFullPath)
+	if err != nil {
+		return "", fmt.Errorf("error opening input file %s: %w", inputFileFullPath, err)
+	}
+
+	// The scratch directory is removed when the step returns, the proxy is smaller than the input
+	// so the input size is reserved to fail fast when the disk budget is too small.
+	scratch, err := cor.NewScratchDir(config.Scratch.Root, ScratchDirPrefix, config.Scratch.Budget)
+	if err != nil {
+		return "", fmt.Errorf("error creating scratch directory: %w", err)
+	}
+	defer func() {
+		if err := scratch.Remove(); err != nil {
+			log.Printf("error removing scratch directory %s: %v", scratch.Path(), err)
+		}
+	}()
+	if err = scratch.Reserve(info.Size()); err != nil {
+		return "", err
+	}
+	tempFile, err := scratch.CreateTemp(TempFilePrefix)
+	if err != nil {
+		return "", fmt.Errorf("error creating temp file: %w", err)
+	}
+	_ = tempFile.Close()
+
+	// ffmpeg is stopped if its output exceeds the disk budget
+	watchCtx, cancel := scratch.Watch(config.BasicRunConfig.Ctx, DiskBudgetCheckInterval)
+	defer cancel()
+
+	args := fmt.Sprintf(config.ArgsStringTemplate, inputFileFullPath, config.TargetWidth, tempFile.Name())
+	cmd := exec.CommandContext(watchCtx, config.CommandPath, strings.Split(args, common.CommandSeparator)...)
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if cause := context.Cause(watchCtx); errors.Is(cause, cor.ErrDiskBudgetExceeded) {
+			return "", fmt.Errorf("error running ffmpeg command: %w", cause)
+		}
+		return "", fmt.Errorf("error running ffmpeg command: %w", err)
+	}
+
+	outputName := config.BasicRunConfig.InputFile
+	if ext := filepath.Ext(config.BasicRunConfig.InputFile); ext != config.OutputFormat {
+		outputName = strings.TrimSuffix(outputName, ext) + config.OutputFormat
+	}
+
+	outputFile := fmt.Sprintf("%s/%s/%s", config.BasicRunConfig.MountPoint, config.OutputFolder, outputName)
+
+	err = MoveFile(tempFile.Name(), outputFile)
+	if err != nil {
+		return "", fmt.Errorf("error moving file: %w", err)
+	}
+
+	return fmt.Sprintf("%s/%s", config.OutputFolder, outputName), nil
+}
+
+// MoveFile copies the source file to the destination and removes the source, the files may be on
+// different file systems, e.g. a scratch disk and a GCS Fuse mount point.
+func MoveFile(sourcePath, destPath string) error {
+	inputFile, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("could not open source file: %v", err)
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("could not open dest file: %v", err)
+	}
+	defer outputFile.Close()
+
+	_, err = io.Copy(outputFile, inputFile)
+	if err != nil {
+		return fmt.Errorf("could not copy to dest from source: %v", err)
+	}
+
+	inputFile.Close()
+
+	err = os.Remove(sourcePath)
+	if err != nil {
+		return fmt.Errorf("could not remove source file: %v", err)
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ package main
 import (
 	"log"
 
+	"github.com/GoogleCloudPlatform/media-search-solution/analyze/analysis"
 	"github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
 )
 
@@ -28,11 +29,7 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	get_content_length(&genaiRunConfig.BasicRunConfig)
-	get_content_type(genaiRunConfig)
-	get_content_summary(genaiRunConfig)
-	get_segment_summaries(genaiRunConfig)
-	persist_analysis_result(genaiRunConfig)
-	generate_embeddings(genaiRunConfig)
-
+	if err = analysis.Run(genaiRunConfig, common.Getenv("COMMAND_PATH", analysis.DefaultFFprobePath)); err != nil {
+		log.Fatal(err)
+	}
 }
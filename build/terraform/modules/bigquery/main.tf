@@ -184,6 +184,63 @@ resource "google_bigquery_table" "media_ds_media" {
                 "mode": "NULLABLE"
             }
         ]
+    },
+    {
+        "name": "config_version",
+        "type": "STRING",
+        "mode": "NULLABLE"
+    },
+    {
+        "name": "config_hash",
+        "type": "STRING",
+        "mode": "NULLABLE"
+    },
+    {
+        "name": "analysis_steps",
+        "type": "RECORD",
+        "mode": "REPEATED",
+        "fields": [
+            {
+                "name": "step",
+                "type": "STRING",
+                "mode": "NULLABLE"
+            },
+            {
+                "name": "prompt_hash",
+                "type": "STRING",
+                "mode": "NULLABLE"
+            },
+            {
+                "name": "agent_model",
+                "type": "STRING",
+                "mode": "NULLABLE"
+            },
+            {
+                "name": "model",
+                "type": "STRING",
+                "mode": "NULLABLE"
+            },
+            {
+                "name": "temperature",
+                "type": "FLOAT64",
+                "mode": "NULLABLE"
+            },
+            {
+                "name": "top_p",
+                "type": "FLOAT64",
+                "mode": "NULLABLE"
+            },
+            {
+                "name": "top_k",
+                "type": "FLOAT64",
+                "mode": "NULLABLE"
+            },
+            {
+                "name": "max_tokens",
+                "type": "INTEGER",
+                "mode": "NULLABLE"
+            }
+        ]
     }
 ]
 EOF
@@ -0,0 +1,350 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Cassette modes, an empty mode disables the cassette.
+const (
+	CassetteModeRecord = "record"
+	CassetteModeReplay = "replay"
+)
+
+// Kinds of recorded interactions.
+const (
+	InteractionGenerate = "generate"
+	InteractionEmbed    = "embed"
+	InteractionCache    = "cache"
+)
+
+// ErrNoRecording is returned in replay mode when a request has no matching recording.
+var ErrNoRecording = errors.New("no matching recording in cassette")
+
+// EmbeddingModel generates embeddings, it's implemented by *genai.Models.
+type EmbeddingModel interface {
+	EmbedContent(ctx context.Context, model string, contents []*genai.Content, config *genai.EmbedContentConfig) (*genai.EmbedContentResponse, error)
+}
+
+// RecordedFile is a file part of a recorded request.
+type RecordedFile struct {
+	URI         string  `json:"uri"`
+	MIMEType    string  `json:"mime_type"`
+	StartOffset float64 `json:"start_offset,omitempty"` // The start offset of the video in seconds.
+	EndOffset   float64 `json:"end_offset,omitempty"`   // The end offset of the video in seconds.
+}
+
+// RecordedRequest is the part of a request used to match recordings.
+type RecordedRequest struct {
+	Kind              string         `json:"kind"`
+	Model             string         `json:"model"`
+	PromptHash        string         `json:"prompt_hash"`
+	Prompt            string         `json:"prompt"`
+	SystemInstruction string         `json:"system_instruction,omitempty"`
+	CachedContent     string         `json:"cached_content,omitempty"`
+	Schema            *genai.Schema  `json:"schema,omitempty"`
+	Files             []RecordedFile `json:"files,omitempty"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest                `json:"request"`
+	Response *genai.GenerateContentResponse `json:"response,omitempty"`
+	Embed    *genai.EmbedContentResponse    `json:"embed,omitempty"`
+	Cache    *genai.CachedContent           `json:"cache,omitempty"`
+	Error    string                         `json:"error,omitempty"`
+	Recorded time.Time                      `json:"recorded"`
+}
+
+// Cassette is a file of recorded model interactions. In record mode each interaction is
+// appended and the file is rewritten, in replay mode interactions are served in the order
+// they were recorded for identical requests.
+type Cassette struct {
+	mu           sync.Mutex
+	path         string
+	mode         string
+	Interactions []*Interaction `json:"interactions"`
+	replayed     map[*Interaction]bool
+}
+
+// LoadCassette opens a cassette, in replay mode the file must exist.
+func LoadCassette(path string, mode string) (*Cassette, error) {
+	if mode != CassetteModeRecord && mode != CassetteModeReplay {
+		return nil, fmt.Errorf("unknown cassette mode: %s", mode)
+	}
+	cassette := &Cassette{path: path, mode: mode, replayed: make(map[*Interaction]bool)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if mode == CassetteModeRecord && errors.Is(err, os.ErrNotExist) {
+			return cassette, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	return cassette, nil
+}
+
+func (c *Cassette) GetMode() string {
+	return c.mode
+}
+
+// GenerativeModel wraps a model with the cassette, the wrapped model is only used in record mode.
+// Created caches are recorded, so replayed requests refer to the recorded cache names.
+func (c *Cassette) GenerativeModel(model GenerativeModel) GenerativeModel {
+	return &cassetteModel{cassette: c, model: model}
+}
+
+// EmbeddingModel wraps an embedding model with the cassette, the wrapped model is only used in record mode.
+func (c *Cassette) EmbeddingModel(model EmbeddingModel) EmbeddingModel {
+	return &cassetteEmbeddingModel{cassette: c, model: model}
+}
+
+func (c *Cassette) record(interaction *Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	interaction.Recorded = time.Now()
+	c.Interactions = append(c.Interactions, interaction)
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// replay returns the first unused recording matching the request, or the last used one
+// when all matching recordings have been replayed.
+func (c *Cassette) replay(request RecordedRequest) (*Interaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var match *Interaction
+	for _, interaction := range c.Interactions {
+		if interaction.Request.equal(request) {
+			match = interaction
+			if !c.replayed[interaction] {
+				break
+			}
+		}
+	}
+	if match != nil {
+		c.replayed[match] = true
+		return match, nil
+	}
+
+	var closest *Interaction
+	var closestDiff []string
+	for _, interaction := range c.Interactions {
+		diff := interaction.Request.diff(request)
+		if closest == nil || len(diff) < len(closestDiff) {
+			closest, closestDiff = interaction, diff
+		}
+	}
+	if closest == nil {
+		return nil, fmt.Errorf("%w: cassette %s is empty", ErrNoRecording, c.path)
+	}
+	return nil, fmt.Errorf("%w, diff of the closest recording (-recorded +requested):\n%s", ErrNoRecording, strings.Join(closestDiff, "\n"))
+}
+
+func (r RecordedRequest) equal(other RecordedRequest) bool {
+	return len(r.diff(other)) == 0
+}
+
+// diff returns a line per differing field of the requests.
+func (r RecordedRequest) diff(other RecordedRequest) []string {
+	var out []string
+	field := func(name string, recorded, requested interface{}) {
+		a, _ := json.Marshal(recorded)
+		b, _ := json.Marshal(requested)
+		if string(a) != string(b) {
+			out = append(out, fmt.Sprintf("%s:\n-\t%s\n+\t%s", name, a, b))
+		}
+	}
+	field("kind", r.Kind, other.Kind)
+	field("model", r.Model, other.Model)
+	field("prompt_hash", r.PromptHash, other.PromptHash)
+	field("prompt", r.Prompt, other.Prompt)
+	field("system_instruction", r.SystemInstruction, other.SystemInstruction)
+	field("cached_content", r.CachedContent, other.CachedContent)
+	field("schema", r.Schema, other.Schema)
+	field("files", r.Files, other.Files)
+	return out
+}
+
+// newRecordedRequest extracts the matched values of a request, the prompt is the text of all parts.
+func newRecordedRequest(kind string, model string, contents []*genai.Content, config *genai.GenerateContentConfig) RecordedRequest {
+	request := RecordedRequest{Kind: kind, Model: model}
+	if config != nil {
+		request.SystemInstruction = instructionText(config.SystemInstruction)
+		request.CachedContent = config.CachedContent
+		request.Schema = config.ResponseSchema
+	}
+	request.setContents(contents)
+	return request
+}
+
+// newCacheRequest extracts the matched values of a cache creation.
+func newCacheRequest(model string, config *genai.CreateCachedContentConfig) RecordedRequest {
+	request := RecordedRequest{Kind: InteractionCache, Model: model}
+	var contents []*genai.Content
+	if config != nil {
+		request.SystemInstruction = instructionText(config.SystemInstruction)
+		contents = config.Contents
+	}
+	request.setContents(contents)
+	return request
+}
+
+func instructionText(instruction *genai.Content) string {
+	if instruction == nil {
+		return ""
+	}
+	var instructions []string
+	for _, part := range instruction.Parts {
+		instructions = append(instructions, part.Text)
+	}
+	return strings.Join(instructions, "\n")
+}
+
+// setContents sets the prompt, files and prompt hash of the request from the contents.
+func (r *RecordedRequest) setContents(contents []*genai.Content) {
+	var prompt []string
+	for _, content := range contents {
+		for _, part := range content.Parts {
+			if part.Text != "" {
+				prompt = append(prompt, part.Text)
+			}
+			if part.FileData != nil {
+				file := RecordedFile{URI: part.FileData.FileURI, MIMEType: part.FileData.MIMEType}
+				if part.VideoMetadata != nil {
+					file.StartOffset = part.VideoMetadata.StartOffset.Seconds()
+					file.EndOffset = part.VideoMetadata.EndOffset.Seconds()
+				}
+				r.Files = append(r.Files, file)
+			}
+		}
+	}
+	r.Prompt = strings.Join(prompt, "\n")
+	hash := sha256.Sum256([]byte(r.SystemInstruction + "\n" + r.Prompt))
+	r.PromptHash = hex.EncodeToString(hash[:])
+}
+
+type cassetteModel struct {
+	cassette *Cassette
+	model    GenerativeModel
+}
+
+func (m *cassetteModel) GenerateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	request := newRecordedRequest(InteractionGenerate, model, contents, config)
+	if m.cassette.mode == CassetteModeReplay {
+		interaction, err := m.cassette.replay(request)
+		if err != nil {
+			return nil, err
+		}
+		if interaction.Error != "" {
+			return nil, errors.New(interaction.Error)
+		}
+		return interaction.Response, nil
+	}
+
+	resp, err := m.model.GenerateContent(ctx, model, contents, config)
+	interaction := &Interaction{Request: request, Response: resp}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+	if recordErr := m.cassette.record(interaction); recordErr != nil {
+		return nil, fmt.Errorf("failed to record interaction: %w", recordErr)
+	}
+	return resp, err
+}
+
+// CreateCachedContent records the created cache, a replayed cache expires after the recorded
+// time to live from the replay so that it's reused as it was when recording.
+func (m *cassetteModel) CreateCachedContent(ctx context.Context, model string, config *genai.CreateCachedContentConfig) (*genai.CachedContent, error) {
+	request := newCacheRequest(model, config)
+	if m.cassette.mode == CassetteModeReplay {
+		interaction, err := m.cassette.replay(request)
+		if err != nil {
+			return nil, err
+		}
+		if interaction.Error != "" {
+			return nil, errors.New(interaction.Error)
+		}
+		cache := *interaction.Cache
+		if !cache.ExpireTime.IsZero() && !interaction.Recorded.IsZero() {
+			cache.ExpireTime = time.Now().Add(cache.ExpireTime.Sub(interaction.Recorded))
+		}
+		return &cache, nil
+	}
+
+	resp, err := m.model.CreateCachedContent(ctx, model, config)
+	interaction := &Interaction{Request: request, Cache: resp}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+	if recordErr := m.cassette.record(interaction); recordErr != nil {
+		return nil, fmt.Errorf("failed to record interaction: %w", recordErr)
+	}
+	return resp, err
+}
+
+type cassetteEmbeddingModel struct {
+	cassette *Cassette
+	model    EmbeddingModel
+}
+
+func (m *cassetteEmbeddingModel) EmbedContent(ctx context.Context, model string, contents []*genai.Content, config *genai.EmbedContentConfig) (*genai.EmbedContentResponse, error) {
+	request := newRecordedRequest(InteractionEmbed, model, contents, nil)
+	if m.cassette.mode == CassetteModeReplay {
+		interaction, err := m.cassette.replay(request)
+		if err != nil {
+			return nil, err
+		}
+		if interaction.Error != "" {
+			return nil, errors.New(interaction.Error)
+		}
+		return interaction.Embed, nil
+	}
+
+	resp, err := m.model.EmbedContent(ctx, model, contents, config)
+	interaction := &Interaction{Request: request, Embed: resp}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+	if recordErr := m.cassette.record(interaction); recordErr != nil {
+		return nil, fmt.Errorf("failed to record interaction: %w", recordErr)
+	}
+	return resp, err
+}
@@ -20,7 +20,10 @@ package cloud
 
 import (
 	"text/template"
+	"time"
 
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
 	"google.golang.org/genai"
 )
 
@@ -73,15 +76,31 @@ type VertexAiEmbeddingModel struct {
 
 // VertexAiLLMModel represents the configuration for a Vertex AI large language model (LLM).
 type VertexAiLLMModel struct {
-	Model              string  `toml:"model"`               // The name of the Vertex AI LLM.
-	SystemInstructions string  `toml:"system_instructions"` // The system instructions for the LLM.
-	Temperature        float32 `toml:"temperature"`         // The temperature parameter for the LLM.
-	TopP               float32 `toml:"top_p"`               // The top_p parameter for the LLM.
-	TopK               float32 `toml:"top_k"`               // The top_k parameter for the LLM.
-	MaxTokens          int32   `toml:"max_tokens"`          // The maximum number of tokens for the LLM output.
-	OutputFormat       string  `toml:"output_format"`       // The desired output format for the LLM.
-	EnableGoogle       bool    `toml:"enable_google"`       // Whether to enable Google Search for the LLM.
-	RateLimit          int     `toml:"rate_limit"`          // The rate limit for the LLM in requests per second.
+	Model              string      `toml:"model"`               // The name of the Vertex AI LLM.
+	SystemInstructions string      `toml:"system_instructions"` // The system instructions for the LLM.
+	Temperature        float32     `toml:"temperature"`         // The temperature parameter for the LLM.
+	TopP               float32     `toml:"top_p"`               // The top_p parameter for the LLM.
+	TopK               float32     `toml:"top_k"`               // The top_k parameter for the LLM.
+	MaxTokens          int32       `toml:"max_tokens"`          // The maximum number of tokens for the LLM output.
+	OutputFormat       string      `toml:"output_format"`       // The desired output format for the LLM.
+	EnableGoogle       bool        `toml:"enable_google"`       // Whether to enable Google Search for the LLM.
+	RateLimit          int         `toml:"rate_limit"`          // Deprecated: rejected by Validate, use requests_per_minute.
+	RequestsPerMinute  int         `toml:"requests_per_minute"` // The requests per minute budget of the LLM.
+	TokensPerMinute    int         `toml:"tokens_per_minute"`   // The tokens per minute budget of the LLM, 0 is unlimited.
+	Provider           string      `toml:"provider"`            // The model provider, vertex (default) or openai.
+	Endpoint           string      `toml:"endpoint"`            // The base URL of an OpenAI-compatible endpoint, e.g. http://localhost:8000/v1.
+	APIKey             string      `toml:"api_key"`             // The API key of an OpenAI-compatible endpoint.
+	Retry              RetryPolicy `toml:"retry"`               // The retry policy of the LLM.
+}
+
+// RetryPolicy represents the retry policy of an agent model.
+type RetryPolicy struct {
+	MaxAttempts              int      `toml:"max_attempts"`                // The maximum number of attempts, defaults to 4.
+	BackoffInMilliseconds    int      `toml:"backoff_in_milliseconds"`     // The delay before the first retry, defaults to 1000.
+	MaxBackoffInMilliseconds int      `toml:"max_backoff_in_milliseconds"` // The maximum delay between retries, defaults to 60000.
+	BackoffMultiplier        float64  `toml:"backoff_multiplier"`          // The growth of the delay between retries, defaults to 2.
+	RetryOn                  []string `toml:"retry_on"`                    // The retried error classes: quota, server, safety, empty, invalid_json and other.
+	FallbackModel            string   `toml:"fallback_model"`              // The agent model used when the attempts are exhausted.
 }
 
 // TopicSubscription represents the configuration for a Pub/Sub topic subscription.
@@ -89,6 +108,7 @@ type TopicSubscription struct {
 	Name             string `toml:"name"`               // The name of the Pub/Sub subscription.
 	DeadLetterTopic  string `toml:"dead_letter_topic"`  // The name of the dead-letter topic for the subscription.
 	TimeoutInSeconds int    `toml:"timeout_in_seconds"` // The timeout for the subscription in seconds.
+	Workflow         string `toml:"workflow"`           // The name of the declarative workflow handling the subscription.
 }
 
 // Storage represents the configuration for storage buckets.
@@ -96,6 +116,17 @@ type Storage struct {
 	HiResInputBucket   string `toml:"high_res_input_bucket"` // The name of the bucket for high-resolution input files.
 	LowResOutputBucket string `toml:"low_res_output_bucket"` // The name of the bucket for low-resolution output files.
 	GCSFuseMountPoint  string `toml:"gcs_fuse_mount_point"`  // The mount point for GCS FUSE.
+	Backend            string `toml:"backend"`               // The object storage backend, "gcs" (default) or "local".
+	LocalRoot          string `toml:"local_root"`            // The root directory of the local backend, buckets are subdirectories.
+}
+
+// GetMountPoint returns the directory the buckets are mounted in, the root of the local backend
+// or the GCS Fuse mount point.
+func (s Storage) GetMountPoint() string {
+	if s.Backend == objectstore.BackendLocal {
+		return s.LocalRoot
+	}
+	return s.GCSFuseMountPoint
 }
 
 type Category struct {
@@ -112,13 +143,102 @@ type ContentType struct {
 	DefaultType    string   `toml:"default_type"`    // The default content type to use if none is matched.
 }
 
+// WorkflowDefinition represents a declarative workflow, or a step of one. A step is
+// exactly one of: a registered command (Command), a reference to another workflow
+// (Workflow), or a nested chain of steps (Steps).
+type WorkflowDefinition struct {
+	Name              string               `toml:"name"`                // The name of the step, defaults to the command or workflow name.
+	Command           string               `toml:"command"`             // The registered name of the command to execute.
+	Workflow          string               `toml:"workflow"`            // The name of another workflow to execute as a nested chain.
+	Parallel          bool                 `toml:"parallel"`            // Whether the steps of this chain execute concurrently.
+	MaxConcurrency    int                  `toml:"max_concurrency"`     // The maximum concurrency of a parallel chain.
+	ContinueOnFailure bool                 `toml:"continue_on_failure"` // Whether the chain continues when a step fails.
+	InputParam        string               `toml:"input_param"`         // The context key of the step input, defaults to cor.CtxIn.
+	OutputParam       string               `toml:"output_param"`        // The context key of the step output, defaults to cor.CtxOut.
+	Checkpoint        bool                 `toml:"checkpoint"`          // Whether a sequential workflow checkpoints its progress for resume.
+	Steps             []WorkflowDefinition `toml:"steps"`               // The steps of a chain.
+}
+
+// Checkpoints represents the configuration of the store used to checkpoint workflow executions.
+type Checkpoints struct {
+	Store  string `toml:"store"`  // The checkpoint store, "file" or "gcs", empty disables checkpointing.
+	Path   string `toml:"path"`   // The local directory of the file store, or the object prefix of the gcs store.
+	Bucket string `toml:"bucket"` // The bucket of the gcs store.
+}
+
+// Idempotency represents the configuration of the store of the handled event keys, used to skip
+// duplicate deliveries of GCS notifications.
+type Idempotency struct {
+	Store      string `toml:"store"`        // The idempotency store, "memory", "file", "gcs" or "none", defaults to "memory".
+	Path       string `toml:"path"`         // The local directory of the file store, or the object prefix of the gcs store.
+	Bucket     string `toml:"bucket"`       // The bucket of the gcs store.
+	TTLInHours int    `toml:"ttl_in_hours"` // The time a key is kept, defaults to 24.
+}
+
+// GetTTL returns the time the key of a handled event is kept.
+func (i Idempotency) GetTTL() time.Duration {
+	if i.TTLInHours <= 0 {
+		return DefaultIdempotencyTTL
+	}
+	return time.Duration(i.TTLInHours) * time.Hour
+}
+
+// Quota represents the configuration of the quota shared by concurrent jobs.
+type Quota struct {
+	Coordinator string `toml:"coordinator"` // The quota coordinator, "local", "file" or "http", empty only applies the local limits.
+	Path        string `toml:"path"`        // The shared directory of the file coordinator.
+	Endpoint    string `toml:"endpoint"`    // The lease endpoint of the http coordinator.
+	Token       string `toml:"token"`       // The shared token of the lease endpoint, e.g. a ${secret:<name>} reference.
+}
+
+// CassetteConfig represents the configuration of recording and replaying model interactions.
+type CassetteConfig struct {
+	Mode string `toml:"mode"` // The cassette mode, "record" or "replay", empty disables the cassette.
+	Path string `toml:"path"` // The path of the cassette file.
+}
+
+// WorkQueueConfig represents the configuration of the queues of the messages received by the listeners.
+type WorkQueueConfig struct {
+	Path  string      `toml:"path"`  // The directory of the queues, a subdirectory per subscription, empty keeps the messages in memory.
+	Retry RetryPolicy `toml:"retry"` // The retry policy of failed executions, retry_on and fallback_model are not used.
+}
+
+// EventSourceConfig represents the configuration of the source of the messages of the topic subscriptions.
+type EventSourceConfig struct {
+	Type                       string `toml:"type"`                          // The event source, "pubsub", "memory" or "spool", defaults to "pubsub".
+	Path                       string `toml:"path"`                          // The directory of the spool source, a subdirectory per subscription.
+	PollIntervalInMilliseconds int    `toml:"poll_interval_in_milliseconds"` // The interval the spool directories are scanned at, defaults to 1000.
+	BufferSize                 int    `toml:"buffer_size"`                   // The number of messages buffered by a memory source, defaults to 100.
+}
+
+// MediaWorker represents the configuration of the in-process media pipeline, generating the proxies
+// of the HiResTopic events and analyzing the proxies of the LowResTopic events.
+type MediaWorker struct {
+	Enabled     bool   `toml:"enabled"`      // Handle the media subscriptions in the API server instead of Cloud Workflows.
+	FFmpegPath  string `toml:"ffmpeg_path"`  // The ffmpeg binary generating the proxies, defaults to "bin/ffmpeg".
+	FFprobePath string `toml:"ffprobe_path"` // The ffprobe binary reading the content length, defaults to "bin/ffprobe".
+	ProxyWidth  int    `toml:"proxy_width"`  // The width of the proxies in pixels, defaults to 740.
+	ProxyFormat string `toml:"proxy_format"` // The file extension of the proxies, defaults to ".mp4".
+}
+
+// Secrets represents the configuration of the provider resolving the ${secret:<name>} references of configuration values.
+type Secrets struct {
+	Provider string `toml:"provider"` // The secret provider, "file" or a registered provider, empty disables secret references.
+	Path     string `toml:"path"`     // The directory of the file provider, a file per secret.
+}
+
 // Config represents the overall configuration for the application.
 type Config struct {
 	Application struct {
-		Name            string `toml:"name"`              // The name of the application.
-		GoogleProjectId string `toml:"google_project_id"` // The Google Cloud project ID.
-		GoogleLocation  string `toml:"location"`          // The Google Cloud location.
-		ThreadPoolSize  int    `toml:"thread_pool_size"`  // The size of the thread pool.
+		Name                  string `toml:"name"`                     // The name of the application.
+		GoogleProjectId       string `toml:"google_project_id"`        // The Google Cloud project ID.
+		GoogleLocation        string `toml:"location"`                 // The Google Cloud location.
+		ThreadPoolSize        int    `toml:"thread_pool_size"`         // The size of the thread pool.
+		ScratchDir            string `toml:"scratch_dir"`              // The parent directory of execution scratch directories, defaults to the system temp dir.
+		ScratchBudgetMB       int64  `toml:"scratch_budget_mb"`        // The disk budget of an execution scratch directory in MB, 0 is unlimited.
+		WatchConfig           bool   `toml:"watch_config"`             // Reload the configuration when the local configuration files change.
+		ConfigVersion         string `toml:"config_version"`           // The version label recorded with analysis results, defaults to the content hash.
+		DrainTimeoutInSeconds int    `toml:"drain_timeout_in_seconds"` // The time shutdown waits for the running executions, defaults to 30.
 	} `toml:"application"`
 	Storage            Storage                           `toml:"storage"`               // Storage configuration.
 	BigQueryDataSource BigQueryDataSource                `toml:"big_query_data_source"` // BigQuery data source configuration.
@@ -128,18 +248,37 @@ type Config struct {
 	AgentModels        map[string]VertexAiLLMModel       `toml:"agent_models"`          // Vertex AI LLM models configuration.
 	Categories         map[string]Category               `toml:"categories"`            // A list of category definitions and LLM overrides.
 	ContentType        ContentType                       `toml:"content_type"`          // Content type configuration.
+	Workflows          map[string]WorkflowDefinition     `toml:"workflows"`             // Declarative workflow definitions.
+	Checkpoints        Checkpoints                       `toml:"checkpoints"`           // Workflow checkpoint store configuration.
+	Idempotency        Idempotency                       `toml:"idempotency"`           // Duplicate event detection configuration.
+	Cassette           CassetteConfig                    `toml:"cassette"`              // Model interaction recording configuration.
+	Quota              Quota                             `toml:"quota"`                 // Shared model quota configuration.
+	Secrets            Secrets                           `toml:"secrets"`               // Secret reference configuration.
+	WorkQueue          WorkQueueConfig                   `toml:"work_queue"`            // Received message queue configuration.
+	EventSource        EventSourceConfig                 `toml:"event_source"`          // Subscription event source configuration.
+	MediaWorker        MediaWorker                       `toml:"media_worker"`          // In-process media pipeline configuration.
+
+	secretReferences map[string]string // The unresolved values of the keys resolved from secrets, keyed by key path.
+}
+
+// GetScratchConfig returns the scratch directory configuration of command executions.
+func (c *Config) GetScratchConfig() cor.ScratchConfig {
+	return cor.ScratchConfig{
+		Root:   c.Application.ScratchDir,
+		Budget: c.Application.ScratchBudgetMB * 1024 * 1024,
+	}
 }
 
-func (c *Config) Replace(newConfig *Config) {
-	c.Application = newConfig.Application
-	c.Storage = newConfig.Storage
-	c.BigQueryDataSource = newConfig.BigQueryDataSource
-	c.PromptTemplates = newConfig.PromptTemplates
-	c.TopicSubscriptions = newConfig.TopicSubscriptions
-	c.EmbeddingModels = newConfig.EmbeddingModels
-	c.AgentModels = newConfig.AgentModels
-	c.Categories = newConfig.Categories
-	c.ContentType = newConfig.ContentType
+// DefaultDrainTimeout is the time shutdown waits for the running executions when
+// application.drain_timeout_in_seconds isn't set.
+const DefaultDrainTimeout = 30 * time.Second
+
+// GetDrainTimeout returns the time shutdown waits for the running executions of the listeners.
+func (c *Config) GetDrainTimeout() time.Duration {
+	if c.Application.DrainTimeoutInSeconds <= 0 {
+		return DefaultDrainTimeout
+	}
+	return time.Duration(c.Application.DrainTimeoutInSeconds) * time.Second
 }
 
 // NewConfig creates a new Config instance with initialized maps.
@@ -149,5 +288,6 @@ func NewConfig() *Config {
 		EmbeddingModels:    make(map[string]VertexAiEmbeddingModel),
 		AgentModels:        make(map[string]VertexAiLLMModel),
 		Categories:         make(map[string]Category),
+		Workflows:          make(map[string]WorkflowDefinition),
 	}
 }
@@ -0,0 +1,176 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Environment variable overrides of configuration keys. The key path follows the prefix with
+// its parts separated by a double underscore, e.g. MSS__STORAGE__HIGH_RES_INPUT_BUCKET overrides
+// storage.high_res_input_bucket and MSS__AGENT_MODELS__CREATIVE_FLASH__MODEL overrides
+// agent_models.creative-flash.model. Parts match keys case-insensitively with "_" matching "-".
+const (
+	EnvConfigOverridePrefix    = "MSS__"
+	EnvConfigOverrideSeparator = "__"
+)
+
+// ApplyEnvOverrides sets the configuration keys of the override variables of the environment,
+// given in the "key=value" form of os.Environ. Lists are comma separated. A map entry that
+// doesn't match an existing key is added with the lower case key. An ErrUndecodedKey error is
+// returned for each variable that doesn't match a configuration key, other keys are still set.
+func ApplyEnvOverrides(config interface{}, environ []string) error {
+	target := reflect.ValueOf(config)
+	for target.Kind() == reflect.Pointer && !target.IsNil() {
+		target = target.Elem()
+	}
+	if target.Kind() != reflect.Struct || !target.CanSet() {
+		return fmt.Errorf("configuration overrides require a pointer to a struct, got %T", config)
+	}
+
+	variables := append([]string(nil), environ...)
+	sort.Strings(variables)
+	var errs []error
+	for _, variable := range variables {
+		name, value, found := strings.Cut(variable, "=")
+		if !found || !strings.HasPrefix(name, EnvConfigOverridePrefix) {
+			continue
+		}
+		path := strings.Split(strings.TrimPrefix(name, EnvConfigOverridePrefix), EnvConfigOverrideSeparator)
+		if err := setPath(target, path, value); err != nil {
+			errs = append(errs, fmt.Errorf("environment variable %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setPath sets the value of the key path below the target, which must be settable.
+func setPath(target reflect.Value, path []string, value string) error {
+	if len(path) == 0 || path[0] == "" {
+		return fmt.Errorf("empty key: %w", ErrUndecodedKey)
+	}
+	switch target.Kind() {
+	case reflect.Pointer:
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		return setPath(target.Elem(), path, value)
+	case reflect.Struct:
+		for i := 0; i < target.NumField(); i++ {
+			field := target.Type().Field(i)
+			if field.IsExported() && normalizeKey(tomlName(field)) == normalizeKey(path[0]) {
+				return setKey(target.Field(i), path[1:], value)
+			}
+		}
+		return fmt.Errorf("%s: %w", strings.ToLower(path[0]), ErrUndecodedKey)
+	case reflect.Map:
+		if target.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", target.Type().Key())
+		}
+		if target.IsNil() {
+			target.Set(reflect.MakeMap(target.Type()))
+		}
+		key := reflect.ValueOf(strings.ToLower(path[0])).Convert(target.Type().Key())
+		for _, existing := range target.MapKeys() {
+			if normalizeKey(existing.String()) == normalizeKey(path[0]) {
+				key = existing
+				break
+			}
+		}
+		return updateMapEntry(target, key, func(entry reflect.Value) error {
+			return setKey(entry, path[1:], value)
+		})
+	default:
+		return fmt.Errorf("%s: %w", strings.ToLower(path[0]), ErrUndecodedKey)
+	}
+}
+
+// updateMapEntry updates the entry of a key of a map, a missing entry starts as the zero value.
+// Map entries aren't addressable, so the entry is copied, updated and stored when the update succeeds.
+func updateMapEntry(target reflect.Value, key reflect.Value, update func(entry reflect.Value) error) error {
+	entry := reflect.New(target.Type().Elem()).Elem()
+	if current := target.MapIndex(key); current.IsValid() {
+		entry.Set(current)
+	}
+	if err := update(entry); err != nil {
+		return err
+	}
+	target.SetMapIndex(key, entry)
+	return nil
+}
+
+// setKey sets the value when the path is complete, or continues with the rest of the path.
+func setKey(target reflect.Value, path []string, value string) error {
+	if len(path) > 0 {
+		return setPath(target, path, value)
+	}
+	return setValue(target, value)
+}
+
+func setValue(target reflect.Value, value string) error {
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		target.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetInt(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetFloat(parsed)
+	case reflect.Slice:
+		if target.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", target.Type())
+		}
+		items := reflect.MakeSlice(target.Type(), 0, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = reflect.Append(items, reflect.ValueOf(item).Convert(target.Type().Elem()))
+			}
+		}
+		target.Set(items)
+	default:
+		return fmt.Errorf("key is a table, not a value")
+	}
+	return nil
+}
+
+func tomlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "-", "_")
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/model"
+)
+
+// The prompts of the analysis, each analysis step uses one of them.
+const (
+	PromptContentType = "content_type"
+	PromptSummary     = "summary"
+	PromptSegment     = "segment"
+)
+
+// HashLength is the number of hex characters of the content hash used as the default version label.
+const HashLength = 12
+
+// Hash returns the SHA-256 content hash of the configuration. Maps are encoded in key
+// order, so configurations with the same content have the same hash. Keys resolved from
+// secrets are hashed with their secret references, so the hash doesn't depend on secrets.
+func (c *Config) Hash() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	if len(c.secretReferences) > 0 {
+		// The references are restored in a copy, readers may use the configuration
+		unresolved := &Config{}
+		if err = json.Unmarshal(data, unresolved); err != nil {
+			return ""
+		}
+		resolveStrings(reflect.ValueOf(unresolved), nil, func(path []string, value string) string {
+			if reference, ok := c.secretReferences[keyPath(path...)]; ok {
+				return reference
+			}
+			return value
+		})
+		if data, err = json.Marshal(unresolved); err != nil {
+			return ""
+		}
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Version returns the version label of the configuration, application.config_version
+// when set, otherwise the abbreviated content hash.
+func (c *Config) Version() string {
+	if c.Application.ConfigVersion != "" {
+		return c.Application.ConfigVersion
+	}
+	hash := c.Hash()
+	if len(hash) > HashLength {
+		hash = hash[:HashLength]
+	}
+	return hash
+}
+
+// PromptHash returns the SHA-256 hash of a prompt of a content type, including the system
+// instructions sent with it. The content type prompt uses the system instructions of the
+// agent model, the other prompts the ones of the prompt templates of the content type.
+func (c *Config) PromptHash(prompt string, contentType string, agentModel string) string {
+	var parts []string
+	switch prompt {
+	case PromptContentType:
+		parts = []string{c.AgentModels[agentModel].SystemInstructions, c.ContentType.PromptTemplate}
+	case PromptSummary:
+		parts = []string{c.PromptTemplates[contentType].SystemInstructions, c.PromptTemplates[contentType].SummaryPrompt}
+	case PromptSegment:
+		parts = []string{c.PromptTemplates[contentType].SystemInstructions, c.PromptTemplates[contentType].SegmentPrompt}
+	default:
+		return ""
+	}
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// NewAnalysisStep returns the provenance of an analysis step executed with a prompt of a
+// content type and an agent model of the configuration.
+func (c *Config) NewAnalysisStep(step string, prompt string, contentType string, agentModel string) *model.AnalysisStep {
+	llm := c.AgentModels[agentModel]
+	return &model.AnalysisStep{
+		Step:        step,
+		PromptHash:  c.PromptHash(prompt, contentType, agentModel),
+		AgentModel:  agentModel,
+		Model:       llm.Model,
+		Temperature: toFloat64(llm.Temperature),
+		TopP:        toFloat64(llm.TopP),
+		TopK:        toFloat64(llm.TopK),
+		MaxTokens:   int(llm.MaxTokens),
+	}
+}
+
+// toFloat64 converts a configured float32 to the float64 of its shortest decimal representation,
+// 0.8 is recorded as 0.8 rather than 0.800000011920929.
+func toFloat64(in float32) float64 {
+	out, _ := strconv.ParseFloat(strconv.FormatFloat(float64(in), 'g', -1, 32), 64)
+	return out
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ConfigSnapshot holds the current configuration. Readers get the configuration with Get
+// and must treat it as immutable, a reload swaps in a new configuration instead of
+// modifying the current one, so a reader always sees a consistent configuration.
+type ConfigSnapshot struct {
+	mu         sync.Mutex
+	current    atomic.Pointer[Config]
+	validators []func(config *Config) error
+	listeners  []func(config *Config)
+}
+
+// NewConfigSnapshot creates a snapshot holding the given configuration.
+func NewConfigSnapshot(config *Config) *ConfigSnapshot {
+	out := &ConfigSnapshot{}
+	out.current.Store(config)
+	return out
+}
+
+// Get returns the current configuration, it must not be modified.
+func (s *ConfigSnapshot) Get() *Config {
+	return s.current.Load()
+}
+
+// AddValidator registers a check a new configuration must pass, in addition to Config.Validate,
+// before it replaces the current configuration.
+func (s *ConfigSnapshot) AddValidator(validator func(config *Config) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validators = append(s.validators, validator)
+}
+
+// AddListener registers a function called with the new configuration after it has replaced
+// the current one. Listeners are called in the order they were added and must not call Swap.
+func (s *ConfigSnapshot) AddListener(listener func(config *Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Swap validates the configuration and makes it the current one. When validation fails
+// the current configuration is kept and the validation errors are returned.
+func (s *ConfigSnapshot) Swap(config *Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := []error{config.Validate()}
+	for _, validator := range s.validators {
+		errs = append(errs, validator(config))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	s.current.Store(config)
+	for _, listener := range s.listeners {
+		listener(config)
+	}
+	return nil
+}
+
+// Reload loads the configuration files of a directory and runtime environment and swaps
+// in the result. The current configuration is kept when the files can't be decoded, have
+// unknown keys or the configuration is invalid.
+func (s *ConfigSnapshot) Reload(dir string, runtimeEnvironment string) error {
+	config := NewConfig()
+	if err := LoadConfigFiles(dir, runtimeEnvironment, config); err != nil {
+		return err
+	}
+	return s.Swap(config)
+}
@@ -0,0 +1,300 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+)
+
+// Models referenced by name in the code, they must be present in the configuration.
+const (
+	DefaultAgentModel     = "creative-flash"
+	DefaultEmbeddingModel = "multi-lingual"
+)
+
+// ErrUndecodedKey is returned by strict decoding for keys that don't match a configuration field.
+var ErrUndecodedKey = errors.New("unknown configuration key")
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// keyPath joins the parts of a TOML key, quoting the parts that are not bare keys.
+func keyPath(parts ...string) string {
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		if bareKey.MatchString(part) {
+			quoted[i] = part
+		} else {
+			quoted[i] = fmt.Sprintf("%q", part)
+		}
+	}
+	return strings.Join(quoted, ".")
+}
+
+// DecodeConfigFile decodes a TOML file into the config, returning an ErrUndecodedKey
+// error for each key of the file that doesn't match a configuration field.
+func DecodeConfigFile(fileName string, config interface{}) error {
+	metadata, err := toml.DecodeFile(fileName, config)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, key := range metadata.Undecoded() {
+		errs = append(errs, fmt.Errorf("%s: %s: %w", fileName, keyPath(key...), ErrUndecodedKey))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the configuration, returning all problems found prefixed by their TOML key path.
+func (c *Config) Validate() error {
+	var errs []error
+	problem := func(key string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	required := func(key string, value string) {
+		if strings.TrimSpace(value) == "" {
+			problem(key, "value is required")
+		}
+	}
+
+	// Application
+	if c.Storage.Backend != objectstore.BackendLocal {
+		required("application.google_project_id", c.Application.GoogleProjectId)
+	}
+	if c.Application.ThreadPoolSize < 0 {
+		problem("application.thread_pool_size", "must not be negative")
+	}
+	if c.Application.ScratchBudgetMB < 0 {
+		problem("application.scratch_budget_mb", "must not be negative")
+	}
+	if c.Application.DrainTimeoutInSeconds < 0 {
+		problem("application.drain_timeout_in_seconds", "must not be negative")
+	}
+
+	// Storage
+	switch c.Storage.Backend {
+	case "", objectstore.BackendGCS:
+		required("storage.high_res_input_bucket", c.Storage.HiResInputBucket)
+		required("storage.low_res_output_bucket", c.Storage.LowResOutputBucket)
+	case objectstore.BackendLocal:
+		required("storage.local_root", c.Storage.LocalRoot)
+	default:
+		problem("storage.backend", "unknown backend %q", c.Storage.Backend)
+	}
+
+	// BigQuery
+	required("big_query_data_source.dataset", c.BigQueryDataSource.DatasetName)
+	required("big_query_data_source.media_table", c.BigQueryDataSource.MediaTable)
+	required("big_query_data_source.embedding_table", c.BigQueryDataSource.EmbeddingTable)
+
+	// Subscriptions
+	for _, name := range sortedKeys(c.TopicSubscriptions) {
+		subscription := c.TopicSubscriptions[name]
+		required(keyPath("topic_subscriptions", name, "name"), subscription.Name)
+		if subscription.Workflow != "" {
+			if _, ok := c.Workflows[subscription.Workflow]; !ok {
+				problem(keyPath("topic_subscriptions", name, "workflow"), "unknown workflow %q", subscription.Workflow)
+			}
+		}
+	}
+	switch c.EventSource.Type {
+	case "", EventSourcePubSub, EventSourceMemory:
+	case EventSourceSpool:
+		required("event_source.path", c.EventSource.Path)
+	default:
+		problem("event_source.type", "unknown event source %q", c.EventSource.Type)
+	}
+	if c.EventSource.PollIntervalInMilliseconds < 0 || c.EventSource.BufferSize < 0 {
+		problem("event_source", "poll interval and buffer size must not be negative")
+	}
+	if c.MediaWorker.Enabled {
+		for _, name := range []string{"HiResTopic", "LowResTopic"} {
+			if _, ok := c.TopicSubscriptions[name]; !ok {
+				problem(keyPath("topic_subscriptions", name), "subscription is required by the media worker")
+			}
+		}
+		if c.Storage.Backend != objectstore.BackendLocal {
+			required("storage.gcs_fuse_mount_point", c.Storage.GCSFuseMountPoint)
+		}
+	}
+	if c.MediaWorker.ProxyWidth < 0 {
+		problem("media_worker.proxy_width", "must not be negative")
+	}
+	if c.MediaWorker.ProxyFormat != "" && !strings.HasPrefix(c.MediaWorker.ProxyFormat, ".") {
+		problem("media_worker.proxy_format", "file extension %q must start with a dot", c.MediaWorker.ProxyFormat)
+	}
+	if c.WorkQueue.Retry.MaxAttempts < 0 || c.WorkQueue.Retry.BackoffInMilliseconds < 0 || c.WorkQueue.Retry.MaxBackoffInMilliseconds < 0 {
+		problem("work_queue.retry", "attempts and backoff must not be negative")
+	}
+
+	// Models
+	if _, ok := c.EmbeddingModels[DefaultEmbeddingModel]; !ok {
+		problem(keyPath("embedding_models", DefaultEmbeddingModel), "embedding model is required")
+	}
+	for _, name := range sortedKeys(c.EmbeddingModels) {
+		required(keyPath("embedding_models", name, "model"), c.EmbeddingModels[name].Model)
+	}
+	if _, ok := c.AgentModels[DefaultAgentModel]; !ok {
+		problem(keyPath("agent_models", DefaultAgentModel), "agent model is required")
+	}
+	for _, name := range sortedKeys(c.AgentModels) {
+		model := c.AgentModels[name]
+		required(keyPath("agent_models", name, "model"), model.Model)
+		switch model.Provider {
+		case "", ModelProviderVertex:
+		case ModelProviderOpenAI:
+			required(keyPath("agent_models", name, "endpoint"), model.Endpoint)
+		default:
+			problem(keyPath("agent_models", name, "provider"), "unknown provider %q", model.Provider)
+		}
+		if model.RequestsPerMinute < 0 || model.TokensPerMinute < 0 {
+			problem(keyPath("agent_models", name), "rate limits must not be negative")
+		}
+		if model.RateLimit != 0 {
+			problem(keyPath("agent_models", name, "rate_limit"), "is no longer supported, use requests_per_minute")
+		}
+		if err := model.Retry.Validate(c.AgentModels); err != nil {
+			for _, e := range unjoin(err) {
+				problem(keyPath("agent_models", name, "retry"), "%v", e)
+			}
+		}
+	}
+
+	// Prompt templates
+	for _, name := range sortedKeys(c.PromptTemplates) {
+		templates := c.PromptTemplates[name]
+		validateTemplate(keyPath("prompt_templates", name, "summary"), templates.SummaryPrompt, problem)
+		validateTemplate(keyPath("prompt_templates", name, "segment"), templates.SegmentPrompt, problem)
+	}
+
+	// Content types
+	if len(c.ContentType.Types) == 0 {
+		problem("content_type.types", "at least one content type is required")
+	}
+	for i, contentType := range c.ContentType.Types {
+		if _, ok := c.PromptTemplates[contentType]; !ok {
+			problem(fmt.Sprintf("content_type.types[%d]", i), "content type %q has no prompt template %s", contentType, keyPath("prompt_templates", contentType))
+		}
+	}
+	if c.ContentType.DefaultType == "" {
+		problem("content_type.default_type", "value is required")
+	} else if !slices.Contains(c.ContentType.Types, c.ContentType.DefaultType) {
+		problem("content_type.default_type", "default type %q is not one of the content types", c.ContentType.DefaultType)
+	}
+	validateTemplate("content_type.prompt_template", c.ContentType.PromptTemplate, problem)
+
+	// Checkpoints, idempotency, quota, cassette and secrets
+	switch c.Checkpoints.Store {
+	case "":
+		// A checkpointed workflow would silently run without checkpoints
+		var checkpointed func(path string, steps []WorkflowDefinition)
+		checkpointed = func(path string, steps []WorkflowDefinition) {
+			for i, step := range steps {
+				stepPath := fmt.Sprintf("%s.steps[%d]", path, i)
+				if step.Checkpoint {
+					problem(stepPath+".checkpoint", "checkpoints.store is required to checkpoint the workflow")
+				}
+				checkpointed(stepPath, step.Steps)
+			}
+		}
+		for _, name := range sortedKeys(c.Workflows) {
+			workflow := c.Workflows[name]
+			if workflow.Checkpoint {
+				problem(keyPath("workflows", name, "checkpoint"), "checkpoints.store is required to checkpoint the workflow")
+			}
+			checkpointed(keyPath("workflows", name), workflow.Steps)
+		}
+	case CheckpointStoreFile:
+		required("checkpoints.path", c.Checkpoints.Path)
+	case CheckpointStoreGCS:
+		required("checkpoints.bucket", c.Checkpoints.Bucket)
+	default:
+		problem("checkpoints.store", "unknown store %q", c.Checkpoints.Store)
+	}
+	switch c.Idempotency.Store {
+	case "", IdempotencyStoreMemory, IdempotencyStoreNone:
+	case IdempotencyStoreFile:
+		required("idempotency.path", c.Idempotency.Path)
+	case IdempotencyStoreGCS:
+		required("idempotency.bucket", c.Idempotency.Bucket)
+	default:
+		problem("idempotency.store", "unknown store %q", c.Idempotency.Store)
+	}
+	if c.Idempotency.TTLInHours < 0 {
+		problem("idempotency.ttl_in_hours", "must not be negative")
+	}
+	switch c.Quota.Coordinator {
+	case "", QuotaCoordinatorLocal:
+	case QuotaCoordinatorFile:
+		required("quota.path", c.Quota.Path)
+	case QuotaCoordinatorHTTP:
+		required("quota.endpoint", c.Quota.Endpoint)
+		required("quota.token", c.Quota.Token)
+	default:
+		problem("quota.coordinator", "unknown coordinator %q", c.Quota.Coordinator)
+	}
+	switch c.Cassette.Mode {
+	case "":
+	case CassetteModeRecord:
+		required("cassette.path", c.Cassette.Path)
+	case CassetteModeReplay:
+		required("cassette.path", c.Cassette.Path)
+		if c.EventSource.GetType() == EventSourcePubSub {
+			problem("cassette.mode", "a replay runs offline, the %s event source isn't available", EventSourcePubSub)
+		}
+	default:
+		problem("cassette.mode", "unknown mode %q", c.Cassette.Mode)
+	}
+	if c.Secrets.Provider == SecretProviderFile {
+		required("secrets.path", c.Secrets.Path)
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateTemplate(key string, text string, problem func(key string, format string, args ...interface{})) {
+	if strings.TrimSpace(text) == "" {
+		problem(key, "template is required")
+		return
+	}
+	if _, err := template.New(key).Parse(text); err != nil {
+		problem(key, "invalid template: %v", err)
+	}
+}
+
+// unjoin returns the errors of a joined error.
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ConfigWatchDelay is the time without further changes to the configuration files
+// before the configuration is reloaded, editors and file sync tools often write a
+// file in several operations.
+const ConfigWatchDelay = 500 * time.Millisecond
+
+// WatchConfigFiles reloads the snapshot when the base or the environment configuration file
+// of the directory changes, until the context is done. The directory is watched rather than
+// the files so files replaced by a rename are still detected. A rejected reload is logged
+// and the current configuration is kept.
+func WatchConfigFiles(ctx context.Context, snapshot *ConfigSnapshot, dir string, runtimeEnvironment string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	watchDir := dir
+	if watchDir == "" {
+		watchDir = "."
+	}
+	if err = watcher.Add(watchDir); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+
+	baseConfigFileName, envConfigFileName := ConfigFileNames(dir, runtimeEnvironment)
+	configFiles := map[string]bool{
+		filepath.Clean(baseConfigFileName): true,
+		filepath.Clean(envConfigFileName):  true,
+	}
+
+	go func() {
+		defer watcher.Close()
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if configFiles[filepath.Clean(event.Name)] && !event.Has(fsnotify.Chmod) {
+					reload = time.After(ConfigWatchDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("configuration watcher error: %v", err)
+			case <-reload:
+				reload = nil
+				if err := snapshot.Reload(dir, runtimeEnvironment); err != nil {
+					log.Printf("configuration reload rejected, keeping the current configuration: %v", err)
+					continue
+				}
+				log.Printf("configuration reloaded from %s", watchDir)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// Event source types supported by the event_source configuration.
+const (
+	EventSourcePubSub = "pubsub"
+	EventSourceMemory = "memory"
+	EventSourceSpool  = "spool"
+)
+
+// DefaultMemoryBufferSize is the number of messages buffered by a memory source when
+// event_source.buffer_size isn't set.
+const DefaultMemoryBufferSize = 100
+
+// GetType returns the event source type, pubsub when not set.
+func (c EventSourceConfig) GetType() string {
+	if c.Type == "" {
+		return EventSourcePubSub
+	}
+	return c.Type
+}
+
+// NewEventSource creates the event source of a topic subscription. The spool source of a
+// subscription is the subdirectory of the spool path named after the subscription. The memory
+// and spool sources handle up to concurrency messages at a time, e.g. the thread pool size.
+func NewEventSource(config EventSourceConfig, client *pubsub.Client, subscription TopicSubscription, concurrency int) (EventSource, error) {
+	switch config.GetType() {
+	case EventSourcePubSub:
+		if client == nil {
+			return nil, fmt.Errorf("a Pub/Sub client is required for the %s event source", EventSourcePubSub)
+		}
+		return NewPubSubEventSource(client, subscription.Name, subscription.DeadLetterTopic), nil
+	case EventSourceMemory:
+		size := config.BufferSize
+		if size <= 0 {
+			size = DefaultMemoryBufferSize
+		}
+		return NewMemoryEventSource(subscription.Name, size, concurrency), nil
+	case EventSourceSpool:
+		if config.Path == "" {
+			return nil, fmt.Errorf("event_source.path is required for the %s event source", EventSourceSpool)
+		}
+		interval := time.Duration(config.PollIntervalInMilliseconds) * time.Millisecond
+		return NewSpoolEventSource(subscription.Name, filepath.Join(config.Path, subscription.Name), interval, concurrency)
+	default:
+		return nil, fmt.Errorf("unknown event source: %s", config.Type)
+	}
+}
+
+// Message is a message delivered by an event source.
+type Message struct {
+	ID         string            // The unique ID of the message.
+	Data       []byte            // The message data, e.g. a GCS notification.
+	Attributes map[string]string // The message attributes.
+}
+
+// MessageHandler handles a received message, the message is acknowledged when the handler
+// returns nil and delivered again otherwise.
+type MessageHandler func(ctx context.Context, msg *Message) error
+
+// EventSource delivers the messages of a topic subscription to a Listener.
+type EventSource interface {
+	// Name returns the name of the source, e.g. the subscription ID.
+	Name() string
+	// Receive calls the handler for each message until the context is done.
+	Receive(ctx context.Context, handler MessageHandler) error
+}
+
+// DeadLetterSource is an event source keeping the messages whose attempts are exhausted.
+type DeadLetterSource interface {
+	// DeadLetter keeps a message whose attempts are exhausted with its number of attempts and last error.
+	DeadLetter(ctx context.Context, item *WorkItem) error
+}
+
+// MemoryEventSource delivers the messages published to a buffered channel, for tests and
+// workflows running in a single process.
+type MemoryEventSource struct {
+	name        string
+	messages    chan *Message
+	concurrency int
+	sequence    atomic.Int64
+}
+
+// NewMemoryEventSource creates a source buffering up to size published messages and handling up
+// to concurrency messages at a time, a concurrency below 1 handles one message at a time.
+func NewMemoryEventSource(name string, size int, concurrency int) *MemoryEventSource {
+	return &MemoryEventSource{name: name, messages: make(chan *Message, max(0, size)), concurrency: max(1, concurrency)}
+}
+
+func (s *MemoryEventSource) Name() string {
+	return s.name
+}
+
+// Publish delivers a message, blocking while the buffer is full. A message without an ID
+// gets a sequential one.
+func (s *MemoryEventSource) Publish(ctx context.Context, msg *Message) error {
+	if msg.ID == "" {
+		msg.ID = fmt.Sprintf("%s-%d", s.name, s.sequence.Add(1))
+	}
+	select {
+	case s.messages <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Receive calls the handler for the published messages concurrently, a message whose handler
+// fails is published again. It waits for the running handlers when the context is done.
+func (s *MemoryEventSource) Receive(ctx context.Context, handler MessageHandler) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	slots := make(chan struct{}, s.concurrency)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case slots <- struct{}{}:
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-s.messages:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				if err := handler(ctx, msg); err != nil {
+					go func() { _ = s.Publish(ctx, msg) }()
+				}
+			}()
+		}
+	}
+}
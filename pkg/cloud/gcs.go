@@ -16,9 +16,19 @@
 
 package cloud
 
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+)
+
+// GCSObjectKey is the typed context key of the GCS object that triggered a workflow.
+const GCSObjectKey cor.Key[*GCSObject] = "__GCS__OBJ__"
+
 // GetGCSObjectName returns a placeholder string for a GCS object name.
 func GetGCSObjectName() string {
-	return "__GCS__OBJ__"
+	return string(GCSObjectKey)
 }
 
 // GCSPubSubNotification is the structure of a message received from a
@@ -45,10 +55,30 @@ type GCSPubSubNotification struct {
 	ETag                    string                 `json:"etag"`
 }
 
+// ParseGCSPubSubNotification parses the data of a GCS Pub/Sub notification.
+func ParseGCSPubSubNotification(data []byte) (*GCSPubSubNotification, error) {
+	out := &GCSPubSubNotification{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IdempotencyKey returns the key of the object version of the notification, the deliveries
+// of a notification have the same key while a new upload of the object has a new generation.
+// The key is empty when the notification doesn't identify an object version.
+func (n *GCSPubSubNotification) IdempotencyKey() string {
+	if n.Bucket == "" || n.Name == "" || n.Generation == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s#%s.%s", n.Bucket, n.Name, n.Generation, n.MetaGeneration)
+}
+
 // GCSObject is a simplified representation of a Google Cloud Storage (GCS)
 // object. It contains the bucket name, object name, and MIME type of the object.
 type GCSObject struct {
-	Bucket   string
-	Name     string
-	MIMEType string
+	Bucket     string
+	Name       string
+	MIMEType   string
+	Generation string // The generation of the notification, empty when unknown.
 }
@@ -0,0 +1,127 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+)
+
+// Checkpoint store types supported by the checkpoints configuration.
+const (
+	CheckpointStoreFile = "file"
+	CheckpointStoreGCS  = "gcs"
+)
+
+func init() {
+	// Restore the typed values placed in the context by the listeners and trigger commands
+	cor.RegisterCheckpointKey(GCSObjectKey)
+	cor.RegisterCheckpointKey(PubSubAttributesKey)
+	// The GCS object is also the output of the trigger command
+	cor.RegisterCheckpointType[*GCSObject]()
+}
+
+// GCSCheckpointStore is a cor.CheckpointStore writing a JSON object per checkpoint
+// to <bucket>/<prefix>/<chain>/<id>.json of the object storage, GCS in production.
+type GCSCheckpointStore struct {
+	store  objectstore.ObjectStore
+	bucket string
+	prefix string
+}
+
+func NewGCSCheckpointStore(store objectstore.ObjectStore, bucket string, prefix string) *GCSCheckpointStore {
+	return &GCSCheckpointStore{store: store, bucket: bucket, prefix: strings.Trim(prefix, "/")}
+}
+
+func (s *GCSCheckpointStore) objectName(chain string, id string) string {
+	return path.Join(s.prefix, chain, id+".json")
+}
+
+func (s *GCSCheckpointStore) Save(ctx context.Context, checkpoint *cor.Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.Write(ctx, s.bucket, s.objectName(checkpoint.Chain, checkpoint.ID), "application/json", bytes.NewReader(data))
+	return err
+}
+
+func (s *GCSCheckpointStore) Load(ctx context.Context, chain string, id string) (*cor.Checkpoint, error) {
+	data, err := objectstore.ReadAll(ctx, s.store, s.bucket, s.objectName(chain, id))
+	if errors.Is(err, objectstore.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w: %s/%s", cor.ErrCheckpointNotFound, chain, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &cor.Checkpoint{}
+	if err = json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (s *GCSCheckpointStore) Delete(ctx context.Context, chain string, id string) error {
+	err := s.store.Delete(ctx, s.bucket, s.objectName(chain, id))
+	if errors.Is(err, objectstore.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *GCSCheckpointStore) List(ctx context.Context, chain string) ([]string, error) {
+	prefix := path.Join(s.prefix, chain) + "/"
+	objects, err := s.store.List(ctx, s.bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, attrs := range objects {
+		name := strings.TrimPrefix(attrs.Name, prefix)
+		if !strings.Contains(name, "/") && strings.HasSuffix(name, ".json") {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	return ids, nil
+}
+
+// NewCheckpointStore creates the checkpoint store of the configuration, or returns nil
+// when checkpointing is not configured.
+func NewCheckpointStore(config *Config, store objectstore.ObjectStore) (cor.CheckpointStore, error) {
+	switch config.Checkpoints.Store {
+	case "":
+		return nil, nil
+	case CheckpointStoreFile:
+		if config.Checkpoints.Path == "" {
+			return nil, errors.New("checkpoints.path is required for the file checkpoint store")
+		}
+		return cor.NewFileCheckpointStore(config.Checkpoints.Path)
+	case CheckpointStoreGCS:
+		if config.Checkpoints.Bucket == "" {
+			return nil, errors.New("checkpoints.bucket is required for the gcs checkpoint store")
+		}
+		return NewGCSCheckpointStore(store, config.Checkpoints.Bucket, config.Checkpoints.Path), nil
+	default:
+		return nil, fmt.Errorf("unknown checkpoint store: %s", config.Checkpoints.Store)
+	}
+}
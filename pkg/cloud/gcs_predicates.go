@@ -0,0 +1,101 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+)
+
+// GCS notification event types, sent as the eventType attribute of a Pub/Sub message.
+const (
+	GCSEventObjectFinalize       = "OBJECT_FINALIZE"
+	GCSEventObjectDelete         = "OBJECT_DELETE"
+	GCSEventObjectArchive        = "OBJECT_ARCHIVE"
+	GCSEventObjectMetadataUpdate = "OBJECT_METADATA_UPDATE"
+	GCSEventTypeAttribute        = "eventType"
+)
+
+// PubSubAttributesKey is the typed context key of the attributes of the received Pub/Sub message.
+const PubSubAttributesKey cor.Key[map[string]string] = "__PUBSUB__ATTRS__"
+
+// GetPubSubAttributesName returns the context key for the attributes of the received Pub/Sub message.
+func GetPubSubAttributesName() string {
+	return string(PubSubAttributesKey)
+}
+
+// GCSObjectFrom returns the GCS object placed in the context by MediaTriggerToGCSObject,
+// or nil if it's missing or of the wrong type.
+func GCSObjectFrom(context cor.Context) *GCSObject {
+	obj, _ := cor.GetKey(context, GCSObjectKey)
+	return obj
+}
+
+// MIMETypePrefix matches GCS objects whose MIME type starts with one of the prefixes, e.g. "video/".
+func MIMETypePrefix(prefixes ...string) cor.Predicate {
+	return func(context cor.Context) bool {
+		obj := GCSObjectFrom(context)
+		if obj == nil {
+			return false
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(obj.MIMEType, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// BucketEquals matches GCS objects in the given bucket.
+func BucketEquals(bucket string) cor.Predicate {
+	return func(context cor.Context) bool {
+		obj := GCSObjectFrom(context)
+		return obj != nil && obj.Bucket == bucket
+	}
+}
+
+// FileExtension matches GCS objects whose name ends in one of the extensions, e.g. ".toml".
+// The comparison is case-insensitive.
+func FileExtension(extensions ...string) cor.Predicate {
+	return func(context cor.Context) bool {
+		obj := GCSObjectFrom(context)
+		if obj == nil {
+			return false
+		}
+		ext := filepath.Ext(obj.Name)
+		for _, e := range extensions {
+			if strings.EqualFold(ext, e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// EventType matches messages whose eventType attribute is one of the given event types.
+func EventType(eventTypes ...string) cor.Predicate {
+	return func(context cor.Context) bool {
+		attributes, _ := cor.GetKey(context, PubSubAttributesKey)
+		for _, eventType := range eventTypes {
+			if attributes[GCSEventTypeAttribute] == eventType {
+				return true
+			}
+		}
+		return false
+	}
+}
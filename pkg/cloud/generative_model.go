@@ -0,0 +1,79 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+	"google.golang.org/genai"
+)
+
+// Supported providers of agent models.
+const (
+	ModelProviderVertex = "vertex"
+	ModelProviderOpenAI = "openai"
+)
+
+// ErrCachingNotSupported is returned by models that do not support cached content.
+var ErrCachingNotSupported = errors.New("cached content is not supported by the model provider")
+
+// GenerativeModel is a provider-agnostic generative model. Requests and responses use the
+// genai types: the response schema and cached content are set in the config, file parts
+// and video offsets are set as FileData and VideoMetadata parts of the contents.
+type GenerativeModel interface {
+	GenerateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error)
+	CreateCachedContent(ctx context.Context, model string, config *genai.CreateCachedContentConfig) (*genai.CachedContent, error)
+}
+
+// GeminiModel is the Vertex AI / Gemini implementation of GenerativeModel.
+type GeminiModel struct {
+	client *genai.Client
+}
+
+func NewGeminiModel(client *genai.Client) *GeminiModel {
+	return &GeminiModel{client: client}
+}
+
+func (g *GeminiModel) GenerateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	return g.client.Models.GenerateContent(ctx, model, contents, config)
+}
+
+func (g *GeminiModel) CreateCachedContent(ctx context.Context, model string, config *genai.CreateCachedContentConfig) (*genai.CachedContent, error) {
+	return g.client.Caches.Create(ctx, model, config)
+}
+
+// NewGenerativeModel creates the generative model of an agent model configuration, the genai
+// client is used by the vertex provider and the object store by the openai provider.
+func NewGenerativeModel(values VertexAiLLMModel, client *genai.Client, store objectstore.ObjectStore) (GenerativeModel, error) {
+	switch values.Provider {
+	case "", ModelProviderVertex:
+		if client == nil {
+			return nil, errors.New("a genai client is required for the vertex provider")
+		}
+		return NewGeminiModel(client), nil
+	case ModelProviderOpenAI:
+		if values.Endpoint == "" {
+			return nil, errors.New("an endpoint is required for the openai provider")
+		}
+		model := NewOpenAICompatibleModel(values.Endpoint, values.APIKey, nil)
+		model.SetObjectStore(store)
+		return model, nil
+	default:
+		return nil, fmt.Errorf("unknown model provider: %s", values.Provider)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+)
+
+// Idempotency store types supported by the idempotency configuration.
+const (
+	IdempotencyStoreMemory = "memory"
+	IdempotencyStoreFile   = "file"
+	IdempotencyStoreGCS    = "gcs"
+	IdempotencyStoreNone   = "none"
+)
+
+// DefaultIdempotencyTTL is the time the key of a handled event is kept when
+// idempotency.ttl_in_hours isn't set.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// IdempotencyStore records the keys of the handled events for a time to live, so duplicate
+// deliveries of an event are skipped.
+type IdempotencyStore interface {
+	// Claim records the key, it returns false when the key is recorded and not expired.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release removes the key, so the event is handled again when it's delivered again.
+	Release(ctx context.Context, key string) error
+}
+
+// idempotencyRecord is the record of a key of the file and gcs stores.
+type idempotencyRecord struct {
+	Key     string    `json:"key"`
+	Expires time.Time `json:"expires"`
+}
+
+// idempotencyName returns the file or object name of a key, keys contain object names.
+func idempotencyName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + ".json"
+}
+
+// MemoryIdempotencyStore keeps the keys in memory, duplicates are only detected by the process.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{expires: make(map[string]time.Time)}
+}
+
+func (s *MemoryIdempotencyStore) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, expires := range s.expires {
+		if !expires.After(now) {
+			delete(s.expires, k)
+		}
+	}
+	if _, ok := s.expires[key]; ok {
+		return false, nil
+	}
+	s.expires[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.expires, key)
+	return nil
+}
+
+// FileIdempotencyStore writes a JSON file per key to a directory, which may be shared by the
+// processes of a host or mounted by several hosts. Records are written to a temp file and
+// linked into place, so a key is claimed by a single process and never read half written.
+type FileIdempotencyStore struct {
+	dir string
+}
+
+func NewFileIdempotencyStore(dir string) (*FileIdempotencyStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileIdempotencyStore{dir: dir}, nil
+}
+
+func (s *FileIdempotencyStore) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(&idempotencyRecord{Key: key, Expires: time.Now().Add(ttl)})
+	if err != nil {
+		return false, err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".claim-")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err = errors.Join(err, tmp.Close()); err != nil {
+		return false, err
+	}
+
+	path := filepath.Join(s.dir, idempotencyName(key))
+	for {
+		// Linking fails when the record exists, unlike a rename which would replace it
+		err = os.Link(tmp.Name(), path)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return false, err
+		}
+		info, expired, err := s.expired(path, ttl)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil || !expired {
+			return false, err
+		}
+
+		// Move the expired record aside before removing it, another process may have replaced
+		// it since it was read, that record is put back and the key is claimed by it
+		stale := tmp.Name() + ".expired"
+		if err = os.Rename(path, stale); errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		moved, err := os.Stat(stale)
+		if err != nil {
+			return false, err
+		}
+		if !os.SameFile(info, moved) {
+			err = os.Link(stale, path)
+			if errors.Is(err, os.ErrExist) {
+				err = nil
+			}
+			return false, errors.Join(err, os.Remove(stale))
+		}
+		if err = os.Remove(stale); err != nil {
+			return false, err
+		}
+	}
+}
+
+// expired reads the record of a path, a record which can't be parsed is only expired once
+// the file is older than the ttl, it may be written by a newer version of the store.
+func (s *FileIdempotencyStore) expired(path string, ttl time.Duration) (os.FileInfo, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, false, err
+	}
+	return info, recordExpired(content, info.ModTime(), ttl), nil
+}
+
+// recordExpired returns true when the record expired, or when it can't be parsed and was
+// written more than the ttl ago.
+func recordExpired(content []byte, written time.Time, ttl time.Duration) bool {
+	record := &idempotencyRecord{}
+	if err := json.Unmarshal(content, record); err != nil {
+		return time.Since(written) > ttl
+	}
+	return !record.Expires.After(time.Now())
+}
+
+func (s *FileIdempotencyStore) Release(_ context.Context, key string) error {
+	err := os.Remove(filepath.Join(s.dir, idempotencyName(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// GCSIdempotencyStore writes a JSON object per key to <bucket>/<prefix>/ of the object
+// storage, GCS in production, so the keys are shared by all instances. Records are written
+// with a generation precondition, so a key is claimed by a single instance.
+type GCSIdempotencyStore struct {
+	store  objectstore.ObjectStore
+	bucket string
+	prefix string
+}
+
+func NewGCSIdempotencyStore(store objectstore.ObjectStore, bucket string, prefix string) *GCSIdempotencyStore {
+	return &GCSIdempotencyStore{store: store, bucket: bucket, prefix: strings.Trim(prefix, "/")}
+}
+
+func (s *GCSIdempotencyStore) objectName(key string) string {
+	return path.Join(s.prefix, idempotencyName(key))
+}
+
+func (s *GCSIdempotencyStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	name := s.objectName(key)
+	// Generation 0 only creates the record, otherwise the expired record is replaced if it
+	// wasn't replaced since it was read
+	generation := int64(0)
+	attrs, err := s.store.Attrs(ctx, s.bucket, name)
+	if err == nil {
+		content, err := objectstore.ReadAll(ctx, s.store, s.bucket, name)
+		if err != nil && !errors.Is(err, objectstore.ErrObjectNotExist) {
+			return false, err
+		}
+		if err == nil && !recordExpired(content, attrs.Updated, ttl) {
+			return false, nil
+		}
+		generation = attrs.Generation
+	} else if !errors.Is(err, objectstore.ErrObjectNotExist) {
+		return false, err
+	}
+	data, err := json.Marshal(&idempotencyRecord{Key: key, Expires: time.Now().Add(ttl)})
+	if err != nil {
+		return false, err
+	}
+	_, err = s.store.WriteIfGenerationMatch(ctx, s.bucket, name, "application/json", bytes.NewReader(data), generation)
+	if errors.Is(err, objectstore.ErrPreconditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *GCSIdempotencyStore) Release(ctx context.Context, key string) error {
+	err := s.store.Delete(ctx, s.bucket, s.objectName(key))
+	if errors.Is(err, objectstore.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
+
+// NewIdempotencyStore creates the idempotency store of the configuration, an in-memory store
+// when no store is configured, or nil when the store is "none".
+func NewIdempotencyStore(config *Config, store objectstore.ObjectStore) (IdempotencyStore, error) {
+	switch config.Idempotency.Store {
+	case "", IdempotencyStoreMemory:
+		return NewMemoryIdempotencyStore(), nil
+	case IdempotencyStoreNone:
+		return nil, nil
+	case IdempotencyStoreFile:
+		return NewFileIdempotencyStore(config.Idempotency.Path)
+	case IdempotencyStoreGCS:
+		if config.Idempotency.Bucket == "" {
+			return nil, errors.New("idempotency.bucket is required for the gcs idempotency store")
+		}
+		return NewGCSIdempotencyStore(store, config.Idempotency.Bucket, config.Idempotency.Path), nil
+	default:
+		return nil, fmt.Errorf("unknown idempotency store: %s", config.Idempotency.Store)
+	}
+}
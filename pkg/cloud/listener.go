@@ -0,0 +1,196 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+)
+
+// Listener is a simple stateful wrapper around an event source. This allows for the easy
+// configuration of multiple listeners. Since listeners life-cycles are outside the command
+// life-cycle they are considered cloud components. Received messages are queued and executed
+// by a bounded worker pool. When the queue is persistent a message is acknowledged once it's
+// queued, otherwise once its execution completes, so a crash doesn't lose the message.
+type Listener struct {
+	source         EventSource         // The source of the messages.
+	command        cor.Command         // The command to execute when a message is received.
+	scratch        cor.ScratchConfig   // The scratch directory configuration of each execution.
+	queue          *WorkQueue          // The queue of the received messages, in memory when not set.
+	workers        int                 // The number of concurrent executions.
+	retry          RetryPolicy         // The retry policy of failed executions.
+	controller     *ListenerController // The controller of the started listener.
+	idempotency    IdempotencyStore    // The keys of the handled GCS notifications, nil disables deduplication.
+	idempotencyTTL time.Duration       // The time the key of a handled notification is kept.
+}
+
+// NewListener the constructor for Listener
+func NewListener(source EventSource, command cor.Command) *Listener {
+	return &Listener{source: source, command: command}
+}
+
+// Source returns the event source of the listener, e.g. to publish to a MemoryEventSource.
+func (m *Listener) Source() EventSource {
+	return m.source
+}
+
+// SetCommand A setter for the underlying handler command.
+func (m *Listener) SetCommand(command cor.Command) {
+	// Only set the command if it's not already set.
+	if m.command == nil {
+		m.command = command
+	}
+}
+
+// SetScratchConfig sets the scratch directory configuration of the executions.
+func (m *Listener) SetScratchConfig(config cor.ScratchConfig) {
+	m.scratch = config
+}
+
+// SetWorkQueue sets the queue of the received messages, the number of concurrent executions
+// and the retry policy of failed executions.
+func (m *Listener) SetWorkQueue(queue *WorkQueue, workers int, retry RetryPolicy) {
+	m.queue = queue
+	m.workers = workers
+	m.retry = retry
+}
+
+// SetIdempotencyStore sets the store of the keys of the handled GCS notifications, a
+// notification of an object version whose key is recorded is skipped.
+func (m *Listener) SetIdempotencyStore(store IdempotencyStore, ttl time.Duration) {
+	m.idempotency = store
+	m.idempotencyTTL = ttl
+}
+
+// Listen starts the async function for listening and should be instantiated
+// using the same context of the cloud service but may be configured independently
+// for a different recovery life-cycle. The returned controller stops the listener.
+func (m *Listener) Listen(ctx context.Context) *ListenerController {
+	log.Printf("listening: %s", m.source.Name())
+
+	if m.queue == nil {
+		queue, err := NewWorkQueue(m.source.Name(), "")
+		if err != nil {
+			log.Printf("error creating work queue of %s: %v", m.source.Name(), err)
+			return nil
+		}
+		m.queue = queue
+	}
+	pool := NewWorkerPool(m.queue, m.command, m.workers, m.retry)
+	pool.SetScratchConfig(m.scratch)
+	pool.SetDeadLetter(m.deadLetter)
+
+	// Receive messages from the source, a message is acknowledged once it is persisted
+	// in the queue, or once it's executed when the queue is in memory.
+	receive := func(ctx context.Context) error {
+		return m.source.Receive(ctx, m.enqueue)
+	}
+
+	// The checkpointed executions interrupted by a restart are resumed by the pool when
+	// their message is executed again.
+	m.controller = StartListener(ctx, m.source.Name(), pool, receive)
+	return m.controller
+}
+
+// Stop stops the listener started by Listen, see ListenerController.Stop, and closes its queue.
+func (m *Listener) Stop(ctx context.Context) []*WorkItem {
+	if m.queue != nil {
+		defer m.queue.Close()
+	}
+	if m.controller == nil {
+		return nil
+	}
+	return m.controller.Stop(ctx)
+}
+
+// enqueue persists a received message in the work queue, skipping the duplicate deliveries.
+// With an in-memory queue it waits for the message to be executed or dead lettered, the message
+// isn't acknowledged when the listener stops first so it's delivered again.
+func (m *Listener) enqueue(ctx context.Context, msg *Message) error {
+	key := m.idempotencyKey(string(msg.Data))
+	if key != "" {
+		claimed, err := m.idempotency.Claim(ctx, key, m.idempotencyTTL)
+		if err != nil {
+			// The message is handled rather than lost when the store is unavailable
+			log.Printf("error claiming %s: %v", key, err)
+		} else if !claimed {
+			log.Printf("skipping duplicate delivery %s of %s", msg.ID, key)
+			return nil
+		}
+	}
+	err := m.queue.Enqueue(&WorkItem{
+		ID:         msg.ID,
+		Data:       string(msg.Data),
+		Attributes: msg.Attributes,
+	})
+	if err != nil {
+		log.Printf("error queueing message %s: %v", msg.ID, err)
+		m.releaseIdempotencyKey(ctx, key)
+		return err
+	}
+	if m.queue.IsPersistent() {
+		return nil
+	}
+	select {
+	case <-m.queue.Done(msg.ID):
+		return nil
+	case <-ctx.Done():
+		m.releaseIdempotencyKey(context.WithoutCancel(ctx), key)
+		return ctx.Err()
+	}
+}
+
+// idempotencyKey returns the source scoped key of a GCS notification, empty when the
+// deduplication is disabled or the message isn't a notification of an object version.
+func (m *Listener) idempotencyKey(data string) string {
+	if m.idempotency == nil {
+		return ""
+	}
+	notification, err := ParseGCSPubSubNotification([]byte(data))
+	if err != nil {
+		return ""
+	}
+	key := notification.IdempotencyKey()
+	if key == "" {
+		return ""
+	}
+	return m.source.Name() + "/" + key
+}
+
+func (m *Listener) releaseIdempotencyKey(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := m.idempotency.Release(ctx, key); err != nil {
+		log.Printf("error releasing %s: %v", key, err)
+	}
+}
+
+// deadLetter releases the key of an item whose attempts are exhausted, so a new delivery of
+// the notification is handled, and hands the item to the source when it keeps dead letters.
+func (m *Listener) deadLetter(ctx context.Context, item *WorkItem) error {
+	if source, ok := m.source.(DeadLetterSource); ok {
+		if err := source.DeadLetter(ctx, item); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("dropping work item %s after %d attempt(s), no dead letter destination: %s", item.ID, item.Attempts, item.LastError)
+	}
+	m.releaseIdempotencyKey(ctx, m.idempotencyKey(item.Data))
+	return nil
+}
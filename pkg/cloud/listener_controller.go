@@ -0,0 +1,80 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// ReceiveFunc receives messages until the context is done.
+type ReceiveFunc func(ctx context.Context) error
+
+// ListenerController is the handle of a started listener, it stops receiving messages and
+// drains the executions of the listener.
+type ListenerController struct {
+	name          string
+	pool          *WorkerPool
+	stopReceiving context.CancelFunc
+	cancel        context.CancelFunc
+	receiving     chan struct{} // Closed when the receive function returns.
+	stopOnce      sync.Once
+	abandoned     []*WorkItem
+}
+
+// StartListener starts receiving messages with the receive function and executing the queued
+// messages with the pool.
+func StartListener(ctx context.Context, name string, pool *WorkerPool, receive ReceiveFunc) *ListenerController {
+	executeCtx, cancel := context.WithCancel(ctx)
+	receiveCtx, stopReceiving := context.WithCancel(executeCtx)
+	c := &ListenerController{
+		name:          name,
+		pool:          pool,
+		stopReceiving: stopReceiving,
+		cancel:        cancel,
+		receiving:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(c.receiving)
+		if err := receive(receiveCtx); err != nil {
+			log.Printf("error receiving data: %v", err)
+		}
+	}()
+
+	go pool.Run(executeCtx)
+	return c
+}
+
+// Stop stops receiving messages and waits for the running executions until the context is
+// done. The executions still running are cancelled and returned, with a persistent work
+// queue they're executed again when the listener is restarted. Stop returns the result of
+// the first call when called again.
+func (c *ListenerController) Stop(ctx context.Context) []*WorkItem {
+	c.stopOnce.Do(func() {
+		log.Printf("stopping listener: %s", c.name)
+		c.stopReceiving()
+		<-c.receiving
+
+		c.abandoned = c.pool.Drain(ctx)
+		c.cancel()
+		for _, item := range c.abandoned {
+			log.Printf("listener %s abandoned work item %s at the drain deadline", c.name, item.ID)
+		}
+		log.Printf("stopped listener: %s", c.name)
+	})
+	return c.abandoned
+}
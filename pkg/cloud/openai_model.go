@@ -0,0 +1,318 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+	"google.golang.org/genai"
+)
+
+// OpenAICompatibleModel is a GenerativeModel calling the chat completions API of an
+// OpenAI-compatible HTTP endpoint, e.g. a local model server. File parts are sent as
+// image_url or video_url content parts, video offsets as a media fragment (#t=start,end)
+// of the URL, and response schemas as a json_schema response format. The endpoint can't
+// read gs:// files, they're read from the object store and sent inline as data URLs, with
+// the video offsets as a text part.
+type OpenAICompatibleModel struct {
+	endpoint   string
+	apiKey     string
+	httpClient *http.Client
+	store      objectstore.ObjectStore
+}
+
+// NewOpenAICompatibleModel creates a model for an endpoint, e.g. http://localhost:8000/v1.
+func NewOpenAICompatibleModel(endpoint string, apiKey string, httpClient *http.Client) *OpenAICompatibleModel {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &OpenAICompatibleModel{endpoint: strings.TrimSuffix(endpoint, "/"), apiKey: apiKey, httpClient: httpClient}
+}
+
+// SetObjectStore sets the object store the gs:// files of the requests are read from, e.g. the
+// local directory of the local storage backend.
+func (o *OpenAICompatibleModel) SetObjectStore(store objectstore.ObjectStore) {
+	o.store = store
+}
+
+type chatMessage struct {
+	Role    string        `json:"role"`
+	Content []chatContent `json:"content"`
+}
+
+type chatContent struct {
+	Type     string       `json:"type"`
+	Text     string       `json:"text,omitempty"`
+	ImageURL *chatFileURL `json:"image_url,omitempty"`
+	VideoURL *chatFileURL `json:"video_url,omitempty"`
+}
+
+type chatFileURL struct {
+	URL string `json:"url"`
+}
+
+type chatRequest struct {
+	Model          string                 `json:"model"`
+	Messages       []chatMessage          `json:"messages"`
+	Temperature    *float32               `json:"temperature,omitempty"`
+	TopP           *float32               `json:"top_p,omitempty"`
+	MaxTokens      int32                  `json:"max_tokens,omitempty"`
+	ResponseFormat map[string]interface{} `json:"response_format,omitempty"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int32 `json:"prompt_tokens"`
+		CompletionTokens int32 `json:"completion_tokens"`
+		TotalTokens      int32 `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// OpenAIError is returned for unsuccessful responses of the endpoint.
+type OpenAIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *OpenAIError) Error() string {
+	return fmt.Sprintf("openai endpoint returned status %d: %s", e.StatusCode, e.Body)
+}
+
+func (o *OpenAICompatibleModel) GenerateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	if config != nil && config.CachedContent != "" {
+		return nil, ErrCachingNotSupported
+	}
+	request := chatRequest{Model: model}
+	if config != nil {
+		request.Temperature = config.Temperature
+		request.TopP = config.TopP
+		request.MaxTokens = config.MaxOutputTokens
+		if config.SystemInstruction != nil {
+			message, err := o.toChatMessage(ctx, "system", config.SystemInstruction)
+			if err != nil {
+				return nil, err
+			}
+			request.Messages = append(request.Messages, message)
+		}
+		if config.ResponseSchema != nil {
+			request.ResponseFormat = map[string]interface{}{
+				"type": "json_schema",
+				"json_schema": map[string]interface{}{
+					"name":   "response",
+					"schema": toJSONSchema(config.ResponseSchema),
+				},
+			}
+		} else if config.ResponseMIMEType == "application/json" {
+			request.ResponseFormat = map[string]interface{}{"type": "json_object"}
+		}
+	}
+	for _, content := range contents {
+		role := "user"
+		if content.Role == genai.RoleModel {
+			role = "assistant"
+		}
+		message, err := o.toChatMessage(ctx, role, content)
+		if err != nil {
+			return nil, err
+		}
+		request.Messages = append(request.Messages, message)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	httpResponse, err := o.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+	responseBody, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, err
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, &OpenAIError{StatusCode: httpResponse.StatusCode, Body: string(responseBody)}
+	}
+
+	var response chatResponse
+	if err = json.Unmarshal(responseBody, &response); err != nil {
+		return nil, fmt.Errorf("invalid response from openai endpoint: %w", err)
+	}
+	out := &genai.GenerateContentResponse{
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:     response.Usage.PromptTokens,
+			CandidatesTokenCount: response.Usage.CompletionTokens,
+			TotalTokenCount:      response.Usage.TotalTokens,
+		},
+	}
+	for _, choice := range response.Choices {
+		out.Candidates = append(out.Candidates, &genai.Candidate{
+			Content:      genai.NewContentFromText(choice.Message.Content, genai.RoleModel),
+			FinishReason: toFinishReason(choice.FinishReason),
+		})
+	}
+	return out, nil
+}
+
+func (o *OpenAICompatibleModel) CreateCachedContent(_ context.Context, _ string, _ *genai.CreateCachedContentConfig) (*genai.CachedContent, error) {
+	return nil, ErrCachingNotSupported
+}
+
+func (o *OpenAICompatibleModel) toChatMessage(ctx context.Context, role string, content *genai.Content) (chatMessage, error) {
+	message := chatMessage{Role: role}
+	for _, part := range content.Parts {
+		switch {
+		case part.Text != "":
+			message.Content = append(message.Content, chatContent{Type: "text", Text: part.Text})
+		case part.FileData != nil:
+			url, segment := part.FileData.FileURI, ""
+			if strings.HasPrefix(url, gcsURIPrefix) {
+				data, err := o.readObject(ctx, url)
+				if err != nil {
+					return message, err
+				}
+				// A data URL has no media fragment
+				url = "data:" + part.FileData.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(data)
+				segment = segmentText(part.VideoMetadata)
+			} else {
+				url += mediaFragment(part.VideoMetadata)
+			}
+			if strings.HasPrefix(part.FileData.MIMEType, "image/") {
+				message.Content = append(message.Content, chatContent{Type: "image_url", ImageURL: &chatFileURL{URL: url}})
+			} else {
+				message.Content = append(message.Content, chatContent{Type: "video_url", VideoURL: &chatFileURL{URL: url}})
+			}
+			if segment != "" {
+				message.Content = append(message.Content, chatContent{Type: "text", Text: segment})
+			}
+		}
+	}
+	return message, nil
+}
+
+const gcsURIPrefix = "gs://"
+
+// readObject reads the object of a gs://<bucket>/<name> URI from the object store.
+func (o *OpenAICompatibleModel) readObject(ctx context.Context, uri string) ([]byte, error) {
+	if o.store == nil {
+		return nil, fmt.Errorf("an object store is required to send %s to the openai endpoint", uri)
+	}
+	bucket, name, ok := strings.Cut(strings.TrimPrefix(uri, gcsURIPrefix), "/")
+	if !ok || bucket == "" || name == "" {
+		return nil, fmt.Errorf("invalid object URI: %s", uri)
+	}
+	data, err := objectstore.ReadAll(ctx, o.store, bucket, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", uri, err)
+	}
+	return data, nil
+}
+
+// segmentText returns the instruction restricting the analysis to the video offsets of an inline
+// video, empty when the whole video is analyzed.
+func segmentText(metadata *genai.VideoMetadata) string {
+	switch {
+	case metadata == nil:
+		return ""
+	case metadata.EndOffset > 0:
+		return fmt.Sprintf("Only consider the video from %gs to %gs.", metadata.StartOffset.Seconds(), metadata.EndOffset.Seconds())
+	case metadata.StartOffset > 0:
+		return fmt.Sprintf("Only consider the video from %gs to the end.", metadata.StartOffset.Seconds())
+	default:
+		return ""
+	}
+}
+
+// mediaFragment returns the #t=start,end media fragment of the video offsets, an unset end
+// offset plays to the end of the video.
+func mediaFragment(metadata *genai.VideoMetadata) string {
+	switch {
+	case metadata == nil:
+		return ""
+	case metadata.EndOffset > 0:
+		return fmt.Sprintf("#t=%g,%g", metadata.StartOffset.Seconds(), metadata.EndOffset.Seconds())
+	case metadata.StartOffset > 0:
+		return fmt.Sprintf("#t=%g", metadata.StartOffset.Seconds())
+	default:
+		return ""
+	}
+}
+
+// toJSONSchema converts a genai schema, whose types are upper case OpenAPI types, to a JSON schema.
+func toJSONSchema(schema *genai.Schema) map[string]interface{} {
+	out := make(map[string]interface{})
+	if schema.Type != "" {
+		out["type"] = strings.ToLower(string(schema.Type))
+	}
+	if schema.Description != "" {
+		out["description"] = schema.Description
+	}
+	if len(schema.Enum) > 0 {
+		out["enum"] = schema.Enum
+	}
+	if len(schema.Required) > 0 {
+		out["required"] = schema.Required
+	}
+	if schema.Items != nil {
+		out["items"] = toJSONSchema(schema.Items)
+	}
+	if len(schema.Properties) > 0 {
+		properties := make(map[string]interface{}, len(schema.Properties))
+		for name, property := range schema.Properties {
+			properties[name] = toJSONSchema(property)
+		}
+		out["properties"] = properties
+	}
+	if schema.Nullable != nil && *schema.Nullable {
+		out["type"] = []interface{}{out["type"], "null"}
+	}
+	return out
+}
+
+func toFinishReason(reason string) genai.FinishReason {
+	switch reason {
+	case "stop":
+		return genai.FinishReasonStop
+	case "length":
+		return genai.FinishReasonMaxTokens
+	case "content_filter":
+		return genai.FinishReasonSafety
+	default:
+		return genai.FinishReasonOther
+	}
+}
@@ -20,99 +20,81 @@ package cloud
 import (
 	"context"
 	"log"
+	"strconv"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
 )
 
-// PubSubListener is a simple stateful wrapper around a subscription object.
-// this allows for the easy configuration of multiple listeners. Since listeners
-// life-cycles are outside the command life-cycle they are considered cloud components.
-type PubSubListener struct {
-	client       *pubsub.Client       // The Pub/Sub client.
-	subscription *pubsub.Subscription // The Pub/Sub subscription.
-	command      cor.Command          // The command to execute when a message is received.
-}
-
-// NewPubSubListener the constructor for PubSubListener
-func NewPubSubListener(
-	pubsubClient *pubsub.Client, // The Pub/Sub client.
-	subscriptionID string, // The ID of the Pub/Sub subscription.
-	command cor.Command, // The command to execute when a message is received.
-) (cmd *PubSubListener, err error) {
-
-	// Get the subscription from the Pub/Sub client.
-	sub := pubsubClient.Subscription(subscriptionID)
+// Attributes added to the messages published to a dead letter topic.
+const (
+	DeadLetterAttemptsAttribute = "dead_letter_attempts"
+	DeadLetterErrorAttribute    = "dead_letter_error"
+)
 
-	// Create a new PubSubListener.
-	cmd = &PubSubListener{
-		client:       pubsubClient,
-		subscription: sub,
-		command:      command,
-	}
-	return cmd, nil
+// PubSubEventSource is the event source of a Pub/Sub subscription.
+type PubSubEventSource struct {
+	client          *pubsub.Client       // The Pub/Sub client.
+	subscription    *pubsub.Subscription // The Pub/Sub subscription.
+	deadLetterTopic string               // The topic of the messages whose attempts are exhausted.
 }
 
-// SetCommand A setter for the underlying handler command.
-func (m *PubSubListener) SetCommand(command cor.Command) {
-	// Only set the command if it's not already set.
-	if m.command == nil {
-		m.command = command
+// NewPubSubEventSource creates the source of a subscription, the messages whose attempts are
+// exhausted are published to the dead letter topic, or dropped when it's empty.
+func NewPubSubEventSource(pubsubClient *pubsub.Client, subscriptionID string, deadLetterTopic string) *PubSubEventSource {
+	return &PubSubEventSource{
+		client:          pubsubClient,
+		subscription:    pubsubClient.Subscription(subscriptionID),
+		deadLetterTopic: deadLetterTopic,
 	}
 }
 
-// Listen starts the async function for listening and should be instantiated
-// using the same context of the cloud service but may be configured independently
-// for a different recovery life-cycle.
-func (m *PubSubListener) Listen(ctx context.Context) {
-	log.Printf("listening: %s", m.subscription)
-
-	// Start a goroutine to listen for messages.
-	go func() {
-		// Create a new tracer.
-		tracer := otel.Tracer("message-listener")
-
-		// Receive messages from the subscription.
-		err := m.subscription.Receive(ctx, func(_ context.Context, msg *pubsub.Message) {
-			// Start a new span.
-			spanCtx, span := tracer.Start(ctx, "receive-message")
-			span.SetName("receive-message")
-			msgDataStr := string(msg.Data)
-			span.SetAttributes(attribute.String("msg", msgDataStr))
-
-			// Create a new chain context.
-			chainCtx := cor.NewBaseContext()
-			chainCtx.SetContext(spanCtx)
-			chainCtx.Add(cor.CtxIn, msgDataStr)
-
-			// Moving message acknowledgement to here tempurarily as the processing takes more than 600 seconds. which is the maximum time for a message to be acknowledged.
-			// If this times out, the resize pipeline don't gets to run to completion, and messages are redelivered so we end up in an infinite loop.
-			// TODO: decouple the message receiving from the command execution.
-			msg.Ack()
-
-			// Execute the command.
-			m.command.Execute(chainCtx)
-
-			// Only acknowledge the message if the command executed successfully.
-			if !chainCtx.HasErrors() {
-				span.SetStatus(codes.Ok, "success")
-			} else {
-				span.SetStatus(codes.Error, "failed")
-				for _, e := range chainCtx.GetErrors() {
-					log.Printf("error executing chain: %v", e)
-				}
-			}
+func (s *PubSubEventSource) Name() string {
+	return s.subscription.ID()
+}
 
-			// End the span.
-			span.End()
+// Receive receives the messages of the subscription, a message is acknowledged when the
+// handler succeeds and negatively acknowledged otherwise.
+func (s *PubSubEventSource) Receive(ctx context.Context, handler MessageHandler) error {
+	return s.subscription.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		err := handler(ctx, &Message{
+			ID:         msg.ID,
+			Data:       msg.Data,
+			Attributes: msg.Attributes,
 		})
-
-		// Log any errors.
 		if err != nil {
-			log.Printf("error receiving data: %v", err)
+			msg.Nack()
+			return
 		}
-	}()
+		msg.Ack()
+	})
+}
+
+// DeadLetter publishes an item to the dead letter topic with its number of attempts and last error.
+func (s *PubSubEventSource) DeadLetter(ctx context.Context, item *WorkItem) error {
+	if s.deadLetterTopic == "" {
+		log.Printf("dropping work item %s after %d attempt(s), no dead letter topic: %s", item.ID, item.Attempts, item.LastError)
+		return nil
+	}
+	attributes := make(map[string]string, len(item.Attributes)+2)
+	for key, value := range item.Attributes {
+		attributes[key] = value
+	}
+	attributes[DeadLetterAttemptsAttribute] = strconv.Itoa(item.Attempts)
+	attributes[DeadLetterErrorAttribute] = item.LastError
+	result := s.client.Topic(s.deadLetterTopic).Publish(ctx, &pubsub.Message{
+		Data:       []byte(item.Data),
+		Attributes: attributes,
+	})
+	_, err := result.Get(ctx)
+	return err
+}
+
+// NewPubSubListener the constructor of a Listener of a Pub/Sub subscription.
+func NewPubSubListener(
+	pubsubClient *pubsub.Client, // The Pub/Sub client.
+	subscriptionID string, // The ID of the Pub/Sub subscription.
+	command cor.Command, // The command to execute when a message is received.
+) (cmd *Listener, err error) {
+	return NewListener(NewPubSubEventSource(pubsubClient, subscriptionID, ""), command), nil
 }
@@ -0,0 +1,273 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Supported quota coordinators, an empty coordinator only applies the local limits.
+const (
+	QuotaCoordinatorLocal = "local"
+	QuotaCoordinatorFile  = "file"
+	QuotaCoordinatorHTTP  = "http"
+)
+
+// StaleLockTimeout is the age after which the lock of a file lease store is considered abandoned.
+const StaleLockTimeout = 10 * time.Second
+
+// QuotaBudget is the shared budget of a model.
+type QuotaBudget struct {
+	RequestsPerMinute float64
+	TokensPerMinute   int
+}
+
+// QuotaCoordinator shares the budget of a model across concurrent jobs.
+type QuotaCoordinator interface {
+	// Acquire blocks until a request of the given number of tokens fits in the budget of the model.
+	Acquire(ctx context.Context, model string, budget QuotaBudget, tokens int) error
+}
+
+// LeaseRequest takes an amount from a token bucket, the bucket is refilled continuously
+// up to its capacity.
+type LeaseRequest struct {
+	Bucket          string  `json:"bucket"`
+	Amount          float64 `json:"amount"`
+	Capacity        float64 `json:"capacity"`
+	RefillPerSecond float64 `json:"refill_per_second"`
+}
+
+// Lease is a granted amount of a bucket, usable after waiting.
+type Lease struct {
+	Bucket string        `json:"bucket"`
+	Amount float64       `json:"amount"`
+	Wait   time.Duration `json:"wait"`
+}
+
+// LeaseStore holds the token buckets, a lease is always granted and the bucket may go
+// into debt, in which case the lease has to wait for the debt to be refilled.
+type LeaseStore interface {
+	Lease(ctx context.Context, request LeaseRequest) (*Lease, error)
+}
+
+// Validate checks the values of a lease request.
+func (r LeaseRequest) Validate() error {
+	if r.Bucket == "" {
+		return errors.New("lease bucket is required")
+	}
+	if r.Amount <= 0 || r.Capacity <= 0 || r.RefillPerSecond <= 0 {
+		return fmt.Errorf("lease of %s requires a positive amount, capacity and refill rate", r.Bucket)
+	}
+	return nil
+}
+
+// bucketState is the persisted state of a token bucket.
+type bucketState struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// take refills the bucket and takes the amount of the request, returning the lease.
+func (b *bucketState) take(now time.Time, request LeaseRequest) *Lease {
+	if b.Updated.IsZero() {
+		b.Tokens = request.Capacity
+	} else {
+		b.Tokens = min(request.Capacity, b.Tokens+now.Sub(b.Updated).Seconds()*request.RefillPerSecond)
+	}
+	b.Updated = now
+	b.Tokens -= min(request.Amount, request.Capacity)
+	lease := &Lease{Bucket: request.Bucket, Amount: request.Amount}
+	if b.Tokens < 0 {
+		lease.Wait = time.Duration(-b.Tokens / request.RefillPerSecond * float64(time.Second))
+	}
+	return lease
+}
+
+// MemoryLeaseStore is an in-process lease store, it coordinates the jobs of a single process.
+type MemoryLeaseStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucketState
+}
+
+func NewMemoryLeaseStore() *MemoryLeaseStore {
+	return &MemoryLeaseStore{buckets: make(map[string]*bucketState)}
+}
+
+func (s *MemoryLeaseStore) Lease(_ context.Context, request LeaseRequest) (*Lease, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bucket, ok := s.buckets[request.Bucket]
+	if !ok {
+		bucket = &bucketState{}
+		s.buckets[request.Bucket] = bucket
+	}
+	return bucket.take(time.Now(), request), nil
+}
+
+var unsafeBucketCharacters = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// FileLeaseStore keeps the buckets as JSON files of a directory, guarded by lock files.
+// It coordinates the processes sharing the directory, e.g. a mounted volume.
+type FileLeaseStore struct {
+	dir string
+}
+
+func NewFileLeaseStore(dir string) (*FileLeaseStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileLeaseStore{dir: dir}, nil
+}
+
+func (s *FileLeaseStore) Lease(ctx context.Context, request LeaseRequest) (*Lease, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.dir, unsafeBucketCharacters.ReplaceAllString(request.Bucket, "_")+".json")
+	unlock, err := lockFile(ctx, path+".lock")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var bucket bucketState
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err = json.Unmarshal(data, &bucket); err != nil {
+			return nil, fmt.Errorf("invalid bucket %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lease := bucket.take(time.Now(), request)
+	if data, err = json.Marshal(bucket); err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, err
+	}
+	return lease, os.Rename(tmp, path)
+}
+
+// lockFile creates the lock file exclusively, waiting for the current holder to release it.
+// Locks older than StaleLockTimeout are removed.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > StaleLockTimeout {
+			_ = os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+// BucketCoordinator is a QuotaCoordinator keeping a requests bucket and a tokens bucket
+// per model in a lease store.
+type BucketCoordinator struct {
+	store LeaseStore
+}
+
+func NewBucketCoordinator(store LeaseStore) *BucketCoordinator {
+	return &BucketCoordinator{store: store}
+}
+
+func (c *BucketCoordinator) Acquire(ctx context.Context, model string, budget QuotaBudget, tokens int) error {
+	if budget.RequestsPerMinute <= 0 {
+		return nil
+	}
+	lease, err := c.store.Lease(ctx, LeaseRequest{
+		Bucket:          model + ".requests",
+		Amount:          1,
+		Capacity:        max(1, budget.RequestsPerMinute/60),
+		RefillPerSecond: budget.RequestsPerMinute / 60,
+	})
+	if err != nil {
+		return err
+	}
+	wait := lease.Wait
+	if budget.TokensPerMinute > 0 && tokens > 0 {
+		lease, err = c.store.Lease(ctx, LeaseRequest{
+			Bucket:          model + ".tokens",
+			Amount:          float64(tokens),
+			Capacity:        float64(budget.TokensPerMinute),
+			RefillPerSecond: float64(budget.TokensPerMinute) / 60,
+		})
+		if err != nil {
+			return err
+		}
+		wait = max(wait, lease.Wait)
+	}
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// NewQuotaCoordinator creates the coordinator of the configuration, or nil when there is none.
+func NewQuotaCoordinator(config Quota) (QuotaCoordinator, error) {
+	switch config.Coordinator {
+	case "":
+		return nil, nil
+	case QuotaCoordinatorLocal:
+		return NewBucketCoordinator(NewMemoryLeaseStore()), nil
+	case QuotaCoordinatorFile:
+		if config.Path == "" {
+			return nil, errors.New("a path is required for the file quota coordinator")
+		}
+		store, err := NewFileLeaseStore(config.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewBucketCoordinator(store), nil
+	case QuotaCoordinatorHTTP:
+		if config.Endpoint == "" || config.Token == "" {
+			return nil, errors.New("an endpoint and a token are required for the http quota coordinator")
+		}
+		return NewBucketCoordinator(NewHTTPLeaseStore(config.Endpoint, config.Token, nil)), nil
+	default:
+		return nil, fmt.Errorf("unknown quota coordinator: %s", config.Coordinator)
+	}
+}
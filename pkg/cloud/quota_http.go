@@ -0,0 +1,101 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HTTPLeaseStore is a lease store served by a remote coordinator, see NewLeaseHandler.
+type HTTPLeaseStore struct {
+	endpoint   string
+	token      string
+	httpClient *http.Client
+}
+
+// NewHTTPLeaseStore creates a store for the lease endpoint of a coordinator,
+// e.g. http://api-server:8080/api/v1/quota/leases, authenticated with the shared token.
+func NewHTTPLeaseStore(endpoint string, token string, httpClient *http.Client) *HTTPLeaseStore {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &HTTPLeaseStore{endpoint: endpoint, token: token, httpClient: httpClient}
+}
+
+func (s *HTTPLeaseStore) Lease(ctx context.Context, request LeaseRequest) (*Lease, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Authorization", "Bearer "+s.token)
+	httpResponse, err := s.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(httpResponse.Body)
+		return nil, fmt.Errorf("quota coordinator returned status %d: %s", httpResponse.StatusCode, message)
+	}
+	var lease Lease
+	if err = json.NewDecoder(httpResponse.Body).Decode(&lease); err != nil {
+		return nil, fmt.Errorf("invalid response from quota coordinator: %w", err)
+	}
+	return &lease, nil
+}
+
+// NewLeaseHandler serves the leases of a store to HTTPLeaseStore clients sending the shared
+// token as a bearer token. Without a token every request is rejected.
+func NewLeaseHandler(store LeaseStore, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !found || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var request LeaseRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := request.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lease, err := store.Lease(r.Context(), request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(lease)
+	})
+}
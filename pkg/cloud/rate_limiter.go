@@ -0,0 +1,237 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+	"google.golang.org/genai"
+)
+
+const (
+	// DefaultRequestsPerMinute is used when an agent model has no request budget.
+	DefaultRequestsPerMinute = 60
+	// MinRequestsPerMinute is the lower bound of the adaptive request rate.
+	MinRequestsPerMinute = 1
+	// RateIncreaseRatio is the share of the configured rate added back after a successful request.
+	RateIncreaseRatio = 0.05
+	// RateDecreaseFactor is applied to the request rate when the quota is exhausted.
+	RateDecreaseFactor = 0.5
+)
+
+// AdaptiveRateLimiter is a blocking limiter with separate requests per minute and
+// tokens per minute budgets. The request rate is adjusted AIMD-style: it is halved
+// when the quota is exhausted and increased additively on success, up to the configured rate.
+type AdaptiveRateLimiter struct {
+	mu                sync.Mutex
+	name              string
+	requestsPerMinute float64
+	currentRate       float64
+	tokensPerMinute   int
+	requests          *rate.Limiter
+	tokens            *rate.Limiter
+	coordinator       QuotaCoordinator
+	waiting           atomic.Int64
+	throttled         atomic.Int64
+	registration      metric.Registration
+}
+
+// NewAdaptiveRateLimiter creates a limiter, a tokens per minute budget of 0 is unlimited.
+func NewAdaptiveRateLimiter(name string, requestsPerMinute int, tokensPerMinute int) *AdaptiveRateLimiter {
+	if requestsPerMinute <= 0 {
+		requestsPerMinute = DefaultRequestsPerMinute
+	}
+	l := &AdaptiveRateLimiter{
+		name:              name,
+		requestsPerMinute: float64(requestsPerMinute),
+		currentRate:       float64(requestsPerMinute),
+		tokensPerMinute:   tokensPerMinute,
+		requests:          rate.NewLimiter(perMinute(float64(requestsPerMinute)), burst(requestsPerMinute)),
+	}
+	if tokensPerMinute > 0 {
+		l.tokens = rate.NewLimiter(perMinute(float64(tokensPerMinute)), tokensPerMinute)
+	}
+	l.registerGauges()
+	return l
+}
+
+func perMinute(n float64) rate.Limit {
+	return rate.Limit(n / 60)
+}
+
+// burst allows a second worth of requests at once.
+func burst(requestsPerMinute int) int {
+	return max(1, requestsPerMinute/60)
+}
+
+// SetCoordinator shares the budget with concurrent jobs, the local limits still apply.
+func (l *AdaptiveRateLimiter) SetCoordinator(coordinator QuotaCoordinator) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.coordinator = coordinator
+}
+
+// Wait blocks until a request of the estimated number of tokens is allowed or the context is done.
+func (l *AdaptiveRateLimiter) Wait(ctx context.Context, estimatedTokens int) error {
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	if err := l.requests.Wait(ctx); err != nil {
+		return err
+	}
+	if l.tokens != nil && estimatedTokens > 0 {
+		if err := l.tokens.WaitN(ctx, min(estimatedTokens, l.tokens.Burst())); err != nil {
+			return err
+		}
+	}
+	l.mu.Lock()
+	coordinator, budget := l.coordinator, QuotaBudget{RequestsPerMinute: l.currentRate, TokensPerMinute: l.tokensPerMinute}
+	l.mu.Unlock()
+	if coordinator != nil {
+		return coordinator.Acquire(ctx, l.name, budget, estimatedTokens)
+	}
+	return nil
+}
+
+// Record charges the tokens used beyond the estimate to the tokens budget, delaying later requests.
+func (l *AdaptiveRateLimiter) Record(estimatedTokens int, usedTokens int) {
+	if l.tokens == nil || usedTokens <= estimatedTokens {
+		return
+	}
+	l.tokens.ReserveN(time.Now(), min(usedTokens-estimatedTokens, l.tokens.Burst()))
+}
+
+// OnSuccess increases the request rate additively.
+func (l *AdaptiveRateLimiter) OnSuccess() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.currentRate < l.requestsPerMinute {
+		l.setRate(min(l.requestsPerMinute, l.currentRate+l.requestsPerMinute*RateIncreaseRatio))
+	}
+}
+
+// OnThrottle decreases the request rate multiplicatively.
+func (l *AdaptiveRateLimiter) OnThrottle() {
+	l.throttled.Add(1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setRate(max(MinRequestsPerMinute, l.currentRate*RateDecreaseFactor))
+	log.Printf("quota exhausted for %s, reducing rate to %.1f requests per minute", l.name, l.currentRate)
+}
+
+func (l *AdaptiveRateLimiter) setRate(requestsPerMinute float64) {
+	l.currentRate = requestsPerMinute
+	l.requests.SetLimit(perMinute(requestsPerMinute))
+}
+
+// GetRequestsPerMinute returns the current request rate.
+func (l *AdaptiveRateLimiter) GetRequestsPerMinute() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.currentRate
+}
+
+// GetTokensAvailable returns the tokens currently available, or -1 when tokens are unlimited.
+func (l *AdaptiveRateLimiter) GetTokensAvailable() float64 {
+	if l.tokens == nil {
+		return -1
+	}
+	return l.tokens.Tokens()
+}
+
+func (l *AdaptiveRateLimiter) registerGauges() {
+	meter := otel.Meter("github.com/GoogleCloudPlatform/media-search-solution")
+	rateGauge, err := meter.Float64ObservableGauge("agent_model.rate_limit.requests_per_minute")
+	if err != nil {
+		log.Printf("error creating rate limit gauge: %s\n", l.name)
+		return
+	}
+	tokensGauge, err := meter.Float64ObservableGauge("agent_model.rate_limit.tokens_available")
+	if err != nil {
+		log.Printf("error creating tokens gauge: %s\n", l.name)
+		return
+	}
+	waitingGauge, err := meter.Int64ObservableGauge("agent_model.rate_limit.waiting")
+	if err != nil {
+		log.Printf("error creating waiting gauge: %s\n", l.name)
+		return
+	}
+	throttledGauge, err := meter.Int64ObservableGauge("agent_model.rate_limit.throttled")
+	if err != nil {
+		log.Printf("error creating throttled gauge: %s\n", l.name)
+		return
+	}
+	attributes := metric.WithAttributes(attribute.String("model", l.name))
+	l.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveFloat64(rateGauge, l.GetRequestsPerMinute(), attributes)
+		o.ObserveFloat64(tokensGauge, l.GetTokensAvailable(), attributes)
+		o.ObserveInt64(waitingGauge, l.waiting.Load(), attributes)
+		o.ObserveInt64(throttledGauge, l.throttled.Load(), attributes)
+		return nil
+	}, rateGauge, tokensGauge, waitingGauge, throttledGauge)
+	if err != nil {
+		log.Printf("error registering rate limit gauges: %s\n", l.name)
+	}
+}
+
+// Close unregisters the gauges of the limiter, e.g. when the limiter of a model is replaced.
+func (l *AdaptiveRateLimiter) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.registration == nil {
+		return
+	}
+	if err := l.registration.Unregister(); err != nil {
+		log.Printf("error unregistering rate limit gauges: %s: %v\n", l.name, err)
+	}
+	l.registration = nil
+}
+
+// IsResourceExhausted returns true when the error is a 429 or RESOURCE_EXHAUSTED response.
+func IsResourceExhausted(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiError genai.APIError
+	if errors.As(err, &apiError) {
+		return apiError.Code == http.StatusTooManyRequests || apiError.Status == "RESOURCE_EXHAUSTED"
+	}
+	var openAIError *OpenAIError
+	if errors.As(err, &openAIError) {
+		return openAIError.StatusCode == http.StatusTooManyRequests
+	}
+	return strings.Contains(err.Error(), "RESOURCE_EXHAUSTED")
+}
+
+// EstimateTokens estimates the prompt tokens of the contents, about four characters per token.
+func EstimateTokens(systemInstruction string, contents []*genai.Content) int {
+	characters := len(systemInstruction)
+	for _, content := range contents {
+		for _, part := range content.Parts {
+			characters += len(part.Text)
+		}
+	}
+	return characters/4 + 1
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Classes of generation errors, used to select the errors retried by a RetryPolicy.
+const (
+	ErrorClassQuota       = "quota"        // The quota is exhausted (429 / RESOURCE_EXHAUSTED).
+	ErrorClassServer      = "server"       // The model returned a 5xx error.
+	ErrorClassSafety      = "safety"       // The prompt or the response was blocked.
+	ErrorClassEmpty       = "empty"        // The model returned no text.
+	ErrorClassInvalidJSON = "invalid_json" // The response of a schema request isn't valid JSON.
+	ErrorClassOther       = "other"        // Any other error, e.g. an invalid request.
+)
+
+// Defaults of the retry policy of agent models.
+const (
+	DefaultBackoffInMilliseconds    = 1000
+	DefaultMaxBackoffInMilliseconds = 60000
+	DefaultBackoffMultiplier        = 2.0
+)
+
+// DefaultRetryOn are the error classes retried when a policy doesn't set them.
+var DefaultRetryOn = []string{ErrorClassQuota, ErrorClassServer, ErrorClassEmpty, ErrorClassInvalidJSON}
+
+// GenerationError is an error of a generation request with its class.
+type GenerationError struct {
+	Class string
+	Err   error
+}
+
+func (e *GenerationError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Class, e.Err)
+}
+
+func (e *GenerationError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError returns the class of a generation error.
+func ClassifyError(err error) string {
+	var generationError *GenerationError
+	if errors.As(err, &generationError) {
+		return generationError.Class
+	}
+	if IsResourceExhausted(err) {
+		return ErrorClassQuota
+	}
+	var apiError genai.APIError
+	if errors.As(err, &apiError) && apiError.Code >= http.StatusInternalServerError {
+		return ErrorClassServer
+	}
+	var openAIError *OpenAIError
+	if errors.As(err, &openAIError) && openAIError.StatusCode >= http.StatusInternalServerError {
+		return ErrorClassServer
+	}
+	return ErrorClassOther
+}
+
+// IsBlocked returns true when the prompt or all candidates of the response were blocked for safety.
+func IsBlocked(resp *genai.GenerateContentResponse) bool {
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return true
+	}
+	if len(resp.Candidates) == 0 {
+		return false
+	}
+	for _, candidate := range resp.Candidates {
+		switch candidate.FinishReason {
+		case genai.FinishReasonSafety, genai.FinishReasonBlocklist, genai.FinishReasonProhibitedContent,
+			genai.FinishReasonSPII, genai.FinishReasonImageSafety:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// GetMaxAttempts returns the maximum number of attempts, defaults to MaxRetries + 1.
+func (p RetryPolicy) GetMaxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return MaxRetries + 1
+	}
+	return p.MaxAttempts
+}
+
+// GetRetryOn returns the retried error classes, defaults to DefaultRetryOn.
+func (p RetryPolicy) GetRetryOn() []string {
+	if len(p.RetryOn) == 0 {
+		return DefaultRetryOn
+	}
+	return p.RetryOn
+}
+
+// Retries returns true when the error class is retried.
+func (p RetryPolicy) Retries(class string) bool {
+	return slices.Contains(p.GetRetryOn(), class)
+}
+
+// Backoff returns the delay before the given retry, starting at 0.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	backoff := float64(p.BackoffInMilliseconds)
+	if backoff <= 0 {
+		backoff = DefaultBackoffInMilliseconds
+	}
+	maxBackoff := float64(p.MaxBackoffInMilliseconds)
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoffInMilliseconds
+	}
+	multiplier := p.BackoffMultiplier
+	if multiplier < 1 {
+		multiplier = DefaultBackoffMultiplier
+	}
+	for range retry {
+		backoff *= multiplier
+	}
+	return time.Duration(min(backoff, maxBackoff)) * time.Millisecond
+}
+
+// Validate checks the error classes and the fallback model of the policy.
+func (p RetryPolicy) Validate(models map[string]VertexAiLLMModel) error {
+	var errs []error
+	for _, class := range p.RetryOn {
+		switch class {
+		case ErrorClassQuota, ErrorClassServer, ErrorClassSafety, ErrorClassEmpty, ErrorClassInvalidJSON, ErrorClassOther:
+		default:
+			errs = append(errs, fmt.Errorf("unknown error class %q", class))
+		}
+	}
+	if p.FallbackModel != "" {
+		if _, ok := models[p.FallbackModel]; !ok {
+			errs = append(errs, fmt.Errorf("unknown fallback model %q", p.FallbackModel))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// ErrScriptExhausted is returned by a ScriptedModel when it has no more responses.
+var ErrScriptExhausted = errors.New("scripted model has no more responses")
+
+// ScriptedResponse is a response of a ScriptedModel, either a text or an error.
+type ScriptedResponse struct {
+	Text  string
+	Err   error
+	Delay time.Duration
+}
+
+// ScriptedRequest is a request recorded by a ScriptedModel.
+type ScriptedRequest struct {
+	Model    string
+	Contents []*genai.Content
+	Config   *genai.GenerateContentConfig
+}
+
+// ScriptedModel is a fake GenerativeModel for tests, it returns its responses in order
+// and records the requests it receives.
+type ScriptedModel struct {
+	mu        sync.Mutex
+	responses []ScriptedResponse
+	requests  []ScriptedRequest
+	caches    int
+}
+
+func NewScriptedModel(responses ...ScriptedResponse) *ScriptedModel {
+	return &ScriptedModel{responses: responses}
+}
+
+// Add appends responses to the script.
+func (s *ScriptedModel) Add(responses ...ScriptedResponse) *ScriptedModel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, responses...)
+	return s
+}
+
+// GetRequests returns the requests received so far.
+func (s *ScriptedModel) GetRequests() []ScriptedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ScriptedRequest(nil), s.requests...)
+}
+
+func (s *ScriptedModel) GenerateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, ScriptedRequest{Model: model, Contents: contents, Config: config})
+	if len(s.responses) == 0 {
+		s.mu.Unlock()
+		return nil, ErrScriptExhausted
+	}
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+	s.mu.Unlock()
+
+	if response.Delay > 0 {
+		select {
+		case <-time.After(response.Delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if response.Err != nil {
+		return nil, response.Err
+	}
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{{
+			Content:      genai.NewContentFromText(response.Text, genai.RoleModel),
+			FinishReason: genai.FinishReasonStop,
+		}},
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:     int32(len(contents)),
+			CandidatesTokenCount: int32(len(response.Text)),
+		},
+	}, nil
+}
+
+// CreateCachedContent returns a cache named after the model, valid for an hour.
+func (s *ScriptedModel) CreateCachedContent(_ context.Context, model string, _ *genai.CreateCachedContentConfig) (*genai.CachedContent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caches++
+	return &genai.CachedContent{
+		Name:       fmt.Sprintf("cachedContents/%s-%d", model, s.caches),
+		Model:      model,
+		ExpireTime: time.Now().Add(time.Hour),
+	}, nil
+}
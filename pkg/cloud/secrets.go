@@ -0,0 +1,176 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Secret providers, see Secrets.
+const (
+	SecretProviderFile = "file"
+)
+
+// secretReference matches the ${secret:<name>} references of configuration values.
+var secretReference = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+
+// SecretProvider resolves the secrets referenced by configuration values.
+type SecretProvider interface {
+	// GetSecret returns the value of the named secret.
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// SecretProviderFactory creates the secret provider of the secrets configuration.
+type SecretProviderFactory func(config Secrets) (SecretProvider, error)
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProviderFactory{
+		SecretProviderFile: func(config Secrets) (SecretProvider, error) {
+			return NewFileSecretProvider(config.Path), nil
+		},
+	}
+)
+
+// RegisterSecretProvider makes a secret provider available to the secrets.provider configuration
+// key, e.g. a provider backed by a secret manager. A registered name replaces the previous one.
+func RegisterSecretProvider(name string, factory SecretProviderFactory) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[name] = factory
+}
+
+// NewSecretProvider creates the secret provider of the configuration, nil when no provider is configured.
+func NewSecretProvider(config Secrets) (SecretProvider, error) {
+	if config.Provider == "" {
+		return nil, nil
+	}
+	secretProvidersMu.RLock()
+	factory, ok := secretProviders[config.Provider]
+	secretProvidersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown secret provider: %s", config.Provider)
+	}
+	return factory(config)
+}
+
+// FileSecretProvider reads each secret from a file of a directory named after the secret,
+// the layout of mounted Kubernetes and Cloud Run secrets. Trailing line breaks are removed.
+type FileSecretProvider struct {
+	Dir string
+}
+
+func NewFileSecretProvider(dir string) *FileSecretProvider {
+	return &FileSecretProvider{Dir: dir}
+}
+
+func (p *FileSecretProvider) GetSecret(_ context.Context, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid secret name: %s", name)
+	}
+	data, err := os.ReadFile(filepath.Join(p.Dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// ResolveSecrets replaces the ${secret:<name>} references of the string values of the
+// configuration with the secrets of the provider. References without a provider are errors.
+// A *Config keeps the references of the resolved keys, so its hash doesn't depend on secrets.
+func ResolveSecrets(ctx context.Context, config interface{}, provider SecretProvider) error {
+	var errs []error
+	references := make(map[string]string)
+	resolve := func(path []string, value string) string {
+		resolved := secretReference.ReplaceAllStringFunc(value, func(reference string) string {
+			name := secretReference.FindStringSubmatch(reference)[1]
+			if provider == nil {
+				errs = append(errs, fmt.Errorf("secret %s is referenced but secrets.provider is not configured", name))
+				return reference
+			}
+			secret, err := provider.GetSecret(ctx, name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("secret %s: %w", name, err))
+				return reference
+			}
+			return secret
+		})
+		if resolved != value {
+			references[keyPath(path...)] = value
+		}
+		return resolved
+	}
+	resolveStrings(reflect.ValueOf(config), nil, resolve)
+	if c := asConfig(config); c != nil && len(references) > 0 {
+		if c.secretReferences == nil {
+			c.secretReferences = make(map[string]string)
+		}
+		maps.Copy(c.secretReferences, references)
+	}
+	return errors.Join(errs...)
+}
+
+// asConfig returns the *Config of a *Config or **Config, nil for other values.
+func asConfig(config interface{}) *Config {
+	switch c := config.(type) {
+	case *Config:
+		return c
+	case **Config:
+		return *c
+	}
+	return nil
+}
+
+// resolveStrings replaces the settable string values below the value, the key path of the
+// value is extended with the TOML key of struct fields, map keys and slice indexes.
+func resolveStrings(value reflect.Value, path []string, resolve func(path []string, value string) string) {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !value.IsNil() {
+			resolveStrings(value.Elem(), path, resolve)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if field := value.Type().Field(i); field.IsExported() {
+				resolveStrings(value.Field(i), append(path, tomlName(field)), resolve)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			resolveStrings(value.Index(i), append(path, strconv.Itoa(i)), resolve)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			_ = updateMapEntry(value, key, func(entry reflect.Value) error {
+				resolveStrings(entry, append(path, key.String()), resolve)
+				return nil
+			})
+		}
+	case reflect.String:
+		if value.CanSet() {
+			value.SetString(resolve(path, value.String()))
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpoolDeadLetterDir is the subdirectory of a spool directory receiving the messages whose
+// attempts are exhausted.
+const SpoolDeadLetterDir = "dead_letter"
+
+// DefaultSpoolPollInterval is the interval the spool directory is scanned at when
+// event_source.poll_interval_in_milliseconds isn't set.
+const DefaultSpoolPollInterval = time.Second
+
+// SpoolEventSource delivers each JSON file dropped into a directory as a message, the file
+// name without the extension is the message ID and the content the message data. A file is
+// removed once it's handled. Files should be written under another name, e.g. with a .tmp
+// extension, and renamed, so partially written files aren't delivered.
+type SpoolEventSource struct {
+	name        string
+	dir         string
+	interval    time.Duration
+	concurrency int
+
+	mu       sync.Mutex      // Guards handling.
+	handling map[string]bool // The names of the files being handled.
+}
+
+// NewSpoolEventSource creates the source of a directory, scanned at the interval, handling up to
+// concurrency files at a time. A concurrency below 1 handles one file at a time.
+func NewSpoolEventSource(name string, dir string, interval time.Duration, concurrency int) (*SpoolEventSource, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = DefaultSpoolPollInterval
+	}
+	return &SpoolEventSource{
+		name:        name,
+		dir:         dir,
+		interval:    interval,
+		concurrency: max(1, concurrency),
+		handling:    make(map[string]bool),
+	}, nil
+}
+
+func (s *SpoolEventSource) Name() string {
+	return s.name
+}
+
+// Receive scans the directory until the context is done, delivering the files in name order to
+// up to concurrency concurrent handlers. A file is delivered again by a later scan only once its
+// handler returned. It waits for the running handlers when the context is done.
+func (s *SpoolEventSource) Receive(ctx context.Context, handler MessageHandler) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	slots := make(chan struct{}, s.concurrency)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.scan(ctx, &wg, slots, handler); err != nil {
+			log.Printf("error scanning spool directory %s: %v", s.dir, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SpoolEventSource) scan(ctx context.Context, wg *sync.WaitGroup, slots chan struct{}, handler MessageHandler) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !s.startHandling(name) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			s.stopHandling(name)
+			return nil
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				<-slots
+				s.stopHandling(name)
+			}()
+			s.deliver(ctx, name, handler)
+		}()
+	}
+	return nil
+}
+
+// deliver hands a file to the handler and removes it once it's handled.
+func (s *SpoolEventSource) deliver(ctx context.Context, name string, handler MessageHandler) {
+	path := filepath.Join(s.dir, name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("error reading spooled file %s: %v", path, err)
+		return
+	}
+	msg := &Message{ID: strings.TrimSuffix(name, ".json"), Data: data}
+	if err = handler(ctx, msg); err != nil {
+		log.Printf("error handling spooled message %s: %v", msg.ID, err)
+		return
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("error removing spooled file %s: %v", path, err)
+	}
+}
+
+// startHandling marks a file as being handled, it returns false when it already is.
+func (s *SpoolEventSource) startHandling(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handling[name] {
+		return false
+	}
+	s.handling[name] = true
+	return true
+}
+
+func (s *SpoolEventSource) stopHandling(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.handling, name)
+}
+
+// DeadLetter writes the item to the dead_letter subdirectory of the spool directory.
+func (s *SpoolEventSource) DeadLetter(_ context.Context, item *WorkItem) error {
+	dir := filepath.Join(s.dir, SpoolDeadLetterDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, unsafeBucketCharacters.ReplaceAllString(item.ID, "_")+".json")
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -20,79 +20,174 @@ package cloud
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/bigquery"
 	"cloud.google.com/go/pubsub"
 	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
 	"google.golang.org/genai"
 )
 
 // ServiceClients is the state machine for the cloud clients.
 type ServiceClients struct {
-	StorageClient   *storage.Client                         // The Google Cloud Storage client.
-	PubsubClient    *pubsub.Client                          // The Google Cloud Pub/Sub client.
-	GenAIClient     *genai.Client                           // The Google Cloud Vertex AI client.
-	BiqQueryClient  *bigquery.Client                        // The Google Cloud BigQuery client.
-	PubSubListeners map[string]*PubSubListener              // A map of Pub/Sub listeners, keyed by subscription name.
-	EmbeddingModels map[string]*genai.Models                // A map of Vertex AI embedding models, keyed by model name.
+	StorageClient   *storage.Client                         // The Google Cloud Storage client, nil unless the storage backend is gcs.
+	ObjectStore     objectstore.ObjectStore                 // The object storage of the configured backend.
+	PubsubClient    *pubsub.Client                          // The Google Cloud Pub/Sub client, nil unless the event source is pubsub.
+	GenAIClient     *genai.Client                           // The Google Cloud Vertex AI client, nil when replaying a cassette.
+	BiqQueryClient  *bigquery.Client                        // The Google Cloud BigQuery client, nil when replaying a cassette.
+	Listeners       map[string]*Listener                    // A map of the listeners of the event source, keyed by subscription name.
+	EmbeddingModels map[string]EmbeddingModel               // A map of Vertex AI embedding models, keyed by model name.
 	AgentModels     map[string]*QuotaAwareGenerativeAIModel // A map of Vertex AI LLM models, keyed by model name.
+	DrainTimeout    time.Duration                           // The time Close waits for the running executions of the listeners.
+}
+
+// Drain stops all listeners concurrently and waits for their running executions until the
+// context is done. It returns the abandoned work items, keyed by subscription name.
+func (c *ServiceClients) Drain(ctx context.Context) map[string][]*WorkItem {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	abandoned := make(map[string][]*WorkItem)
+	for name, listener := range c.Listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if items := listener.Stop(ctx); len(items) > 0 {
+				mu.Lock()
+				abandoned[name] = items
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return abandoned
 }
 
 // Close A close method to ensure all clients are shut down,
 // these are handled using a closable context, but here for clean testing.
+// The listeners are drained for up to DrainTimeout before the clients are closed.
 func (c *ServiceClients) Close() {
-	_ = c.StorageClient.Close()
-	_ = c.PubsubClient.Close()
-	_ = c.BiqQueryClient.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), c.DrainTimeout)
+	defer cancel()
+	c.Drain(ctx)
+	_ = c.ObjectStore.Close()
+	if c.PubsubClient != nil {
+		_ = c.PubsubClient.Close()
+	}
+	if c.BiqQueryClient != nil {
+		_ = c.BiqQueryClient.Close()
+	}
+	for _, agent := range c.AgentModels {
+		agent.Close()
+	}
 }
 
 // NewCloudServiceClients A helper function for correctly initializing the Google Cloud Services based on the configuration.
 func NewCloudServiceClients(ctx context.Context, config *Config) (cloud *ServiceClients, err error) {
-	// Create a new Google Cloud Storage client.
-	sc, err := storage.NewClient(ctx)
+	// Create the object storage of the configured backend.
+	store, err := objectstore.New(ctx, config.Storage.Backend, config.Storage.LocalRoot)
 	if err != nil {
 		return nil, err
 	}
+	var sc *storage.Client
+	if gcsStore, ok := store.(*objectstore.GCSStore); ok {
+		sc = gcsStore.GetClient()
+	}
 
-	// Create a new Google Cloud Pub/Sub client.
-	pc, err := pubsub.NewClient(ctx, config.Application.GoogleProjectId)
-	if err != nil {
-		return nil, err
+	// Open the cassette recording or replaying the model interactions, a replay runs offline
+	// so the models are served by the cassette and no Google Cloud client is created.
+	var cassette *Cassette
+	if config.Cassette.Mode != "" {
+		cassette, err = LoadCassette(config.Cassette.Path, config.Cassette.Mode)
+		if err != nil {
+			return nil, err
+		}
+	}
+	replay := cassette != nil && cassette.GetMode() == CassetteModeReplay
+	if replay && config.EventSource.GetType() == EventSourcePubSub {
+		return nil, fmt.Errorf("the %s event source isn't available when replaying a cassette", EventSourcePubSub)
 	}
 
-	// Create a new Google Cloud Vertex AI client.
-	gc, err := genai.NewClient(ctx, &genai.ClientConfig{
-		Project:  config.Application.GoogleProjectId,
-		Location: "global",
-		Backend:  genai.BackendVertexAI,
-	})
-	if err != nil {
-		log.Printf("error creating genai client: %v", err)
-		return nil, err
+	// Create a new Google Cloud Pub/Sub client, the other event sources don't need one.
+	var pc *pubsub.Client
+	if config.EventSource.GetType() == EventSourcePubSub {
+		pc, err = pubsub.NewClient(ctx, config.Application.GoogleProjectId)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Create a new Google Cloud BigQuery client.
-	bc, err := bigquery.NewClient(ctx, config.Application.GoogleProjectId)
+	var gc *genai.Client
+	var bc *bigquery.Client
+	if !replay {
+		// Create a new Google Cloud Vertex AI client.
+		gc, err = genai.NewClient(ctx, &genai.ClientConfig{
+			Project:  config.Application.GoogleProjectId,
+			Location: "global",
+			Backend:  genai.BackendVertexAI,
+		})
+		if err != nil {
+			log.Printf("error creating genai client: %v", err)
+			return nil, err
+		}
+
+		// Create a new Google Cloud BigQuery client.
+		bc, err = bigquery.NewClient(ctx, config.Application.GoogleProjectId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The keys of the handled GCS notifications are shared by the listeners.
+	idempotency, err := NewIdempotencyStore(config, store)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create Pub/Sub listeners based on the configuration.
-	subscriptions := make(map[string]*PubSubListener)
+	// Create the listeners of the event source based on the configuration.
+	subscriptions := make(map[string]*Listener)
 	for sub := range config.TopicSubscriptions {
 		values := config.TopicSubscriptions[sub]
-		actual, err := NewPubSubListener(pc, values.Name, nil)
+		source, err := NewEventSource(config.EventSource, pc, values, config.Application.ThreadPoolSize)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("event source of %s: %w", sub, err)
 		}
+		actual := NewListener(source, nil)
+		actual.SetScratchConfig(config.GetScratchConfig())
+		queueDir := ""
+		if config.WorkQueue.Path != "" {
+			queueDir = filepath.Join(config.WorkQueue.Path, values.Name)
+		}
+		queue, err := NewWorkQueue(values.Name, queueDir)
+		if err != nil {
+			return nil, fmt.Errorf("work queue of %s: %w", sub, err)
+		}
+		actual.SetWorkQueue(queue, config.Application.ThreadPoolSize, config.WorkQueue.Retry)
+		actual.SetIdempotencyStore(idempotency, config.Idempotency.GetTTL())
 		subscriptions[sub] = actual
 	}
 
+	// Create the coordinator of the quota shared with concurrent jobs.
+	coordinator, err := NewQuotaCoordinator(config.Quota)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create Vertex AI embedding models based on the configuration.
-	embeddingModels := make(map[string]*genai.Models)
+	embeddingModels := make(map[string]EmbeddingModel)
 	for emb := range config.EmbeddingModels {
-		embeddingModels[emb] = gc.Models
+		switch {
+		case replay:
+			embeddingModels[emb] = cassette.EmbeddingModel(nil)
+		case cassette != nil:
+			embeddingModels[emb] = cassette.EmbeddingModel(gc.Models)
+		default:
+			embeddingModels[emb] = gc.Models
+		}
 	}
 
 	// Create Vertex AI LLM models based on the configuration.
@@ -109,19 +204,48 @@ func NewCloudServiceClients(ctx context.Context, config *Config) (cloud *Service
 			ResponseMIMEType:  values.OutputFormat,
 			Tools:             []*genai.Tool{},
 		}
-		wrappedAgent := NewQuotaAwareModel(generateContentConfig, values.Model, gc.Models, values.RateLimit)
+		var model GenerativeModel
+		if replay {
+			model = cassette.GenerativeModel(nil)
+		} else {
+			model, err = NewGenerativeModel(values, gc, store)
+			if err != nil {
+				return nil, fmt.Errorf("agent model %s: %w", am, err)
+			}
+			if cassette != nil {
+				model = cassette.GenerativeModel(model)
+			}
+		}
+		wrappedAgent := NewQuotaAwareModel(generateContentConfig, values.Model, model, values.RequestsPerMinute, values.TokensPerMinute)
+		if coordinator != nil {
+			wrappedAgent.RateLimit.SetCoordinator(coordinator)
+		}
 		agentModels[am] = wrappedAgent
 	}
 
+	// Link the fallback models of the retry policies.
+	for am, agent := range agentModels {
+		values := config.AgentModels[am]
+		if err = values.Retry.Validate(config.AgentModels); err != nil {
+			return nil, fmt.Errorf("agent model %s: %w", am, err)
+		}
+		agent.RetryPolicy = values.Retry
+		if values.Retry.FallbackModel != "" {
+			agent.Fallback = agentModels[values.Retry.FallbackModel]
+		}
+	}
+
 	// Create a new ServiceClients instance with all the initialized clients.
 	cloud = &ServiceClients{
 		StorageClient:   sc,
+		ObjectStore:     store,
 		PubsubClient:    pc,
 		GenAIClient:     gc,
 		BiqQueryClient:  bc,
-		PubSubListeners: subscriptions,
+		Listeners:       subscriptions,
 		EmbeddingModels: embeddingModels,
 		AgentModels:     agentModels,
+		DrainTimeout:    config.GetDrainTimeout(),
 	}
 
 	return cloud, err
@@ -16,46 +16,98 @@
 
 package cloud
 
-import "text/template"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"text/template"
+)
 
+// TemplateService holds the parsed prompt templates of the configuration, the templates
+// are replaced as a whole on update so readers never see a partial update.
 type TemplateService struct {
-	config              *Config
+	templates atomic.Pointer[templateSet]
+}
+
+type templateSet struct {
 	templateByMediaType map[string]*PromptTemplate
 	contentTypeTemplate *template.Template
 }
 
+// NewTemplateService creates the templates of the config, invalid templates are logged and skipped.
 func NewTemplateService(config *Config) *TemplateService {
-	out := &TemplateService{
-		config: config,
+	out := &TemplateService{}
+	out.templates.Store(&templateSet{templateByMediaType: make(map[string]*PromptTemplate)})
+	if err := out.UpdateTemplates(config); err != nil {
+		log.Printf("invalid prompt templates: %v", err)
+	}
+	return out
+}
+
+// NewTemplateServiceFromSnapshot creates the templates of the current configuration of the
+// snapshot and updates them each time the snapshot swaps in a new configuration.
+func NewTemplateServiceFromSnapshot(snapshot *ConfigSnapshot) *TemplateService {
+	out := NewTemplateService(snapshot.Get())
+	snapshot.AddListener(func(config *Config) {
+		if err := out.UpdateTemplates(config); err != nil {
+			log.Printf("invalid prompt templates, keeping the previous ones: %v", err)
+		}
+	})
+	// A configuration swapped in before the listener was added is applied as well
+	if err := out.UpdateTemplates(snapshot.Get()); err != nil {
+		log.Printf("invalid prompt templates: %v", err)
 	}
-	out.UpdateTemplates()
 	return out
 }
 
 func (t *TemplateService) GetTemplateBy(mediaType string) *PromptTemplate {
-	return t.templateByMediaType[mediaType]
+	return t.templates.Load().templateByMediaType[mediaType]
 }
 
 func (t *TemplateService) GetContentTypeTemplate() *template.Template {
-	return t.contentTypeTemplate
+	return t.templates.Load().contentTypeTemplate
 }
 
-func (t *TemplateService) UpdateTemplates() {
-	t.templateByMediaType = GetTemplateByMediaType(t.config)
-	t.contentTypeTemplate = GetContentTypeTemplate(t.config)
+// UpdateTemplates parses the templates of the config. The valid templates replace the current
+// ones, the current templates are kept for the invalid ones.
+func (t *TemplateService) UpdateTemplates(config *Config) error {
+	current := t.templates.Load()
+	templateByMediaType, templateErr := GetTemplateByMediaType(config)
+	for mediaType, currentTemplate := range current.templateByMediaType {
+		if _, ok := templateByMediaType[mediaType]; !ok {
+			if _, configured := config.PromptTemplates[mediaType]; configured {
+				templateByMediaType[mediaType] = currentTemplate
+			}
+		}
+	}
+	contentTypeTemplate, contentTypeErr := GetContentTypeTemplate(config)
+	if contentTypeErr != nil {
+		contentTypeTemplate = current.contentTypeTemplate
+	}
+	t.templates.Store(&templateSet{
+		templateByMediaType: templateByMediaType,
+		contentTypeTemplate: contentTypeTemplate,
+	})
+	return errors.Join(templateErr, contentTypeErr)
 }
 
-func GetTemplateByMediaType(config *Config) map[string]*PromptTemplate {
+// GetTemplateByMediaType parses the prompt templates of the config, returning the valid
+// templates and an error for each invalid one.
+func GetTemplateByMediaType(config *Config) (map[string]*PromptTemplate, error) {
 	templateByMediaType := make(map[string]*PromptTemplate)
+	var errs []error
 	for mediaType := range config.PromptTemplates {
 		systemInstruction := config.PromptTemplates[mediaType].SystemInstructions
 		summaryTemplate, err := template.New("summary-template").Parse(config.PromptTemplates[mediaType].SummaryPrompt)
 		if err != nil {
-			panic(err)
+			errs = append(errs, fmt.Errorf("%s: %w", keyPath("prompt_templates", mediaType, "summary"), err))
+			continue
 		}
 		segmentTemplate, err := template.New("segment-template").Parse(config.PromptTemplates[mediaType].SegmentPrompt)
 		if err != nil {
-			panic(err)
+			errs = append(errs, fmt.Errorf("%s: %w", keyPath("prompt_templates", mediaType, "segment"), err))
+			continue
 		}
 		templateByMediaType[mediaType] = &PromptTemplate{
 			SystemInstructions: systemInstruction,
@@ -63,13 +115,13 @@ func GetTemplateByMediaType(config *Config) map[string]*PromptTemplate {
 			SegmentPrompt:      segmentTemplate,
 		}
 	}
-	return templateByMediaType
+	return templateByMediaType, errors.Join(errs...)
 }
 
-func GetContentTypeTemplate(config *Config) *template.Template {
+func GetContentTypeTemplate(config *Config) (*template.Template, error) {
 	contentTypeTemplate, err := template.New("content-type-template").Parse(config.ContentType.PromptTemplate)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("content_type.prompt_template: %w", err)
 	}
-	return contentTypeTemplate
+	return contentTypeTemplate, nil
 }
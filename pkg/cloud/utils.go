@@ -20,15 +20,16 @@ package cloud
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 
-	"github.com/BurntSushi/toml"
 	"google.golang.org/genai"
 )
 
@@ -49,41 +50,115 @@ func fileExists(in string) bool {
 }
 
 // LoadConfig The configuration loader, a hierarchical loader that allows environment overrides.
+// The environment variable overrides are applied and the secret references resolved last, see
+// ApplyEnvOverrides. Like the validate_config tool, keys of the files or the overrides that don't
+// match a configuration field fail the startup, see DecodeConfigFile.
 func LoadConfig(baseConfig interface{}) {
 	configurationFilePrefix := os.Getenv(EnvConfigFilePrefix)
-	if len(configurationFilePrefix) > 0 && !strings.HasSuffix(configurationFilePrefix, string(os.PathSeparator)) {
-		configurationFilePrefix = configurationFilePrefix + string(os.PathSeparator)
-	}
-
 	runtimeEnvironment := os.Getenv(EnvConfigRuntime)
-	if runtimeEnvironment == "" {
-		runtimeEnvironment = "test"
-	}
+	baseConfigFileName, envConfigFileName := ConfigFileNames(configurationFilePrefix, runtimeEnvironment)
 
 	// Read Base Config
-	baseConfigFileName := configurationFilePrefix + ConfigFileBaseName + ConfigFileExtension
 	fmt.Printf("Base Configuration File: %s\n", baseConfigFileName)
 
 	// Override with environment config
-	envConfigFileName := configurationFilePrefix + ConfigFileBaseName + ConfigSeparator + runtimeEnvironment + ConfigFileExtension
 	fmt.Printf("Environment Configuration File: %s\n", envConfigFileName)
 
 	if fileExists(baseConfigFileName) {
-		_, err := toml.DecodeFile(baseConfigFileName, baseConfig)
+		err := DecodeConfigFile(baseConfigFileName, baseConfig)
 		if err != nil {
 			log.Fatalf("failed to decode base configuration file %s with error: %s", baseConfigFileName, err)
 		}
 	}
 
 	if fileExists(envConfigFileName) {
-		_, err := toml.DecodeFile(envConfigFileName, baseConfig)
+		err := DecodeConfigFile(envConfigFileName, baseConfig)
 		if err != nil {
 			log.Fatalf("failed to decode environment configuration file: %s with error: %s", envConfigFileName, err)
 		}
 	}
+
+	err := applyOverrides(baseConfig)
+	if err != nil {
+		log.Fatalf("failed to apply configuration overrides with error: %s", err)
+	}
+}
+
+// ConfigFileNames returns the base and the environment configuration file names of a directory
+// and a runtime environment, the runtime defaults to "test".
+func ConfigFileNames(configurationFilePrefix string, runtimeEnvironment string) (string, string) {
+	if len(configurationFilePrefix) > 0 && !strings.HasSuffix(configurationFilePrefix, string(os.PathSeparator)) {
+		configurationFilePrefix = configurationFilePrefix + string(os.PathSeparator)
+	}
+	if runtimeEnvironment == "" {
+		runtimeEnvironment = "test"
+	}
+	baseConfigFileName := configurationFilePrefix + ConfigFileBaseName + ConfigFileExtension
+	envConfigFileName := configurationFilePrefix + ConfigFileBaseName + ConfigSeparator + runtimeEnvironment + ConfigFileExtension
+	return baseConfigFileName, envConfigFileName
+}
+
+// LoadConfigFiles strictly decodes the base and the environment configuration files of a directory,
+// then applies the environment variable overrides and resolves the secret references, returning the
+// undecoded keys of both files and the overrides. A missing environment file is skipped.
+func LoadConfigFiles(dir string, runtimeEnvironment string, baseConfig interface{}) error {
+	baseConfigFileName, envConfigFileName := ConfigFileNames(dir, runtimeEnvironment)
+	if !fileExists(baseConfigFileName) {
+		return fmt.Errorf("base configuration file not found: %s", baseConfigFileName)
+	}
+	baseErr := DecodeConfigFile(baseConfigFileName, baseConfig)
+	if baseErr != nil && !errors.Is(baseErr, ErrUndecodedKey) {
+		return baseErr
+	}
+	var envErr error
+	if fileExists(envConfigFileName) {
+		envErr = DecodeConfigFile(envConfigFileName, baseConfig)
+		if envErr != nil && !errors.Is(envErr, ErrUndecodedKey) {
+			return envErr
+		}
+	}
+	overrideErr := applyOverrides(baseConfig)
+	if overrideErr != nil && !errors.Is(overrideErr, ErrUndecodedKey) {
+		return overrideErr
+	}
+	return errors.Join(baseErr, envErr, overrideErr)
 }
 
-// GenerateMultiModalResponse A GenAI helper function for executing multi-modal requests with a retry limit.
+// applyOverrides applies the environment variable overrides of the process and resolves the secret
+// references with the secret provider of the configuration. Only the ErrUndecodedKey errors of
+// unknown override keys are returned when the other overrides and the secrets are valid.
+func applyOverrides(baseConfig interface{}) error {
+	var undecoded, errs []error
+	if err := ApplyEnvOverrides(baseConfig, os.Environ()); err != nil {
+		for _, e := range unjoin(err) {
+			if errors.Is(e, ErrUndecodedKey) {
+				undecoded = append(undecoded, e)
+			} else {
+				errs = append(errs, e)
+			}
+		}
+	}
+
+	var secrets Secrets
+	if config := asConfig(baseConfig); config != nil {
+		secrets = config.Secrets
+	}
+	provider, err := NewSecretProvider(secrets)
+	if err != nil {
+		errs = append(errs, err)
+	} else if err = ResolveSecrets(context.Background(), baseConfig, provider); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return errors.Join(undecoded...)
+}
+
+// GenerateMultiModalResponse A GenAI helper function for executing multi-modal requests with the
+// retry policy of the model. Retries and fallbacks are counted by the retry counter, tagged by reason.
+// The fallback model isn't used with cached content since caches belong to a model.
 func GenerateMultiModalResponse(
 	ctx context.Context,
 	inputTokenCounter metric.Int64Counter,
@@ -95,16 +170,70 @@ func GenerateMultiModalResponse(
 	cacheName string,
 	contents []*genai.Content,
 	outputSchema *genai.Schema) (value string, err error) {
-	resp, err := model.GenerateContent(ctx, systemInstruction, cacheName, contents, outputSchema)
-	inputTokenCounter.Add(ctx, int64(resp.UsageMetadata.PromptTokenCount))
-	outputTokenCounter.Add(ctx, int64(resp.UsageMetadata.CandidatesTokenCount))
-	if err != nil {
-		if tryCount < MaxRetries {
-			retryCounter.Add(ctx, 1)
-			return GenerateMultiModalResponse(ctx, inputTokenCounter, outputTokenCounter, retryCounter, tryCount+1, model, systemInstruction, cacheName, contents, outputSchema)
-		} else {
+	value, err = generateWithRetries(ctx, inputTokenCounter, outputTokenCounter, retryCounter, tryCount, model, systemInstruction, cacheName, contents, outputSchema)
+	if err == nil || model.Fallback == nil || cacheName != "" {
+		return value, err
+	}
+	// The fallback of the fallback model isn't followed, avoiding cycles.
+	log.Printf("Generation failed with %s, falling back to %s: %v", model.ModelName, model.Fallback.ModelName, err)
+	retryCounter.Add(ctx, 1, retryAttributes(model, ClassifyError(err), "fallback"))
+	return generateWithRetries(ctx, inputTokenCounter, outputTokenCounter, retryCounter, 0, model.Fallback, systemInstruction, cacheName, contents, outputSchema)
+}
+
+func generateWithRetries(
+	ctx context.Context,
+	inputTokenCounter metric.Int64Counter,
+	outputTokenCounter metric.Int64Counter,
+	retryCounter metric.Int64Counter,
+	tryCount int,
+	model *QuotaAwareGenerativeAIModel,
+	systemInstruction string,
+	cacheName string,
+	contents []*genai.Content,
+	outputSchema *genai.Schema) (value string, err error) {
+	policy := model.RetryPolicy
+	for attempt := tryCount; ; attempt++ {
+		value, err = generateOnce(ctx, inputTokenCounter, outputTokenCounter, model, systemInstruction, cacheName, contents, outputSchema)
+		if err == nil {
+			return value, nil
+		}
+		class := ClassifyError(err)
+		if !policy.Retries(class) {
+			retryCounter.Add(ctx, 1, retryAttributes(model, class, "not_retryable"))
 			return "", err
 		}
+		if attempt+1 >= policy.GetMaxAttempts() {
+			retryCounter.Add(ctx, 1, retryAttributes(model, class, "exhausted"))
+			return "", err
+		}
+		log.Printf("Generation failed with %s (%s), retrying: %v", model.ModelName, class, err)
+		retryCounter.Add(ctx, 1, retryAttributes(model, class, "retry"))
+		if sleepErr := sleep(ctx, policy.Backoff(attempt-tryCount)); sleepErr != nil {
+			return "", errors.Join(err, sleepErr)
+		}
+	}
+}
+
+// generateOnce executes a single request, classifying blocked, empty and invalid JSON responses as errors.
+func generateOnce(
+	ctx context.Context,
+	inputTokenCounter metric.Int64Counter,
+	outputTokenCounter metric.Int64Counter,
+	model *QuotaAwareGenerativeAIModel,
+	systemInstruction string,
+	cacheName string,
+	contents []*genai.Content,
+	outputSchema *genai.Schema) (value string, err error) {
+	resp, err := model.GenerateContent(ctx, systemInstruction, cacheName, contents, outputSchema)
+	if err != nil {
+		return "", err
+	}
+	if resp.UsageMetadata != nil {
+		inputTokenCounter.Add(ctx, int64(resp.UsageMetadata.PromptTokenCount))
+		outputTokenCounter.Add(ctx, int64(resp.UsageMetadata.CandidatesTokenCount))
+	}
+	if IsBlocked(resp) {
+		return "", &GenerationError{Class: ErrorClassSafety, Err: errors.New("response blocked by safety filters")}
 	}
 	for _, candidate := range resp.Candidates {
 		if candidate.Content != nil {
@@ -114,17 +243,21 @@ func GenerateMultiModalResponse(
 		}
 	}
 	if len(value) == 0 {
-		log.Println("Empty response from model, retrying...")
-		if tryCount < MaxRetries {
-			retryCounter.Add(ctx, 1)
-			return GenerateMultiModalResponse(ctx, inputTokenCounter, outputTokenCounter, retryCounter, tryCount+1, model, systemInstruction, cacheName, contents, outputSchema)
-		} else {
-			return "", errors.New("no candidates returned from model after retries")
-		}
+		return "", &GenerationError{Class: ErrorClassEmpty, Err: errors.New("no candidates returned from model")}
+	}
+	if outputSchema != nil && !json.Valid([]byte(value)) {
+		return "", &GenerationError{Class: ErrorClassInvalidJSON, Err: errors.New("response is not valid JSON")}
 	}
 	return value, nil
 }
 
+func retryAttributes(model *QuotaAwareGenerativeAIModel, reason string, outcome string) metric.AddOption {
+	return metric.WithAttributes(
+		attribute.String("model", model.ModelName),
+		attribute.String("reason", reason),
+		attribute.String("outcome", outcome))
+}
+
 // NewTextPart A delegate method for creating text parts
 func NewTextPart(in string) []*genai.Content {
 	return genai.Text(in)
@@ -0,0 +1,318 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// WorkItem is a received message held by a WorkQueue until its command completes.
+type WorkItem struct {
+	ID         string            `json:"id"`                   // The message ID, duplicate deliveries are queued once.
+	Data       string            `json:"data"`                 // The message data.
+	Attributes map[string]string `json:"attributes,omitempty"` // The message attributes.
+	Received   time.Time         `json:"received"`             // The time the message was first received.
+	Attempts   int               `json:"attempts"`             // The number of failed executions.
+	NotBefore  time.Time         `json:"not_before"`           // The time of the next attempt.
+	LastError  string            `json:"last_error,omitempty"` // The errors of the last failed execution.
+}
+
+// WorkQueue holds the received messages until their command completes. When the queue has
+// a directory each item is written to a JSON file of the directory, so the items of a crashed
+// or restarted process are executed again when the queue is reopened.
+type WorkQueue struct {
+	mu           sync.Mutex
+	name         string
+	dir          string
+	items        map[string]*WorkItem
+	inFlight     map[string]bool
+	done         map[string]chan struct{}
+	changed      chan struct{}
+	registration metric.Registration
+}
+
+// NewWorkQueue opens the queue of a directory, loading its items, or creates an in-memory
+// queue when the directory is empty.
+func NewWorkQueue(name string, dir string) (*WorkQueue, error) {
+	q := &WorkQueue{
+		name:     name,
+		dir:      dir,
+		items:    make(map[string]*WorkItem),
+		inFlight: make(map[string]bool),
+		done:     make(map[string]chan struct{}),
+		changed:  make(chan struct{}),
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		if err := q.load(); err != nil {
+			return nil, err
+		}
+	}
+	q.registerGauges()
+	return q, nil
+}
+
+func (q *WorkQueue) load() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(q.dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		item := &WorkItem{}
+		if err = json.Unmarshal(data, item); err != nil {
+			return fmt.Errorf("invalid work item %s: %w", entry.Name(), err)
+		}
+		q.items[item.ID] = item
+	}
+	if len(q.items) > 0 {
+		log.Printf("work queue %s: loaded %d pending item(s)", q.name, len(q.items))
+	}
+	return nil
+}
+
+// IsPersistent returns true when the items are written to the directory of the queue.
+func (q *WorkQueue) IsPersistent() bool {
+	return q.dir != ""
+}
+
+func (q *WorkQueue) path(id string) string {
+	return filepath.Join(q.dir, unsafeBucketCharacters.ReplaceAllString(id, "_")+".json")
+}
+
+// persist writes the item to the directory of the queue, the caller holds the lock.
+func (q *WorkQueue) persist(item *WorkItem) error {
+	if q.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	path := q.path(item.ID)
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// notify wakes up the callers of Next, the caller holds the lock.
+func (q *WorkQueue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// Enqueue persists the item, an item with the ID of a queued item is ignored.
+func (q *WorkQueue) Enqueue(item *WorkItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.items[item.ID]; ok {
+		return nil
+	}
+	if item.Received.IsZero() {
+		item.Received = time.Now()
+	}
+	if err := q.persist(item); err != nil {
+		return err
+	}
+	q.items[item.ID] = item
+	q.notify()
+	return nil
+}
+
+// Next blocks until an item is due or the context is done, returning the oldest due item.
+// The item is in flight until it is completed or retried.
+func (q *WorkQueue) Next(ctx context.Context) (*WorkItem, error) {
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		var next *WorkItem
+		var wake time.Time
+		for id, item := range q.items {
+			if q.inFlight[id] {
+				continue
+			}
+			if item.NotBefore.After(now) {
+				if wake.IsZero() || item.NotBefore.Before(wake) {
+					wake = item.NotBefore
+				}
+				continue
+			}
+			if next == nil || item.Received.Before(next.Received) || (item.Received.Equal(next.Received) && item.ID < next.ID) {
+				next = item
+			}
+		}
+		if next != nil {
+			q.inFlight[next.ID] = true
+			q.mu.Unlock()
+			return next, nil
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if !wake.IsZero() {
+			timer = time.NewTimer(time.Until(wake))
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil, err
+		case <-changed:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Done returns a channel closed when the item is completed, i.e. removed from the queue.
+// The channel of an item that isn't queued is already closed.
+func (q *WorkQueue) Done(id string) <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	done, ok := q.done[id]
+	if !ok {
+		done = make(chan struct{})
+		if _, queued := q.items[id]; queued {
+			q.done[id] = done
+		} else {
+			close(done)
+		}
+	}
+	return done
+}
+
+// Complete removes an item from the queue.
+func (q *WorkQueue) Complete(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.items, id)
+	delete(q.inFlight, id)
+	if done, ok := q.done[id]; ok {
+		close(done)
+		delete(q.done, id)
+	}
+	q.notify()
+	if q.dir == "" {
+		return nil
+	}
+	err := os.Remove(q.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Retry records a failed attempt of an in flight item and schedules the next attempt.
+func (q *WorkQueue) Retry(item *WorkItem, notBefore time.Time, cause error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	item.Attempts++
+	item.NotBefore = notBefore
+	if cause != nil {
+		item.LastError = cause.Error()
+	}
+	delete(q.inFlight, item.ID)
+	q.notify()
+	return q.persist(item)
+}
+
+// Depth returns the number of queued items, including the ones in flight.
+func (q *WorkQueue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// OldestAge returns the time since the oldest queued item was received, 0 when the queue is empty.
+func (q *WorkQueue) OldestAge() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var oldest time.Time
+	for _, item := range q.items {
+		if oldest.IsZero() || item.Received.Before(oldest) {
+			oldest = item.Received
+		}
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return time.Since(oldest)
+}
+
+func (q *WorkQueue) registerGauges() {
+	meter := otel.Meter("github.com/GoogleCloudPlatform/media-search-solution")
+	depthGauge, err := meter.Int64ObservableGauge("work_queue.depth")
+	if err != nil {
+		log.Printf("error creating work queue depth gauge: %s\n", q.name)
+		return
+	}
+	ageGauge, err := meter.Float64ObservableGauge("work_queue.oldest_age_seconds")
+	if err != nil {
+		log.Printf("error creating work queue age gauge: %s\n", q.name)
+		return
+	}
+	attributes := metric.WithAttributes(attribute.String("queue", q.name))
+	q.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(depthGauge, int64(q.Depth()), attributes)
+		o.ObserveFloat64(ageGauge, q.OldestAge().Seconds(), attributes)
+		return nil
+	}, depthGauge, ageGauge)
+	if err != nil {
+		log.Printf("error registering work queue gauges: %s\n", q.name)
+	}
+}
+
+// Close unregisters the gauges of the queue, the queued items are kept in its directory.
+func (q *WorkQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.registration == nil {
+		return
+	}
+	if err := q.registration.Unregister(); err != nil {
+		log.Printf("error unregistering work queue gauges: %s: %v\n", q.name, err)
+	}
+	q.registration = nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// DeadLetterFunc publishes an item whose attempts are exhausted.
+type DeadLetterFunc func(ctx context.Context, item *WorkItem) error
+
+// WorkerPool executes a command for the items of a work queue with a bounded number of
+// workers. A failed item is retried with the backoff of the retry policy, an item whose
+// attempts are exhausted is handed to the dead letter function and removed from the queue.
+// When the command is resumable, an item with a checkpoint is resumed from it.
+type WorkerPool struct {
+	queue        *WorkQueue
+	command      cor.Command
+	size         int
+	retry        RetryPolicy
+	scratch      cor.ScratchConfig
+	deadLetter   DeadLetterFunc
+	retryCounter metric.Int64Counter
+
+	mu       sync.Mutex           // Guards running.
+	running  map[string]*WorkItem // The items being executed, keyed by ID.
+	stop     chan struct{}        // Closed when the pool is drained.
+	stopOnce sync.Once
+	done     chan struct{} // Closed when Run returns.
+}
+
+// NewWorkerPool creates a pool of size workers, a size below 1 runs a single worker.
+func NewWorkerPool(queue *WorkQueue, command cor.Command, size int, retry RetryPolicy) *WorkerPool {
+	meter := otel.Meter("github.com/GoogleCloudPlatform/media-search-solution")
+	retryCounter, _ := meter.Int64Counter("work_queue.retry")
+	return &WorkerPool{
+		queue:        queue,
+		command:      command,
+		size:         max(1, size),
+		retry:        retry,
+		retryCounter: retryCounter,
+		running:      make(map[string]*WorkItem),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// SetScratchConfig sets the scratch directory configuration of the executions.
+func (p *WorkerPool) SetScratchConfig(config cor.ScratchConfig) {
+	p.scratch = config
+}
+
+// SetDeadLetter sets the function receiving the items whose attempts are exhausted,
+// without one the items are logged and dropped.
+func (p *WorkerPool) SetDeadLetter(deadLetter DeadLetterFunc) {
+	p.deadLetter = deadLetter
+}
+
+// Run executes the queued items until the context is done or the pool is drained, then waits
+// for the running executions. The executions are cancelled with the context.
+func (p *WorkerPool) Run(ctx context.Context) {
+	defer close(p.done)
+
+	// Workers stop taking items when the pool is drained, the running executions continue
+	nextCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stop:
+			cancel()
+		case <-nextCtx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range p.size {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, err := p.queue.Next(nextCtx)
+				if err != nil {
+					return
+				}
+				p.execute(ctx, item)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Drain stops taking queued items and waits for the running executions until the context is
+// done, returning the items still running at that time. The remaining items stay in the queue.
+func (p *WorkerPool) Drain(ctx context.Context) []*WorkItem {
+	p.stopOnce.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	abandoned := make([]*WorkItem, 0, len(p.running))
+	for _, item := range p.running {
+		abandoned = append(abandoned, item)
+	}
+	sort.Slice(abandoned, func(i, j int) bool { return abandoned[i].ID < abandoned[j].ID })
+	return abandoned
+}
+
+// execute runs the command for an item, then completes, retries or dead letters it.
+func (p *WorkerPool) execute(ctx context.Context, item *WorkItem) {
+	tracer := otel.Tracer("message-listener")
+	spanCtx, span := tracer.Start(ctx, "process-message")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("msg", item.Data),
+		attribute.String("queue", p.queue.name),
+		attribute.Int("attempt", item.Attempts+1))
+
+	// Create a new chain context.
+	chainCtx := cor.NewBaseContextWithScratch(p.scratch)
+	chainCtx.SetContext(spanCtx)
+	chainCtx.Add(cor.CtxIn, item.Data)
+	chainCtx.Add(GetPubSubAttributesName(), item.Attributes)
+	cor.Set(chainCtx, cor.CheckpointIDKey, item.ID)
+
+	p.mu.Lock()
+	p.running[item.ID] = item
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.running, item.ID)
+		p.mu.Unlock()
+	}()
+
+	// Execute the command, resuming from the checkpoint of the item when a previous attempt or a
+	// restart left one. The context is closed to remove the temp files and scratch directory even
+	// if the command panics.
+	cor.RunOrResume(p.command, chainCtx)
+
+	// A cancelled execution isn't a failed attempt, the item stays in the queue.
+	if ctx.Err() != nil {
+		span.SetStatus(codes.Error, "cancelled")
+		log.Printf("work item %s cancelled: %v", item.ID, ctx.Err())
+		return
+	}
+
+	if !chainCtx.HasErrors() {
+		span.SetStatus(codes.Ok, "success")
+		p.complete(ctx, item)
+		return
+	}
+
+	span.SetStatus(codes.Error, "failed")
+	var errs []error
+	for _, e := range chainCtx.GetErrors() {
+		log.Printf("error executing chain: %v", e)
+		errs = append(errs, e)
+	}
+	cause := errors.Join(errs...)
+
+	if item.Attempts+1 < p.retry.GetMaxAttempts() {
+		p.retryCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("queue", p.queue.name), attribute.String("outcome", "retry")))
+		if err := p.queue.Retry(item, time.Now().Add(p.retry.Backoff(item.Attempts)), cause); err != nil {
+			log.Printf("error scheduling the retry of work item %s: %v", item.ID, err)
+		}
+		return
+	}
+
+	// The attempts are exhausted, the item is dead lettered and removed from the queue.
+	p.retryCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("queue", p.queue.name), attribute.String("outcome", "dead_letter")))
+	deadItem := *item
+	deadItem.Attempts++
+	deadItem.LastError = cause.Error()
+	if p.deadLetter == nil {
+		log.Printf("dropping work item %s after %d attempt(s), no dead letter topic: %v", item.ID, deadItem.Attempts, cause)
+	} else if err := p.deadLetter(ctx, &deadItem); err != nil {
+		// The item is kept in the queue and executed again, rather than losing it.
+		log.Printf("error dead lettering work item %s: %v", item.ID, err)
+		if err = p.queue.Retry(item, time.Now().Add(p.retry.Backoff(item.Attempts)), cause); err != nil {
+			log.Printf("error scheduling the retry of work item %s: %v", item.ID, err)
+		}
+		return
+	}
+	p.complete(ctx, item)
+}
+
+// complete removes a completed or dead lettered item from the queue along with its checkpoint,
+// so a new delivery of the message isn't resumed from it.
+func (p *WorkerPool) complete(ctx context.Context, item *WorkItem) {
+	if resumable, ok := p.command.(cor.Resumable); ok {
+		if err := resumable.DeleteCheckpoint(ctx, item.ID); err != nil {
+			log.Printf("error deleting the checkpoint of work item %s: %v", item.ID, err)
+		}
+	}
+	if err := p.queue.Complete(item.ID); err != nil {
+		log.Printf("error completing work item %s: %v", item.ID, err)
+	}
+}
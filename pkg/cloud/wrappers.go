@@ -19,33 +19,49 @@ package cloud
 
 import (
 	"context"
-	"errors"
 	"log"
-	"time"
 
-	"golang.org/x/time/rate"
 	"google.golang.org/genai"
 )
 
-// QuotaAwareGenerativeAIModel wraps a genai.GenerativeModel with rate limiting.
+// QuotaAwareGenerativeAIModel wraps a GenerativeModel with rate limiting.
 type QuotaAwareGenerativeAIModel struct {
 	GenerativeContentConfig *genai.GenerateContentConfig // The configuration for LLM content genration.
 	ModelName               string
-	ModelHandle             *genai.Models
-	RateLimit               rate.Limiter // The rate limiter for the LLM.
+	Model                   GenerativeModel              // The provider of the LLM.
+	RateLimit               *AdaptiveRateLimiter         // The rate limiter for the LLM.
+	RetryPolicy             RetryPolicy                  // The retry policy of GenerateMultiModalResponse.
+	Fallback                *QuotaAwareGenerativeAIModel // The model used when the retries are exhausted.
 }
 
-// NewQuotaAwareModel creates a new QuotaAwareGenerativeAIModel with the given rate limit.
-func NewQuotaAwareModel(wrapped *genai.GenerateContentConfig, modelName string, modelHandle *genai.Models, requestsPerSecond int) *QuotaAwareGenerativeAIModel {
+// NewQuotaAwareModel creates a new QuotaAwareGenerativeAIModel with the given requests
+// and tokens per minute budgets.
+func NewQuotaAwareModel(wrapped *genai.GenerateContentConfig, modelName string, model GenerativeModel, requestsPerMinute int, tokensPerMinute int) *QuotaAwareGenerativeAIModel {
 	return &QuotaAwareGenerativeAIModel{
 		GenerativeContentConfig: wrapped,
 		ModelName:               modelName,
-		ModelHandle:             modelHandle,
-		RateLimit:               *rate.NewLimiter(rate.Every(time.Second/1), requestsPerSecond),
+		Model:                   model,
+		RateLimit:               NewAdaptiveRateLimiter(modelName, requestsPerMinute, tokensPerMinute),
 	}
 }
 
-// GenerateContent generates content using the wrapped LLM with rate limiting.
+// SetRateLimit replaces the rate limiter of the model, the gauges of the previous limiter are unregistered.
+func (q *QuotaAwareGenerativeAIModel) SetRateLimit(limiter *AdaptiveRateLimiter) {
+	if q.RateLimit != nil && q.RateLimit != limiter {
+		q.RateLimit.Close()
+	}
+	q.RateLimit = limiter
+}
+
+// Close unregisters the gauges of the rate limiter of the model.
+func (q *QuotaAwareGenerativeAIModel) Close() {
+	if q.RateLimit != nil {
+		q.RateLimit.Close()
+	}
+}
+
+// GenerateContent generates content using the wrapped LLM, waiting for the rate limiter.
+// Requests rejected for exhausted quota reduce the rate, retries are left to the retry policy.
 func (q *QuotaAwareGenerativeAIModel) GenerateContent(ctx context.Context, systemInstruction string, cacheName string, contents []*genai.Content, outputSchema *genai.Schema) (resp *genai.GenerateContentResponse, err error) {
 	// Create a copy of the generative content config to avoid modifying the original.
 	config := *q.GenerativeContentConfig
@@ -65,32 +81,28 @@ func (q *QuotaAwareGenerativeAIModel) GenerateContent(ctx context.Context, syste
 	if systemInstruction != "" {
 		config.SystemInstruction = genai.NewContentFromText(systemInstruction, genai.RoleUser)
 	}
-	// Check if the rate limit allows a request.
-	if q.RateLimit.Allow() {
-		// If allowed, make the request to the LLM.
-		resp, err = q.ModelHandle.GenerateContent(ctx, q.ModelName, contents, &config)
-		if err != nil {
-			log.Printf("Error generating content: %v", err)
-			// If there's an error, check the retry count from the context.
-			retryCount, ok := ctx.Value("retry").(int)
-			if !ok {
-				// This is the first attempt.
-				retryCount = 0
-			}
-			if retryCount > 3 {
-				// If retry count exceeds the limit, return an error.
-				return nil, errors.New("failed generation on max retries")
-			}
-			// If retries are allowed, wait for one minute and try again.
-			errCtx := context.WithValue(ctx, "retry", retryCount+1)
-			time.Sleep(time.Minute * 1)
-			return q.ModelHandle.GenerateContent(errCtx, q.ModelName, contents, &config)
+
+	estimatedTokens := EstimateTokens(systemInstruction, contents)
+	if err = q.RateLimit.Wait(ctx, estimatedTokens); err != nil {
+		return nil, err
+	}
+	resp, err = q.Model.GenerateContent(ctx, q.ModelName, contents, &config)
+	if err != nil {
+		log.Printf("Error generating content: %v", err)
+		if IsResourceExhausted(err) {
+			q.RateLimit.OnThrottle()
 		}
-		// If successful, return the response.
-		return resp, err
-	} else {
-		// If rate limit is exceeded, wait for 5 seconds and try again.
-		time.Sleep(time.Second * 5)
-		return q.GenerateContent(ctx, systemInstruction, cacheName, contents, outputSchema)
+		return nil, err
+	}
+	q.RateLimit.OnSuccess()
+	if resp.UsageMetadata != nil {
+		q.RateLimit.Record(estimatedTokens, int(resp.UsageMetadata.TotalTokenCount))
 	}
+	return resp, nil
+}
+
+// CreateCachedContent creates a cached content for the wrapped LLM, models that don't
+// support caching return ErrCachingNotSupported.
+func (q *QuotaAwareGenerativeAIModel) CreateCachedContent(ctx context.Context, config *genai.CreateCachedContentConfig) (*genai.CachedContent, error) {
+	return q.Model.CreateCachedContent(ctx, q.ModelName, config)
 }
@@ -0,0 +1,60 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"github.com/GoogleCloudPlatform/media-search-solution/analyze/analysis"
+	common "github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+)
+
+// MediaAnalysisCommand runs the analysis steps of a proxy, like the analysis job. Completed steps
+// are skipped, so a failed analysis resumes from the failed step when the execution is retried.
+// The prompt templates follow the reloads of the snapshot.
+type MediaAnalysisCommand struct {
+	cor.BaseCommand
+	snapshot  *cloud.ConfigSnapshot
+	clients   *cloud.ServiceClients
+	templates *cloud.TemplateService
+}
+
+func NewMediaAnalysisCommand(name string, snapshot *cloud.ConfigSnapshot, clients *cloud.ServiceClients) *MediaAnalysisCommand {
+	return &MediaAnalysisCommand{
+		BaseCommand: *cor.NewBaseCommand(name),
+		snapshot:    snapshot,
+		clients:     clients,
+		templates:   cloud.NewTemplateServiceFromSnapshot(snapshot)}
+}
+
+func (m *MediaAnalysisCommand) Execute(context cor.Context) {
+	gcsFile, err := cor.GetKey(context, cloud.GCSObjectKey)
+	if err != nil {
+		m.GetErrorCounter().Add(context.GetContext(), 1)
+		context.AddError(m.GetName(), err)
+		return
+	}
+	config := m.snapshot.Get()
+
+	runConfig := NewMediaRunConfig(context, config, m.clients, gcsFile)
+	genaiRunConfig := common.NewGenaiRunConfigWithClients(runConfig, config, m.clients, m.templates)
+	if err = analysis.Run(genaiRunConfig, valueOrDefault(config.MediaWorker.FFprobePath, analysis.DefaultFFprobePath)); err != nil {
+		m.GetErrorCounter().Add(context.GetContext(), 1)
+		context.AddError(m.GetName(), err)
+		return
+	}
+
+	m.GetSuccessCounter().Add(context.GetContext(), 1)
+}
@@ -33,21 +33,26 @@ const (
 	FileCheckDelay = 10 * time.Second
 )
 
+// MediaConfigUpdateCommand reloads the configuration snapshot when a configuration file is updated,
+// the current configuration is kept when the updated configuration is invalid.
 type MediaConfigUpdateCommand struct {
 	cor.BaseCommand
-	config          *cloud.Config
-	templateService *cloud.TemplateService
+	snapshot *cloud.ConfigSnapshot
 }
 
-func NewMediaConfigUpdateCommand(name string, config *cloud.Config, templateService *cloud.TemplateService) *MediaConfigUpdateCommand {
+func NewMediaConfigUpdateCommand(name string, snapshot *cloud.ConfigSnapshot) *MediaConfigUpdateCommand {
 	return &MediaConfigUpdateCommand{
-		BaseCommand:     *cor.NewBaseCommand(name),
-		config:          config,
-		templateService: templateService}
+		BaseCommand: *cor.NewBaseCommand(name),
+		snapshot:    snapshot}
 }
 
 func (m *MediaConfigUpdateCommand) Execute(context cor.Context) {
-	gcsFile := context.Get(cloud.GetGCSObjectName()).(*cloud.GCSObject)
+	gcsFile, err := cor.GetKey(context, cloud.GCSObjectKey)
+	if err != nil {
+		m.GetErrorCounter().Add(context.GetContext(), 1)
+		context.AddError(m.GetName(), err)
+		return
+	}
 	configurationFilePrefix := os.Getenv(cloud.EnvConfigFilePrefix)
 	if len(configurationFilePrefix) > 0 && !strings.HasSuffix(configurationFilePrefix, string(os.PathSeparator)) {
 		configurationFilePrefix = configurationFilePrefix + string(os.PathSeparator)
@@ -57,13 +62,15 @@ func (m *MediaConfigUpdateCommand) Execute(context cor.Context) {
 
 	m.WaitForTheLocalFileToUpdate(localConfigFile)
 
-	newConfig := cloud.NewConfig()
-	// Load the configuration values for the updated config files
-	cloud.LoadConfig(&newConfig)
-	// Replace the current config with the new one
-	m.config.Replace(newConfig)
-	// Update the templates with the new config values
-	m.templateService.UpdateTemplates()
+	// Load the updated config files, the snapshot validates the new config values, makes
+	// them visible to readers and then notifies its listeners, e.g. rebuilding the templates
+	err = m.snapshot.Reload(os.Getenv(cloud.EnvConfigFilePrefix), os.Getenv(cloud.EnvConfigRuntime))
+	if err != nil {
+		log.Printf("configuration reload rejected, keeping the current configuration: %v", err)
+		m.GetErrorCounter().Add(context.GetContext(), 1)
+		context.AddError(m.GetName(), err)
+		return
+	}
 
 	m.GetSuccessCounter().Add(context.GetContext(), 1)
 }
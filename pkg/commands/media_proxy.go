@@ -0,0 +1,84 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"strconv"
+
+	common "github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+	"github.com/GoogleCloudPlatform/media-search-solution/analyze/proxy"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+)
+
+// MediaProxyCommand generates the proxy of an uploaded file in the low resolution bucket, like the
+// proxy generation job. The step is skipped when the metadata of the file marks it as completed.
+type MediaProxyCommand struct {
+	cor.BaseCommand
+	snapshot *cloud.ConfigSnapshot
+	clients  *cloud.ServiceClients
+}
+
+func NewMediaProxyCommand(name string, snapshot *cloud.ConfigSnapshot, clients *cloud.ServiceClients) *MediaProxyCommand {
+	return &MediaProxyCommand{
+		BaseCommand: *cor.NewBaseCommand(name),
+		snapshot:    snapshot,
+		clients:     clients}
+}
+
+func (m *MediaProxyCommand) Execute(context cor.Context) {
+	gcsFile, err := cor.GetKey(context, cloud.GCSObjectKey)
+	if err != nil {
+		m.GetErrorCounter().Add(context.GetContext(), 1)
+		context.AddError(m.GetName(), err)
+		return
+	}
+	config := m.snapshot.Get()
+	worker := config.MediaWorker
+	targetWidth := proxy.DefaultTargetWidth
+	if worker.ProxyWidth > 0 {
+		targetWidth = strconv.Itoa(worker.ProxyWidth)
+	}
+
+	runConfig := NewMediaRunConfig(context, config, m.clients, gcsFile)
+	step := proxy.NewProxyCommandConfig(runConfig, common.GENERATE_PROXY_STEP,
+		valueOrDefault(worker.FFmpegPath, proxy.DefaultCommandPath), proxy.DefaultArgsStringTemplate,
+		config.Storage.LowResOutputBucket, targetWidth, valueOrDefault(worker.ProxyFormat, proxy.DefaultOutputFormat))
+	step.Scratch = config.GetScratchConfig()
+	if err = step.Run(); err != nil {
+		m.GetErrorCounter().Add(context.GetContext(), 1)
+		context.AddError(m.GetName(), err)
+		return
+	}
+
+	m.GetSuccessCounter().Add(context.GetContext(), 1)
+}
+
+// NewMediaRunConfig creates the step run configuration of the GCS object of an execution, the steps
+// read the file from the mount point of the storage and share the object store of the clients.
+func NewMediaRunConfig(context cor.Context, config *cloud.Config, clients *cloud.ServiceClients, gcsFile *cloud.GCSObject) *common.BasicRunConfig {
+	runConfig := common.NewBasicRunConfigForObject(context.GetContext(), gcsFile.Bucket, gcsFile.Name,
+		config.Storage.GetMountPoint(), valueOrDefault(config.Storage.Backend, objectstore.BackendGCS))
+	runConfig.SetObjectStore(clients.ObjectStore)
+	return runConfig
+}
+
+func valueOrDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
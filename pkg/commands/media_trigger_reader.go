@@ -17,8 +17,6 @@
 package commands
 
 import (
-	"encoding/json"
-
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
 
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
@@ -33,9 +31,13 @@ func NewMediaTriggerToGCSObject(name string) *MediaTriggerToGCSObject {
 }
 
 func (c *MediaTriggerToGCSObject) Execute(context cor.Context) {
-	in := context.Get(c.GetInputParam()).(string)
-	var out cloud.GCSPubSubNotification
-	err := json.Unmarshal([]byte(in), &out)
+	in, err := cor.Get[string](context, c.GetInputParam())
+	if err != nil {
+		c.GetErrorCounter().Add(context.GetContext(), 1)
+		context.AddError(c.GetName(), err)
+		return
+	}
+	out, err := cloud.ParseGCSPubSubNotification([]byte(in))
 	if err != nil {
 		c.GetErrorCounter().Add(context.GetContext(), 1)
 		context.AddError(c.GetName(), err)
@@ -44,7 +46,7 @@ func (c *MediaTriggerToGCSObject) Execute(context cor.Context) {
 
 	c.GetSuccessCounter().Add(context.GetContext(), 1)
 
-	msg := &cloud.GCSObject{Bucket: out.Bucket, Name: out.Name, MIMEType: out.ContentType}
-	context.Add(cloud.GetGCSObjectName(), msg)
+	msg := &cloud.GCSObject{Bucket: out.Bucket, Name: out.Name, MIMEType: out.ContentType, Generation: out.Generation}
+	cor.Set(context, cloud.GCSObjectKey, msg)
 	context.Add(c.GetOutputParam(), msg)
 }
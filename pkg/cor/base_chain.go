@@ -17,15 +17,22 @@
 package cor
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type BaseChain struct {
 	BaseCommand
 	continueOnFailure bool
 	commands          []Command
+	checkpointStore   CheckpointStore
+	interceptors      []Interceptor
 }
 
 func NewBaseChain(name string) *BaseChain {
@@ -46,16 +53,105 @@ func (c *BaseChain) GetCommands() []Command {
 	return c.commands
 }
 
+// SetCheckpointStore enables checkpointing, after each successfully completed command
+// the context data is saved to the store so the execution can be resumed after a restart.
+// The checkpoint of a failed execution whose completed commands were compensated is deleted.
+func (c *BaseChain) SetCheckpointStore(store CheckpointStore) *BaseChain {
+	c.checkpointStore = store
+	return c
+}
+
+func (c *BaseChain) GetCheckpointStore() CheckpointStore {
+	return c.checkpointStore
+}
+
 func (c *BaseChain) IsExecutable(context Context) bool {
 	return context.GetContext() != nil
 }
 
+func (c *BaseChain) Explain(context Context) *Plan {
+	return Explain(c, context)
+}
+
 func (c *BaseChain) Execute(chCtx Context) {
+	c.execute(chCtx, 0)
+}
+
+// PendingCheckpoints returns the IDs of the checkpointed executions of this chain that did not complete.
+func (c *BaseChain) PendingCheckpoints(ctx context.Context) ([]string, error) {
+	if c.checkpointStore == nil {
+		return nil, nil
+	}
+	return c.checkpointStore.List(ctx, c.GetName())
+}
+
+// Resume restores the context of a checkpoint and executes the commands following
+// the last successfully completed command. The caller is responsible for closing the context.
+func (c *BaseChain) Resume(ctx context.Context, id string) (Context, error) {
+	if c.checkpointStore == nil {
+		return nil, fmt.Errorf("chain %s has no checkpoint store", c.GetName())
+	}
+	checkpoint, err := c.checkpointStore.Load(ctx, c.GetName(), id)
+	if err != nil {
+		return nil, err
+	}
+	chCtx, err := checkpoint.Restore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.execute(chCtx, checkpoint.Next)
+	return chCtx, nil
+}
+
+// ResumeContext resumes the checkpointed execution of the CheckpointIDKey of the context on the
+// context, e.g. a message executed again after a restart or a failed attempt. It returns false
+// without executing when the chain has no checkpoint store or the execution no checkpoint.
+func (c *BaseChain) ResumeContext(chCtx Context) (bool, error) {
+	if c.checkpointStore == nil {
+		return false, nil
+	}
+	id, err := GetKey(chCtx, CheckpointIDKey)
+	if err != nil || id == "" {
+		return false, nil
+	}
+	checkpoint, err := c.checkpointStore.Load(chCtx.GetContext(), c.GetName(), id)
+	if errors.Is(err, ErrCheckpointNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err = checkpoint.RestoreInto(chCtx); err != nil {
+		return false, err
+	}
+	c.execute(chCtx, checkpoint.Next)
+	return true, nil
+}
+
+// DeleteCheckpoint deletes the checkpoint of an execution, e.g. one whose attempts are exhausted.
+func (c *BaseChain) DeleteCheckpoint(ctx context.Context, id string) error {
+	if c.checkpointStore == nil {
+		return nil
+	}
+	return c.checkpointStore.Delete(ctx, c.GetName(), id)
+}
+
+func (c *BaseChain) execute(chCtx Context, start int) {
 	var ctx = chCtx.GetContext()
 	var parentCtx = chCtx.GetContext()
 
 	outerCtx, chainSpan := c.Tracer.Start(ctx, fmt.Sprintf("%s_execute", c.GetName()))
-	for _, command := range c.commands {
+	checkpointID := c.checkpointID(chCtx)
+	// Commands completed before a resume are restored from the checkpoint
+	var completed []int
+	var checkpointed []string
+	if start > 0 {
+		checkpointed = chCtx.GetTempFiles()
+		chainSpan.AddEvent(fmt.Sprintf("resuming %s at command %d", checkpointID, start))
+		completed = c.getCompleted(chCtx)
+	}
+	for i := start; i < len(c.commands); i++ {
+		command := c.commands[i]
 		// Ensure that the next parameter is callable in a pipe stack
 		commandContext, commandSpan := c.Tracer.Start(outerCtx, command.GetName())
 		commandSpan.SetName(command.GetName())
@@ -63,17 +159,34 @@ func (c *BaseChain) Execute(chCtx Context) {
 			commandSpan.SetStatus(codes.Error, "previous error on chain")
 			break
 		} else if command.IsExecutable(chCtx) {
-			// Since the next command may be a chain, we must set the parent context
-			chCtx.SetContext(commandContext)
+			invocation := &Invocation{Chain: c.GetName(), Command: command, Context: chCtx, Values: make(map[string]interface{})}
+			if c.before(invocation) {
+				// Since the next command may be a chain, we must set the parent context
+				chCtx.SetContext(commandContext)
 
-			// Start a span for each command to measure command performance
-			command.Execute(chCtx)
+				// Start a span for each command to measure command performance
+				baseline := chCtx.GetAllErrors()
+				started := time.Now()
+				command.Execute(chCtx)
+				invocation.Duration = time.Since(started)
+				invocation.Errors = newErrors(baseline, chCtx)
 
-			// Reset the context to the original state
-			if parentCtx != nil {
-				chCtx.SetContext(parentCtx)
+				// Reset the context to the original state
+				if parentCtx != nil {
+					chCtx.SetContext(parentCtx)
+				} else {
+					chCtx.SetContext(nil)
+				}
 			} else {
-				chCtx.SetContext(nil)
+				// A skipped command passes its input on to the next command
+				commandSpan.AddEvent("skipped by interceptor")
+				chCtx.Add(CtxOut, chCtx.Get(CtxIn))
+			}
+			c.after(invocation)
+
+			if !invocation.Skipped && len(invocation.Errors) == 0 {
+				completed = append(completed, i)
+				c.setCompleted(chCtx, completed)
 			}
 		} else {
 			commandSpan.SetStatus(codes.Error, fmt.Sprintf("command not executable: %s", command.GetName()))
@@ -92,12 +205,74 @@ func (c *BaseChain) Execute(chCtx Context) {
 		chCtx.Remove(CtxIn)
 		chCtx.Add(CtxIn, chCtx.Get(CtxOut))
 		chCtx.Remove(CtxOut)
+
+		if !chCtx.HasErrors() {
+			if files, ok := c.saveCheckpoint(outerCtx, chainSpan, checkpointID, i+1, chCtx); ok {
+				checkpointed = files
+			}
+		}
 	}
 
 	if !chCtx.HasErrors() {
+		c.deleteCheckpoint(outerCtx, chainSpan, checkpointID)
 		chainSpan.SetStatus(codes.Ok, c.GetName())
 	} else {
+		if c.compensate(outerCtx, chCtx, completed) {
+			// The checkpoint would skip the compensated commands, the execution starts over
+			c.deleteCheckpoint(outerCtx, chainSpan, checkpointID)
+		} else {
+			c.keepCheckpointedTempFiles(chCtx, checkpointed)
+		}
 		chainSpan.SetStatus(codes.Error, "chain failed to execute")
 	}
 	chainSpan.End()
 }
+
+// checkpointID returns the checkpoint ID of the execution, creating one if needed.
+func (c *BaseChain) checkpointID(chCtx Context) string {
+	if c.checkpointStore == nil {
+		return ""
+	}
+	id, err := GetKey(chCtx, CheckpointIDKey)
+	if err != nil || id == "" {
+		id = NewCheckpointID()
+		Set(chCtx, CheckpointIDKey, id)
+	}
+	return id
+}
+
+// saveCheckpoint saves the progress of the chain, a failure is logged and does not fail the chain.
+// It returns the temp files of the saved checkpoint, false when no checkpoint was saved.
+func (c *BaseChain) saveCheckpoint(ctx context.Context, span trace.Span, id string, next int, chCtx Context) ([]string, bool) {
+	if c.checkpointStore == nil {
+		return nil, false
+	}
+	checkpoint := NewCheckpoint(c.GetName(), id, next, chCtx)
+	if err := c.checkpointStore.Save(ctx, checkpoint); err != nil {
+		span.AddEvent(fmt.Sprintf("failed to save checkpoint: %v", err))
+		log.Printf("failed to save checkpoint %s/%s: %v", c.GetName(), id, err)
+		return nil, false
+	}
+	return checkpoint.TempFiles, true
+}
+
+// keepCheckpointedTempFiles hands the temp files of the pending checkpoint of a failed execution
+// over to the checkpoint, so closing the context doesn't delete the files a resume reads.
+func (c *BaseChain) keepCheckpointedTempFiles(chCtx Context, files []string) {
+	if c.checkpointStore == nil || len(files) == 0 {
+		return
+	}
+	if releaser, ok := chCtx.(interface{ ReleaseTempFiles(files ...string) }); ok {
+		releaser.ReleaseTempFiles(files...)
+	}
+}
+
+func (c *BaseChain) deleteCheckpoint(ctx context.Context, span trace.Span, id string) {
+	if c.checkpointStore == nil {
+		return
+	}
+	if err := c.checkpointStore.Delete(ctx, c.GetName(), id); err != nil {
+		span.AddEvent(fmt.Sprintf("failed to delete checkpoint: %v", err))
+		log.Printf("failed to delete checkpoint %s/%s: %v", c.GetName(), id, err)
+	}
+}
@@ -18,72 +18,265 @@ package cor
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
+	"slices"
+	"sort"
+	"sync"
 )
 
+// BaseContext is the default implementation of Context, all methods are safe for concurrent use.
 type BaseContext struct {
+	mu        sync.RWMutex
 	data      map[string]interface{}
-	errors    map[string]error
+	errors    map[string][]error
 	tempFiles []string
 	context   context.Context
+	scratch   *scratchState
+}
+
+// scratchState is shared by a context and its snapshots, so all the commands
+// of an execution use the same scratch directory.
+type scratchState struct {
+	mu     sync.Mutex
+	config ScratchConfig
+	dir    *ScratchDir
 }
 
 func NewBaseContext() Context {
 	return &BaseContext{
 		data:      make(map[string]interface{}),
-		errors:    make(map[string]error),
+		errors:    make(map[string][]error),
 		tempFiles: make([]string, 0),
+		scratch:   &scratchState{},
+	}
+}
+
+// NewBaseContextWithScratch creates a context whose scratch directory uses the given configuration.
+func NewBaseContextWithScratch(config ScratchConfig) Context {
+	c := NewBaseContext().(*BaseContext)
+	c.SetScratchConfig(config)
+	return c
+}
+
+// SetScratchConfig sets the root and disk budget of the scratch directory, it must be
+// called before the scratch directory is created.
+func (c *BaseContext) SetScratchConfig(config ScratchConfig) {
+	c.scratch.mu.Lock()
+	defer c.scratch.mu.Unlock()
+	c.scratch.config = config
+}
+
+// GetScratchDir returns the scratch directory of the execution, creating it on first use.
+// The directory is removed by Close.
+func (c *BaseContext) GetScratchDir() (*ScratchDir, error) {
+	c.scratch.mu.Lock()
+	defer c.scratch.mu.Unlock()
+	if c.scratch.dir == nil {
+		dir, err := NewScratchDir(c.scratch.config.Root, "cor-scratch-", c.scratch.config.Budget)
+		if err != nil {
+			return nil, err
+		}
+		c.scratch.dir = dir
 	}
+	return c.scratch.dir, nil
 }
 
 func (c *BaseContext) SetContext(context context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.context = context
 }
 
 func (c *BaseContext) GetContext() context.Context {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.context
 }
 
+// Close removes the temp files and the scratch directory, it's safe to call more than once.
 func (c *BaseContext) Close() {
+	c.mu.Lock()
+	tempFiles := c.tempFiles
+	c.tempFiles = make([]string, 0)
+	c.mu.Unlock()
+
 	// Clean up any temp files created along the way
-	for _, file := range c.GetTempFiles() {
+	for _, file := range tempFiles {
 		err := os.Remove(file)
-		if err != nil {
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
 			log.Printf("failed to remove file %v\n", err)
 		}
 	}
+
+	c.scratch.mu.Lock()
+	defer c.scratch.mu.Unlock()
+	if c.scratch.dir != nil {
+		if err := c.scratch.dir.Remove(); err != nil {
+			log.Printf("failed to remove scratch directory %v\n", err)
+		}
+		c.scratch.dir = nil
+	}
 }
 
 func (c *BaseContext) Add(key string, value interface{}) Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.data[key] = value
 	return c
 }
 
 func (c *BaseContext) AddTempFile(file string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.tempFiles = append(c.tempFiles, file)
 }
 
+// ReleaseTempFiles removes files from the temp files of the context, Close doesn't delete them.
+func (c *BaseContext) ReleaseTempFiles(files ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := make([]string, 0, len(c.tempFiles))
+	for _, file := range c.tempFiles {
+		if !slices.Contains(files, file) {
+			kept = append(kept, file)
+		}
+	}
+	c.tempFiles = kept
+}
+
 func (c *BaseContext) GetTempFiles() []string {
-	return c.tempFiles
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]string, len(c.tempFiles))
+	copy(out, c.tempFiles)
+	return out
 }
 
+// AddError records an error under a key, errors added under the same key are kept in order.
 func (c *BaseContext) AddError(key string, err error) {
-	c.errors[key] = err
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors[key] = append(c.errors[key], err)
 }
 
+// GetErrors returns the errors by key, multiple errors of a key are joined.
 func (c *BaseContext) GetErrors() map[string]error {
-	return c.errors
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]error, len(c.errors))
+	for key, errs := range c.errors {
+		out[key] = errors.Join(errs...)
+	}
+	return out
+}
+
+// GetAllErrors returns a copy of all errors by key.
+func (c *BaseContext) GetAllErrors() map[string][]error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string][]error, len(c.errors))
+	for key, errs := range c.errors {
+		out[key] = append([]error(nil), errs...)
+	}
+	return out
+}
+
+// Snapshot returns an isolated copy of the context for commands executing concurrently
+// or speculatively. The data is copied, values themselves are not deep copied. Errors and
+// temp files are not carried over so the snapshot only holds those of the commands executed
+// on it, Merge adds them to the original. The scratch directory is shared with the original,
+// so snapshots must not be closed.
+func (c *BaseContext) Snapshot() Context {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := &BaseContext{
+		data:      make(map[string]interface{}, len(c.data)),
+		errors:    make(map[string][]error),
+		tempFiles: make([]string, 0),
+		context:   c.context,
+		scratch:   c.scratch,
+	}
+	for k, v := range c.data {
+		out.data[k] = v
+	}
+	return out
+}
+
+// Merge copies the data of another context into this context, overwriting existing values,
+// and appends the errors and temp files of the other context. Use MergeDiff to only apply the
+// changes made to a snapshot.
+func (c *BaseContext) Merge(other Context) {
+	if other == nil || other == Context(c) {
+		return
+	}
+	data := make(map[string]interface{})
+	for _, key := range other.Keys() {
+		if value := other.Get(key); value != nil {
+			data[key] = value
+		}
+	}
+	errs := other.GetAllErrors()
+	tempFiles := other.GetTempFiles()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range data {
+		c.data[k] = v
+	}
+	for k, v := range errs {
+		c.errors[k] = append(c.errors[k], v...)
+	}
+	c.tempFiles = append(c.tempFiles, tempFiles...)
+}
+
+// MergeDiff applies the data diff of a snapshot to the target context, the keys added or
+// changed are set to their value in the snapshot and the keys removed are removed, other
+// keys of the target are kept. The errors and temp files of the snapshot are appended.
+func MergeDiff(target Context, snapshot Context, diff ContextDiff) {
+	for _, key := range append(append([]string(nil), diff.Added...), diff.Changed...) {
+		target.Add(key, snapshot.Get(key))
+	}
+	for _, key := range diff.Removed {
+		target.Remove(key)
+	}
+	for key, errs := range snapshot.GetAllErrors() {
+		for _, err := range errs {
+			target.AddError(key, err)
+		}
+	}
+	for _, file := range snapshot.GetTempFiles() {
+		target.AddTempFile(file)
+	}
 }
 
 func (c *BaseContext) Get(key string) interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.data[key]
 }
 
+// Keys returns the sorted keys of the context data.
+func (c *BaseContext) Keys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]string, 0, len(c.data))
+	for key := range c.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (c *BaseContext) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	delete(c.data, key)
 }
 
 func (c *BaseContext) HasErrors() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return len(c.errors) > 0
 }
@@ -0,0 +1,276 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CheckpointIDKey is the context key of the identifier used to checkpoint an execution,
+// e.g. the Pub/Sub message ID. A chain with a checkpoint store generates one when missing.
+const CheckpointIDKey Key[string] = "__CHECKPOINT__ID__"
+
+// ErrCheckpointNotFound is returned by a CheckpointStore when a checkpoint does not exist.
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// Checkpoint is the persisted state of a chain execution. Next is the index of the
+// command to execute on resume, i.e. one past the last successfully completed command.
+type Checkpoint struct {
+	ID        string                     `json:"id"`
+	Chain     string                     `json:"chain"`
+	Next      int                        `json:"next"`
+	Data      map[string]json.RawMessage `json:"data"`
+	Types     map[string]string          `json:"types,omitempty"`
+	TempFiles []string                   `json:"temp_files,omitempty"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+}
+
+// CheckpointStore persists checkpoints by chain name and checkpoint ID.
+type CheckpointStore interface {
+	Save(ctx context.Context, checkpoint *Checkpoint) error
+	Load(ctx context.Context, chain string, id string) (*Checkpoint, error)
+	Delete(ctx context.Context, chain string, id string) error
+	List(ctx context.Context, chain string) ([]string, error)
+}
+
+// Resumable is implemented by chains that can resume a checkpointed execution.
+type Resumable interface {
+	// PendingCheckpoints returns the IDs of executions that did not complete.
+	PendingCheckpoints(ctx context.Context) ([]string, error)
+	// Resume restores the context of a checkpoint and executes the remaining commands.
+	Resume(ctx context.Context, id string) (Context, error)
+	// ResumeContext restores the checkpoint of the CheckpointIDKey of the context into the
+	// context and executes the remaining commands, it returns false when there's no checkpoint.
+	ResumeContext(chCtx Context) (bool, error)
+	// DeleteCheckpoint deletes the checkpoint of an execution that won't be resumed.
+	DeleteCheckpoint(ctx context.Context, id string) error
+}
+
+var (
+	checkpointTypesMu sync.RWMutex
+	checkpointKeys    = make(map[string]func(data json.RawMessage) (interface{}, error))
+	checkpointTypes   = make(map[string]func(data json.RawMessage) (interface{}, error))
+)
+
+func init() {
+	// The input of an execution is the data of the received message
+	RegisterCheckpointType[string]()
+	RegisterCheckpointType[bool]()
+	RegisterCheckpointType[int]()
+	RegisterCheckpointType[int64]()
+	RegisterCheckpointType[float64]()
+	RegisterCheckpointType[[]string]()
+	RegisterCheckpointType[map[string]string]()
+}
+
+func checkpointDecoder[T any]() func(data json.RawMessage) (interface{}, error) {
+	return func(data json.RawMessage) (interface{}, error) {
+		var value T
+		err := json.Unmarshal(data, &value)
+		return value, err
+	}
+}
+
+// RegisterCheckpointKey registers the type of a context key so that its value is restored
+// with the correct type whatever the type of the value.
+func RegisterCheckpointKey[T any](key Key[T]) {
+	checkpointTypesMu.Lock()
+	defer checkpointTypesMu.Unlock()
+	checkpointKeys[string(key)] = checkpointDecoder[T]()
+}
+
+// RegisterCheckpointType registers a value type, values of the type are checkpointed under
+// any key, e.g. the outputs of commands, and restored with the same type.
+func RegisterCheckpointType[T any]() {
+	checkpointTypesMu.Lock()
+	defer checkpointTypesMu.Unlock()
+	checkpointTypes[reflect.TypeFor[T]().String()] = checkpointDecoder[T]()
+}
+
+// NewCheckpoint captures the data of the context. Only the values of registered keys or of
+// registered types are captured, so a resumed command never reads a value of another type,
+// other values and values that can't be serialized to JSON are skipped.
+func NewCheckpoint(chain string, id string, next int, context Context) *Checkpoint {
+	checkpoint := &Checkpoint{
+		ID:        id,
+		Chain:     chain,
+		Next:      next,
+		Data:      make(map[string]json.RawMessage),
+		Types:     make(map[string]string),
+		TempFiles: context.GetTempFiles(),
+		UpdatedAt: time.Now(),
+	}
+	checkpointTypesMu.RLock()
+	defer checkpointTypesMu.RUnlock()
+	for _, key := range context.Keys() {
+		value := context.Get(key)
+		if value == nil {
+			continue
+		}
+		if _, ok := checkpointKeys[key]; !ok {
+			typeName := reflect.TypeOf(value).String()
+			if _, ok = checkpointTypes[typeName]; !ok {
+				log.Printf("checkpoint %s/%s: skipping key %s: type %s is not registered", chain, id, key, typeName)
+				continue
+			}
+			checkpoint.Types[key] = typeName
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			log.Printf("checkpoint %s/%s: skipping key %s: %v", chain, id, key, err)
+			delete(checkpoint.Types, key)
+			continue
+		}
+		checkpoint.Data[key] = data
+	}
+	return checkpoint
+}
+
+// Restore creates a new context from the checkpoint data, see RestoreInto.
+func (c *Checkpoint) Restore(ctx context.Context) (Context, error) {
+	out := NewBaseContext()
+	out.SetContext(ctx)
+	if err := c.RestoreInto(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RestoreInto adds the checkpoint data to a context, values are restored with the type of their
+// key or the type recorded when they were captured. The context is unchanged when a value can't
+// be restored.
+func (c *Checkpoint) RestoreInto(out Context) error {
+	values := make(map[string]interface{}, len(c.Data))
+	checkpointTypesMu.RLock()
+	for key, data := range c.Data {
+		decode, ok := checkpointKeys[key]
+		if !ok {
+			decode, ok = checkpointTypes[c.Types[key]]
+		}
+		if !ok {
+			checkpointTypesMu.RUnlock()
+			return fmt.Errorf("checkpoint %s/%s: key %s has no registered type", c.Chain, c.ID, key)
+		}
+		value, err := decode(data)
+		if err != nil {
+			checkpointTypesMu.RUnlock()
+			return fmt.Errorf("checkpoint %s/%s: failed to restore key %s: %w", c.Chain, c.ID, key, err)
+		}
+		values[key] = value
+	}
+	checkpointTypesMu.RUnlock()
+
+	for key, value := range values {
+		out.Add(key, value)
+	}
+	for _, file := range c.TempFiles {
+		out.AddTempFile(file)
+	}
+	Set(out, CheckpointIDKey, c.ID)
+	return nil
+}
+
+// NewCheckpointID returns a random checkpoint identifier.
+func NewCheckpointID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// FileCheckpointStore is a CheckpointStore writing a JSON file per checkpoint
+// to <dir>/<chain>/<id>.json.
+type FileCheckpointStore struct {
+	dir string
+}
+
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+func (s *FileCheckpointStore) path(chain string, id string) string {
+	return filepath.Join(s.dir, filepath.Base(chain), filepath.Base(id)+".json")
+}
+
+// Save writes the checkpoint to a temp file and renames it, so a checkpoint is never partially written.
+func (s *FileCheckpointStore) Save(_ context.Context, checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	path := s.path(checkpoint.Chain, checkpoint.ID)
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileCheckpointStore) Load(_ context.Context, chain string, id string) (*Checkpoint, error) {
+	data, err := os.ReadFile(s.path(chain, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s/%s", ErrCheckpointNotFound, chain, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &Checkpoint{}
+	if err = json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (s *FileCheckpointStore) Delete(_ context.Context, chain string, id string) error {
+	err := os.Remove(s.path(chain, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileCheckpointStore) List(_ context.Context, chain string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, filepath.Base(chain)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			ids = append(ids, strings.TrimSuffix(entry.Name(), ".json"))
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CompensationKey is the context key of the indexes of the successfully completed
+// commands by chain name, used to compensate them when a chain fails.
+const CompensationKey Key[map[string][]int] = "__COMPENSATION__"
+
+var (
+	// ErrCompensated records that a command was compensated after a chain failure.
+	ErrCompensated = errors.New("compensated")
+	// ErrCompensationFailed records that the compensation of a command failed.
+	ErrCompensationFailed = errors.New("compensation failed")
+)
+
+func init() {
+	RegisterCheckpointKey(CompensationKey)
+}
+
+// Compensable is an optional interface of commands with side effects that
+// can be undone. When a chain fails, the compensable commands that completed
+// successfully are compensated in the reverse order of their execution.
+type Compensable interface {
+	Compensate(context Context)
+}
+
+// Compensate compensates the commands of the chain completed in the last execution
+// on the context, this is called by a parent chain failing after this chain completed.
+func (c *BaseChain) Compensate(chCtx Context) {
+	c.compensate(chCtx.GetContext(), chCtx, c.getCompleted(chCtx))
+}
+
+// compensate calls Compensate on the completed commands in reverse order. The outcome of each
+// compensation is recorded as an event of the compensation span and as an error of the context
+// under the "<chain>_compensate" key, so it's visible along with the error that caused it.
+// It returns whether a command was compensated.
+func (c *BaseChain) compensate(ctx context.Context, chCtx Context, completed []int) bool {
+	defer c.setCompleted(chCtx, nil)
+	if len(completed) == 0 {
+		return false
+	}
+	compensateCtx, span := c.Tracer.Start(ctx, fmt.Sprintf("%s_compensate", c.GetName()))
+	defer span.End()
+
+	key := fmt.Sprintf("%s_compensate", c.GetName())
+	parentCtx := chCtx.GetContext()
+	compensated, failed := false, false
+	for i := len(completed) - 1; i >= 0; i-- {
+		if completed[i] < 0 || completed[i] >= len(c.commands) {
+			continue
+		}
+		command := c.commands[completed[i]]
+		compensable, ok := asCompensable(command)
+		if !ok {
+			continue
+		}
+		compensated = true
+		before := failureCount(chCtx)
+		chCtx.SetContext(compensateCtx)
+		compensable.Compensate(chCtx)
+		chCtx.SetContext(parentCtx)
+
+		if failureCount(chCtx) > before {
+			failed = true
+			span.AddEvent("compensation failed", withCommandAttribute(command))
+			chCtx.AddError(key, fmt.Errorf("%w: %s", ErrCompensationFailed, command.GetName()))
+		} else {
+			span.AddEvent("compensated", withCommandAttribute(command))
+			chCtx.AddError(key, fmt.Errorf("%w: %s", ErrCompensated, command.GetName()))
+		}
+	}
+	if failed {
+		span.SetStatus(codes.Error, "compensation failed")
+	} else {
+		span.SetStatus(codes.Ok, "compensated")
+	}
+	return compensated
+}
+
+// getCompleted returns the indexes of the commands completed by the chain.
+func (c *BaseChain) getCompleted(chCtx Context) []int {
+	completed, _ := GetKey(chCtx, CompensationKey)
+	return append([]int(nil), completed[c.GetName()]...)
+}
+
+// setCompleted replaces the completed commands of the chain, the map is copied since
+// it may be shared with snapshots of the context.
+func (c *BaseChain) setCompleted(chCtx Context, indexes []int) {
+	previous, _ := GetKey(chCtx, CompensationKey)
+	completed := make(map[string][]int, len(previous)+1)
+	for name, value := range previous {
+		completed[name] = value
+	}
+	if len(indexes) == 0 {
+		delete(completed, c.GetName())
+	} else {
+		completed[c.GetName()] = indexes
+	}
+	if len(completed) == 0 {
+		chCtx.Remove(string(CompensationKey))
+		return
+	}
+	Set(chCtx, CompensationKey, completed)
+}
+
+// asCompensable returns the compensable command, unwrapping decorators such as RetryCommand.
+func asCompensable(command Command) (Compensable, bool) {
+	for command != nil {
+		if compensable, ok := command.(Compensable); ok {
+			return compensable, true
+		}
+		decorator, ok := command.(interface{ Unwrap() Command })
+		if !ok {
+			return nil, false
+		}
+		command = decorator.Unwrap()
+	}
+	return nil, false
+}
+
+// failureCount counts the errors of the context, except the records of successful
+// compensations made by nested chains.
+func failureCount(chCtx Context) int {
+	count := 0
+	for _, errs := range chCtx.GetAllErrors() {
+		for _, err := range errs {
+			if !errors.Is(err, ErrCompensated) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func withCommandAttribute(command Command) trace.EventOption {
+	return trace.WithAttributes(attribute.String("command", command.GetName()))
+}
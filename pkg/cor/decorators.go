@@ -0,0 +1,272 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrTimeout is reported when a command exceeds its deadline.
+	ErrTimeout = errors.New("command timed out")
+	// ErrCancelled is reported when the context of a command is cancelled before its deadline.
+	ErrCancelled = errors.New("command cancelled")
+)
+
+// RetryPolicy configures how a command is retried.
+type RetryPolicy struct {
+	MaxAttempts    int                  // The maximum number of attempts, including the first.
+	InitialBackoff time.Duration        // The wait before the second attempt.
+	MaxBackoff     time.Duration        // The upper bound of the wait between attempts.
+	Multiplier     float64              // The growth factor of the wait between attempts.
+	Jitter         float64              // The fraction (0-1) of the wait that is randomized.
+	Retryable      func(err error) bool // Classifies errors, nil retries all errors except cancellation.
+}
+
+// DefaultRetryPolicy returns a policy of 3 attempts with exponential backoff starting at one second.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Backoff returns the wait after the given (1-based) failed attempt.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		backoff = backoff * (1 - jitter + 2*jitter*rand.Float64())
+	}
+	return time.Duration(backoff)
+}
+
+// ShouldRetry classifies an error using the Retryable function of the policy.
+func (p *RetryPolicy) ShouldRetry(err error) bool {
+	if err == nil {
+		return false
+	}
+	if p.Retryable == nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return p.Retryable(err)
+}
+
+// RetryOn returns a classifier that retries errors matching any target using errors.Is.
+func RetryOn(targets ...error) func(err error) bool {
+	return func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryUnless returns a classifier that retries all errors except those matching a target using errors.Is.
+func RetryUnless(targets ...error) func(err error) bool {
+	return func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RetryOnType returns a classifier that retries errors of type T using errors.As.
+func RetryOnType[T error]() func(err error) bool {
+	return func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}
+}
+
+// RetryCommand is a decorator that retries the wrapped command according to a retry policy.
+// Each attempt is executed on a snapshot of the context, only the changes of the final
+// attempt are applied so that errors of a recovered attempt do not fail the chain.
+// The wrapped command counts its executions, attempts are counted on the
+// <name>.counter.retry_attempts counter with their outcome.
+type RetryCommand struct {
+	Command
+	policy   *RetryPolicy
+	attempts metric.Int64Counter
+}
+
+// WithRetry wraps a command with a retry policy, a nil policy uses DefaultRetryPolicy.
+func WithRetry(command Command, policy *RetryPolicy) *RetryCommand {
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	attempts, err := command.GetMeter().Int64Counter(fmt.Sprintf("%s.counter.retry_attempts", command.GetName()))
+	if err != nil {
+		log.Printf("error creating retry attempts counter: %s\n", command.GetName())
+	}
+	return &RetryCommand{Command: command, policy: policy, attempts: attempts}
+}
+
+func (r *RetryCommand) GetPolicy() *RetryPolicy {
+	return r.policy
+}
+
+// Unwrap returns the decorated command.
+func (r *RetryCommand) Unwrap() Command {
+	return r.Command
+}
+
+func (r *RetryCommand) Execute(chCtx Context) {
+	ctx := chCtx.GetContext()
+	span := trace.SpanFromContext(ctx)
+
+	var attempt Context
+	for i := 1; ; i++ {
+		attempt = chCtx.Snapshot()
+		r.Command.Execute(attempt)
+
+		err := joinErrors(attempt)
+		attrs := []attribute.KeyValue{
+			attribute.String("command", r.GetName()),
+			attribute.Int("attempt", i),
+		}
+		if err == nil {
+			span.AddEvent("attempt succeeded", trace.WithAttributes(attrs...))
+			r.countAttempt(ctx, "success")
+			break
+		}
+		span.AddEvent("attempt failed", trace.WithAttributes(append(attrs, attribute.String("error", err.Error()))...))
+		r.countAttempt(ctx, "error")
+
+		if i >= r.policy.MaxAttempts || !r.policy.ShouldRetry(err) {
+			break
+		}
+
+		backoff := r.policy.Backoff(i)
+		select {
+		case <-ctx.Done():
+			attempt.AddError(r.GetName(), fmt.Errorf("retry of %s aborted after %d attempts: %w", r.GetName(), i, ctx.Err()))
+			MergeDiff(chCtx, attempt, Diff(chCtx, attempt))
+			return
+		case <-time.After(backoff):
+		}
+	}
+	MergeDiff(chCtx, attempt, Diff(chCtx, attempt))
+}
+
+func (r *RetryCommand) countAttempt(ctx context.Context, outcome string) {
+	if r.attempts == nil {
+		return
+	}
+	r.attempts.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("command", r.GetName()),
+		attribute.String("outcome", outcome)))
+}
+
+// TimeoutCommand is a decorator that bounds the execution time of the wrapped command.
+// The deadline is derived from the context.Context of the chain so a shorter chain deadline
+// takes precedence. Commands that do not observe the context are abandoned at the deadline
+// and their output is discarded. A cancelled chain is reported as ErrCancelled, not ErrTimeout.
+type TimeoutCommand struct {
+	Command
+	timeout time.Duration
+}
+
+// WithTimeout wraps a command with a deadline.
+func WithTimeout(command Command, timeout time.Duration) *TimeoutCommand {
+	return &TimeoutCommand{Command: command, timeout: timeout}
+}
+
+func (t *TimeoutCommand) GetTimeout() time.Duration {
+	return t.timeout
+}
+
+// Unwrap returns the decorated command.
+func (t *TimeoutCommand) Unwrap() Command {
+	return t.Command
+}
+
+func (t *TimeoutCommand) Execute(chCtx Context) {
+	parentCtx := chCtx.GetContext()
+	ctx, cancel := context.WithTimeout(parentCtx, t.timeout)
+	defer cancel()
+
+	attempt := chCtx.Snapshot()
+	attempt.SetContext(ctx)
+
+	// A panic can't be recovered by the caller of the chain from the goroutine, it's reported
+	// through the done channel, which is buffered so an abandoned command doesn't block.
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("%w: %v\n%s", ErrPanic, r, debug.Stack())
+			}
+		}()
+		t.Command.Execute(attempt)
+	}()
+
+	select {
+	case err := <-done:
+		attempt.SetContext(parentCtx)
+		MergeDiff(chCtx, attempt, Diff(chCtx, attempt))
+		if err != nil {
+			chCtx.AddError(t.GetName(), err)
+		}
+	case <-ctx.Done():
+		// The chain may be cancelled, e.g. on shutdown, which isn't a timeout of the command
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			trace.SpanFromContext(parentCtx).AddEvent("command cancelled", trace.WithAttributes(
+				attribute.String("command", t.GetName())))
+			chCtx.AddError(t.GetName(), fmt.Errorf("%w: %s: %w", ErrCancelled, t.GetName(), ctx.Err()))
+			return
+		}
+		trace.SpanFromContext(parentCtx).AddEvent("command timed out", trace.WithAttributes(
+			attribute.String("command", t.GetName()),
+			attribute.String("timeout", t.timeout.String())))
+		chCtx.AddError(t.GetName(), fmt.Errorf("%w: %s after %s: %w", ErrTimeout, t.GetName(), t.timeout, ctx.Err()))
+	}
+}
+
+// joinErrors returns all errors of the context joined, or nil if there are none.
+func joinErrors(context Context) error {
+	var out []error
+	for _, errs := range context.GetAllErrors() {
+		out = append(out, errs...)
+	}
+	return errors.Join(out...)
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Kinds of the steps of a plan.
+const (
+	StepKindCommand   = "command"
+	StepKindChain     = "chain"
+	StepKindParallel  = "parallel"
+	StepKindRouter    = "router"
+	StepKindRoute     = "route"
+	StepKindRetry     = "retry"
+	StepKindTimeout   = "timeout"
+	StepKindReference = "reference"
+)
+
+// PlanStep is a node of the command tree of a plan.
+type PlanStep struct {
+	Name        string      `json:"name"`
+	Kind        string      `json:"kind"`
+	InputParam  string      `json:"input_param"`
+	OutputParam string      `json:"output_param"`
+	Executable  bool        `json:"executable"`
+	SkipReason  string      `json:"skip_reason,omitempty"`
+	Selected    bool        `json:"selected,omitempty"` // Whether a route is selected by the sample context.
+	Steps       []*PlanStep `json:"steps,omitempty"`
+}
+
+// Plan is the result of explaining a command against a sample context.
+type Plan struct {
+	Root *PlanStep `json:"root"`
+}
+
+// Placeholder is the value assumed for the output of a command while explaining,
+// since commands are not executed.
+type Placeholder struct {
+	Command string `json:"command"`
+}
+
+// Explain walks the command tree of a command, including nested chains, routers and
+// decorators, and evaluates IsExecutable against a snapshot of the sample context without
+// executing any command. The output of each executable command is assumed to be set.
+func Explain(command Command, sample Context) *Plan {
+	snapshot := sample.Snapshot()
+	if snapshot.GetContext() == nil {
+		snapshot.SetContext(context.Background())
+	}
+	return &Plan{Root: explain(command, snapshot)}
+}
+
+// JSON returns the indented JSON representation of the plan.
+func (p *Plan) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// String returns the plan as a tree, one step per line.
+func (p *Plan) String() string {
+	var sb strings.Builder
+	writeStep(&sb, p.Root, "", "")
+	return sb.String()
+}
+
+// Skipped returns the steps that would be skipped.
+func (p *Plan) Skipped() []*PlanStep {
+	var out []*PlanStep
+	var walk func(step *PlanStep)
+	walk = func(step *PlanStep) {
+		if !step.Executable {
+			out = append(out, step)
+		}
+		for _, child := range step.Steps {
+			walk(child)
+		}
+	}
+	walk(p.Root)
+	return out
+}
+
+func explain(command Command, sample Context) *PlanStep {
+	step := &PlanStep{
+		Name:        command.GetName(),
+		Kind:        StepKindCommand,
+		InputParam:  command.GetInputParam(),
+		OutputParam: command.GetOutputParam(),
+		Executable:  command.IsExecutable(sample),
+	}
+	if !step.Executable {
+		if sample.Get(step.InputParam) == nil {
+			step.SkipReason = fmt.Sprintf("missing input param %s", step.InputParam)
+		} else {
+			step.SkipReason = "not executable"
+		}
+	}
+
+	switch cmd := command.(type) {
+	case *BaseChain:
+		step.Kind = StepKindChain
+		for _, child := range cmd.GetCommands() {
+			childStep := explain(child, sample)
+			step.Steps = append(step.Steps, childStep)
+			// Simulate the flip-flop of the input and output of the chain
+			sample.Remove(CtxIn)
+			if childStep.Executable {
+				sample.Add(CtxIn, sample.Get(CtxOut))
+			}
+			sample.Remove(CtxOut)
+		}
+		return step
+	case *ParallelChain:
+		step.Kind = StepKindParallel
+		for _, child := range cmd.GetCommands() {
+			snapshot := sample.Snapshot()
+			snapshot.Remove(CtxOut)
+			step.Steps = append(step.Steps, explain(child, snapshot))
+			snapshot.Remove(CtxIn)
+			snapshot.Remove(CtxOut)
+			sample.Merge(snapshot)
+		}
+	case *Router:
+		step.Kind = StepKindRouter
+		selected, _ := cmd.Select(sample)
+		for _, route := range cmd.GetRoutes() {
+			step.Steps = append(step.Steps, explainRoute(route.Name, route.Command, route.Name == selected, sample))
+		}
+		if cmd.GetDefault() != nil {
+			step.Steps = append(step.Steps, explainRoute("default", cmd.GetDefault(), selected == "default", sample))
+		}
+		return step
+	case *RetryCommand:
+		step.Kind = StepKindRetry
+		step.Steps = []*PlanStep{explain(cmd.Unwrap(), sample)}
+		return step
+	case *TimeoutCommand:
+		step.Kind = StepKindTimeout
+		step.Steps = []*PlanStep{explain(cmd.Unwrap(), sample)}
+		return step
+	case interface{ Unwrap() Command }:
+		if inner := cmd.Unwrap(); inner != nil {
+			step.Kind = StepKindReference
+			step.Steps = []*PlanStep{explain(inner, sample)}
+			return step
+		}
+	}
+
+	if step.Executable {
+		sample.Add(step.OutputParam, &Placeholder{Command: step.Name})
+	}
+	return step
+}
+
+// explainRoute explains the branch of a router, only the selected branch updates the sample.
+func explainRoute(name string, command Command, selected bool, sample Context) *PlanStep {
+	if !selected {
+		sample = sample.Snapshot()
+	}
+	step := &PlanStep{
+		Name:       name,
+		Kind:       StepKindRoute,
+		Executable: selected,
+		Selected:   selected,
+		Steps:      []*PlanStep{explain(command, sample)},
+	}
+	if !selected {
+		step.SkipReason = "route not selected"
+	}
+	return step
+}
+
+func writeStep(sb *strings.Builder, step *PlanStep, prefix string, childPrefix string) {
+	sb.WriteString(prefix)
+	sb.WriteString(fmt.Sprintf("%s [%s]", step.Name, step.Kind))
+	if step.Kind != StepKindRoute {
+		sb.WriteString(fmt.Sprintf(" in=%s out=%s", step.InputParam, step.OutputParam))
+	}
+	if step.Selected {
+		sb.WriteString(" (selected)")
+	}
+	if step.SkipReason != "" {
+		sb.WriteString(fmt.Sprintf(" SKIPPED: %s", step.SkipReason))
+	}
+	sb.WriteString("\n")
+	for i, child := range step.Steps {
+		if i == len(step.Steps)-1 {
+			writeStep(sb, child, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			writeStep(sb, child, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+}
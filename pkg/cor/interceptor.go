@@ -0,0 +1,238 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Invocation describes the execution of a command by a chain, it's passed to the
+// interceptors of the chain. Duration and Errors are set before After is called.
+type Invocation struct {
+	Chain    string                 // The name of the executing chain.
+	Command  Command                // The command being executed.
+	Context  Context                // The chain context.
+	Duration time.Duration          // The execution time of the command.
+	Errors   []error                // The errors added to the context by the command.
+	Skipped  bool                   // Whether an interceptor short-circuited the command.
+	Values   map[string]interface{} // Values shared by the hooks of an invocation.
+}
+
+// Interceptor is a middleware of BaseChain, Before is called in the order the interceptors
+// were added before each command, and After in reverse order after each command.
+// Returning false from Before short-circuits the command, the remaining Before hooks
+// are not called, the command is skipped and its input is passed on to the next command.
+type Interceptor interface {
+	Before(invocation *Invocation) bool
+	After(invocation *Invocation)
+}
+
+// InterceptorFuncs adapts a pair of functions to an Interceptor, either may be nil.
+type InterceptorFuncs struct {
+	BeforeFunc func(invocation *Invocation) bool
+	AfterFunc  func(invocation *Invocation)
+}
+
+func (f InterceptorFuncs) Before(invocation *Invocation) bool {
+	if f.BeforeFunc == nil {
+		return true
+	}
+	return f.BeforeFunc(invocation)
+}
+
+func (f InterceptorFuncs) After(invocation *Invocation) {
+	if f.AfterFunc != nil {
+		f.AfterFunc(invocation)
+	}
+}
+
+// AddInterceptor adds an interceptor called around each command of the chain.
+func (c *BaseChain) AddInterceptor(interceptor Interceptor) *BaseChain {
+	c.interceptors = append(c.interceptors, interceptor)
+	return c
+}
+
+func (c *BaseChain) GetInterceptors() []Interceptor {
+	return c.interceptors
+}
+
+// before calls the Before hooks, returning false if one of them short-circuits the command.
+func (c *BaseChain) before(invocation *Invocation) bool {
+	for _, interceptor := range c.interceptors {
+		if !interceptor.Before(invocation) {
+			invocation.Skipped = true
+			return false
+		}
+	}
+	return true
+}
+
+func (c *BaseChain) after(invocation *Invocation) {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		c.interceptors[i].After(invocation)
+	}
+}
+
+// newErrors returns the errors of the context added after the baseline was taken.
+func newErrors(baseline map[string][]error, chCtx Context) []error {
+	var out []error
+	for key, errs := range chCtx.GetAllErrors() {
+		if len(errs) > len(baseline[key]) {
+			out = append(out, errs[len(baseline[key]):]...)
+		}
+	}
+	return out
+}
+
+// SlogInterceptor logs the execution of each command as a structured log record.
+type SlogInterceptor struct {
+	logger *slog.Logger
+}
+
+// NewSlogInterceptor creates a logging interceptor, the default logger is used when logger is nil.
+func NewSlogInterceptor(logger *slog.Logger) *SlogInterceptor {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogInterceptor{logger: logger}
+}
+
+func (s *SlogInterceptor) Before(invocation *Invocation) bool {
+	s.logger.DebugContext(invocationContext(invocation), "executing command",
+		slog.String("chain", invocation.Chain),
+		slog.String("command", invocation.Command.GetName()))
+	return true
+}
+
+func (s *SlogInterceptor) After(invocation *Invocation) {
+	attrs := []any{
+		slog.String("chain", invocation.Chain),
+		slog.String("command", invocation.Command.GetName()),
+		slog.Duration("duration", invocation.Duration),
+		slog.Bool("skipped", invocation.Skipped),
+	}
+	if len(invocation.Errors) > 0 {
+		s.logger.ErrorContext(invocationContext(invocation), "command failed",
+			append(attrs, slog.Any("error", errors.Join(invocation.Errors...)))...)
+		return
+	}
+	s.logger.InfoContext(invocationContext(invocation), "command executed", attrs...)
+}
+
+// ContextDiff is the change made to the context data by a command.
+type ContextDiff struct {
+	Chain   string   `json:"chain"`
+	Command string   `json:"command"`
+	Added   []string `json:"added,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// IsEmpty returns true if the command did not change the context data.
+func (d ContextDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// Keys returns the sorted keys added, changed or removed.
+func (d ContextDiff) Keys() []string {
+	keys := make([]string, 0, len(d.Added)+len(d.Changed)+len(d.Removed))
+	keys = append(append(append(keys, d.Added...), d.Changed...), d.Removed...)
+	sort.Strings(keys)
+	return keys
+}
+
+// Without returns the diff without the given keys.
+func (d ContextDiff) Without(keys ...string) ContextDiff {
+	filter := func(values []string) []string {
+		var out []string
+		for _, value := range values {
+			if !slices.Contains(keys, value) {
+				out = append(out, value)
+			}
+		}
+		return out
+	}
+	d.Added = filter(d.Added)
+	d.Changed = filter(d.Changed)
+	d.Removed = filter(d.Removed)
+	return d
+}
+
+// Diff returns the keys of the context data added, changed or removed in after compared
+// to before, values are compared with reflect.DeepEqual.
+func Diff(before Context, after Context) ContextDiff {
+	var diff ContextDiff
+	previous := make(map[string]bool)
+	for _, key := range before.Keys() {
+		previous[key] = true
+		value := after.Get(key)
+		if value == nil {
+			diff.Removed = append(diff.Removed, key)
+		} else if !reflect.DeepEqual(before.Get(key), value) {
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for _, key := range after.Keys() {
+		if !previous[key] {
+			diff.Added = append(diff.Added, key)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+const contextDiffBefore = "context_diff.before"
+
+// ContextDiffInterceptor records the keys of the context data added, changed
+// or removed by each command, values are compared with reflect.DeepEqual.
+type ContextDiffInterceptor struct {
+	record func(diff ContextDiff)
+}
+
+// NewContextDiffInterceptor creates an interceptor passing the diff of each command to record.
+func NewContextDiffInterceptor(record func(diff ContextDiff)) *ContextDiffInterceptor {
+	return &ContextDiffInterceptor{record: record}
+}
+
+func (d *ContextDiffInterceptor) Before(invocation *Invocation) bool {
+	invocation.Values[contextDiffBefore] = invocation.Context.Snapshot()
+	return true
+}
+
+func (d *ContextDiffInterceptor) After(invocation *Invocation) {
+	before, ok := invocation.Values[contextDiffBefore].(Context)
+	if !ok {
+		return
+	}
+	diff := Diff(before, invocation.Context)
+	diff.Chain = invocation.Chain
+	diff.Command = invocation.Command.GetName()
+	d.record(diff)
+}
+
+func invocationContext(invocation *Invocation) context.Context {
+	if ctx := invocation.Context.GetContext(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
@@ -30,18 +30,24 @@ const (
 
 // Context is an opinionated runtime context for Go Lang.
 // It's a bit more complex than other language versions due to the nature
-// of Filesystem behaviors.
+// of Filesystem behaviors. Implementations must be safe for concurrent use,
+// use Get or GetKey for type-safe access to values.
 type Context interface {
 	SetContext(context context.Context)
 	GetContext() context.Context
 	Add(key string, value interface{}) Context
 	AddError(key string, err error)
 	GetErrors() map[string]error
+	GetAllErrors() map[string][]error
 	Get(key string) interface{}
+	Keys() []string
 	Remove(key string)
 	HasErrors() bool
 	AddTempFile(file string)
 	GetTempFiles() []string
+	GetScratchDir() (*ScratchDir, error)
+	Snapshot() Context
+	Merge(other Context)
 	Close()
 }
 
@@ -70,4 +76,6 @@ type Chain interface {
 	Command
 	ContinueOnFailure(bool) Chain
 	AddCommand(command Command) Chain
+	// Explain returns the execution plan of the chain for a sample context without executing it.
+	Explain(context Context) *Plan
 }
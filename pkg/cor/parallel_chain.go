@@ -0,0 +1,183 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+// DefaultMaxConcurrency is used when a ParallelChain is created without a positive limit.
+const DefaultMaxConcurrency = 4
+
+// ErrConflictingWrites is reported by a ParallelChain when more than one command changes a key.
+var ErrConflictingWrites = errors.New("conflicting writes")
+
+// ParallelChain is a fan-out chain, each command is executed concurrently
+// on an isolated snapshot of the chain context. When all commands have completed
+// the keys each command added, changed or removed are applied to the chain context, a key
+// changed by more than one command is reported as ErrConflictingWrites. Since each command may
+// write CtxOut, the outputs are collected into a map keyed by command name and
+// set as the CtxOut of the chain.
+//
+// By default, the chain is fail-fast: the first command to report an error cancels
+// the context of the remaining commands and commands that have not started are skipped.
+// Setting ContinueOnFailure(true) waits for all commands to complete.
+type ParallelChain struct {
+	BaseCommand
+	continueOnFailure bool
+	maxConcurrency    int
+	commands          []Command
+}
+
+// NewParallelChain creates a parallel chain executing at most maxConcurrency commands at a time.
+func NewParallelChain(name string, maxConcurrency int) *ParallelChain {
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	return &ParallelChain{BaseCommand: *NewBaseCommand(name), maxConcurrency: maxConcurrency}
+}
+
+func (c *ParallelChain) ContinueOnFailure(continueOnFailure bool) Chain {
+	c.continueOnFailure = continueOnFailure
+	return c
+}
+
+func (c *ParallelChain) AddCommand(command Command) Chain {
+	c.commands = append(c.commands, command)
+	return c
+}
+
+func (c *ParallelChain) GetCommands() []Command {
+	return c.commands
+}
+
+func (c *ParallelChain) GetMaxConcurrency() int {
+	return c.maxConcurrency
+}
+
+func (c *ParallelChain) IsExecutable(context Context) bool {
+	return context.GetContext() != nil
+}
+
+func (c *ParallelChain) Explain(context Context) *Plan {
+	return Explain(c, context)
+}
+
+func (c *ParallelChain) Execute(chCtx Context) {
+	outerCtx, chainSpan := c.Tracer.Start(chCtx.GetContext(), fmt.Sprintf("%s_execute", c.GetName()))
+	defer chainSpan.End()
+
+	if chCtx.HasErrors() && !c.continueOnFailure {
+		chainSpan.SetStatus(codes.Error, "previous error on chain")
+		return
+	}
+
+	cancelCtx, cancel := context.WithCancel(outerCtx)
+	defer cancel()
+
+	// The chain context is only read while the commands execute, base is the data each snapshot starts with.
+	base := chCtx.Snapshot()
+	snapshots := make([]Context, len(c.commands))
+	semaphore := make(chan struct{}, c.maxConcurrency)
+	var wg sync.WaitGroup
+
+	for i, command := range c.commands {
+		semaphore <- struct{}{} // Acquire a slot
+		if cancelCtx.Err() != nil {
+			<-semaphore
+			break
+		}
+		snapshots[i] = base.Snapshot()
+		wg.Add(1)
+		go func(snapshot Context, command Command) {
+			defer func() {
+				<-semaphore // Release the slot
+				wg.Done()
+			}()
+			// A panic can't be recovered by the caller of the chain from this goroutine
+			defer func() {
+				if r := recover(); r != nil {
+					snapshot.AddError(command.GetName(), fmt.Errorf("%w: %v\n%s", ErrPanic, r, debug.Stack()))
+					if !c.continueOnFailure {
+						cancel()
+					}
+				}
+			}()
+			c.executeCommand(cancelCtx, snapshot, command, cancel)
+		}(snapshots[i], command)
+	}
+	wg.Wait()
+
+	// Collect the outputs and apply the changes of each command in the order the commands were added.
+	outputs := make(map[string]interface{})
+	writers := make(map[string]string)
+	for i, snapshot := range snapshots {
+		if snapshot == nil {
+			continue
+		}
+		name := c.commands[i].GetName()
+		if out := snapshot.Get(CtxOut); out != nil {
+			outputs[name] = out
+		}
+		diff := Diff(base, snapshot).Without(CtxIn, CtxOut)
+		for _, key := range diff.Keys() {
+			if writer, ok := writers[key]; ok {
+				chCtx.AddError(c.GetName(), fmt.Errorf("%w: %s changed by %s and %s", ErrConflictingWrites, key, writer, name))
+			}
+			writers[key] = name
+		}
+		MergeDiff(chCtx, snapshot, diff)
+	}
+	chCtx.Add(c.GetOutputParam(), outputs)
+
+	if !chCtx.HasErrors() {
+		c.GetSuccessCounter().Add(outerCtx, 1)
+		chainSpan.SetStatus(codes.Ok, c.GetName())
+	} else {
+		c.GetErrorCounter().Add(outerCtx, 1)
+		chainSpan.SetStatus(codes.Error, "chain failed to execute")
+	}
+}
+
+// executeCommand runs a single command on a snapshot of the chain context.
+func (c *ParallelChain) executeCommand(ctx context.Context, snapshot Context, command Command, cancel context.CancelFunc) {
+	commandContext, commandSpan := c.Tracer.Start(ctx, command.GetName())
+	defer commandSpan.End()
+
+	snapshot.SetContext(commandContext)
+	snapshot.Remove(CtxOut)
+
+	if !command.IsExecutable(snapshot) {
+		commandSpan.SetStatus(codes.Error, fmt.Sprintf("command not executable: %s", command.GetName()))
+		return
+	}
+
+	command.Execute(snapshot)
+
+	if snapshot.HasErrors() {
+		commandSpan.SetStatus(codes.Error, "error after execute")
+		if !c.continueOnFailure {
+			cancel()
+		}
+	} else {
+		commandSpan.SetStatus(codes.Ok, command.GetName())
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Predicate evaluates a context to determine if a route should be taken.
+type Predicate func(context Context) bool
+
+// Route is a named branch of a Router.
+type Route struct {
+	Name      string
+	Predicate Predicate
+	Command   Command
+}
+
+// Router is a command that evaluates its routes in the order they were added
+// and executes the command of the first route whose predicate matches. When no
+// route matches the default command is executed, if one is set.
+type Router struct {
+	BaseCommand
+	routes         []*Route
+	defaultCommand Command
+}
+
+func NewRouter(name string) *Router {
+	return &Router{BaseCommand: *NewBaseCommand(name)}
+}
+
+// AddRoute adds a named branch to the router.
+func (r *Router) AddRoute(name string, predicate Predicate, command Command) *Router {
+	r.routes = append(r.routes, &Route{Name: name, Predicate: predicate, Command: command})
+	return r
+}
+
+// SetDefault sets the command executed when no route matches.
+func (r *Router) SetDefault(command Command) *Router {
+	r.defaultCommand = command
+	return r
+}
+
+func (r *Router) GetRoutes() []*Route {
+	return r.routes
+}
+
+func (r *Router) GetDefault() Command {
+	return r.defaultCommand
+}
+
+// Select returns the name and command of the first matching route, the default
+// command when no route matches, or nil when there is no default.
+func (r *Router) Select(context Context) (string, Command) {
+	for _, route := range r.routes {
+		if route.Predicate != nil && route.Predicate(context) {
+			return route.Name, route.Command
+		}
+	}
+	if r.defaultCommand != nil {
+		return "default", r.defaultCommand
+	}
+	return "", nil
+}
+
+func (r *Router) IsExecutable(context Context) bool {
+	return context != nil && context.GetContext() != nil
+}
+
+func (r *Router) Execute(chCtx Context) {
+	parentCtx := chCtx.GetContext()
+	routerCtx, span := r.Tracer.Start(parentCtx, fmt.Sprintf("%s_route", r.GetName()))
+	defer span.End()
+
+	routeName, command := r.Select(chCtx)
+	if command == nil {
+		span.AddEvent("no route matched")
+		span.SetStatus(codes.Ok, "no route matched")
+		return
+	}
+	span.SetAttributes(attribute.String("route", routeName), attribute.String("command", command.GetName()))
+
+	if !command.IsExecutable(chCtx) {
+		span.SetStatus(codes.Error, fmt.Sprintf("command not executable: %s", command.GetName()))
+		r.GetErrorCounter().Add(routerCtx, 1, metricRouteAttribute(routeName))
+		return
+	}
+
+	chCtx.SetContext(routerCtx)
+	command.Execute(chCtx)
+	chCtx.SetContext(parentCtx)
+
+	if chCtx.HasErrors() {
+		span.SetStatus(codes.Error, fmt.Sprintf("route %s failed", routeName))
+		r.GetErrorCounter().Add(routerCtx, 1, metricRouteAttribute(routeName))
+	} else {
+		span.SetStatus(codes.Ok, routeName)
+		r.GetSuccessCounter().Add(routerCtx, 1, metricRouteAttribute(routeName))
+	}
+}
+
+// HasKey returns a predicate that matches when the key is present in the context.
+func HasKey(key string) Predicate {
+	return func(context Context) bool {
+		return context.Get(key) != nil
+	}
+}
+
+// And returns a predicate that matches when all predicates match.
+func And(predicates ...Predicate) Predicate {
+	return func(context Context) bool {
+		for _, p := range predicates {
+			if !p(context) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a predicate that matches when any predicate matches.
+func Or(predicates ...Predicate) Predicate {
+	return func(context Context) bool {
+		for _, p := range predicates {
+			if p(context) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a predicate that negates the given predicate.
+func Not(predicate Predicate) Predicate {
+	return func(context Context) bool {
+		return !predicate(context)
+	}
+}
+
+func metricRouteAttribute(routeName string) metric.AddOption {
+	return metric.WithAttributes(attribute.String("route", routeName))
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDiskBudgetExceeded is returned when the files of a scratch directory exceed its disk budget.
+	ErrDiskBudgetExceeded = errors.New("disk budget exceeded")
+	// ErrPanic is the error recorded when a command panics during Run.
+	ErrPanic = errors.New("command panicked")
+)
+
+// ScratchConfig configures the scratch directory of a context.
+type ScratchConfig struct {
+	Root   string // The parent directory, defaults to os.TempDir().
+	Budget int64  // The maximum size in bytes of the files in the directory, 0 is unlimited.
+}
+
+// ScratchDir is a temporary directory with an optional disk budget, all files
+// created by a command execution should be written here so they are removed
+// when the context is closed.
+type ScratchDir struct {
+	mu      sync.Mutex
+	path    string
+	budget  int64
+	removed bool
+}
+
+// NewScratchDir creates a new directory in root, os.TempDir() when root is empty.
+func NewScratchDir(root string, pattern string, budget int64) (*ScratchDir, error) {
+	if root != "" {
+		if err := os.MkdirAll(root, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	path, err := os.MkdirTemp(root, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &ScratchDir{path: path, budget: budget}, nil
+}
+
+func (s *ScratchDir) Path() string {
+	return s.path
+}
+
+func (s *ScratchDir) GetBudget() int64 {
+	return s.budget
+}
+
+// Usage returns the total size in bytes of the files in the directory.
+func (s *ScratchDir) Usage() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(s.path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Files may be removed while walking
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// Reserve fails fast with ErrDiskBudgetExceeded when writing size more bytes would exceed the budget.
+func (s *ScratchDir) Reserve(size int64) error {
+	if s.budget <= 0 {
+		return nil
+	}
+	usage, err := s.Usage()
+	if err != nil {
+		return err
+	}
+	if usage+size > s.budget {
+		return fmt.Errorf("%w: %d bytes used, %d bytes requested, budget is %d bytes", ErrDiskBudgetExceeded, usage, size, s.budget)
+	}
+	return nil
+}
+
+// CreateTemp creates a new temp file in the directory, see os.CreateTemp.
+func (s *ScratchDir) CreateTemp(pattern string) (*os.File, error) {
+	if err := s.Reserve(0); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(s.path, pattern)
+}
+
+// Watch returns a context that is cancelled with ErrDiskBudgetExceeded as its cause when the usage
+// of the directory exceeds the budget, e.g. to stop an external process writing to the directory.
+// The usage is checked at every interval until the returned cancel function is called.
+func (s *ScratchDir) Watch(ctx context.Context, interval time.Duration) (context.Context, context.CancelFunc) {
+	watchCtx, cancel := context.WithCancelCause(ctx)
+	if s.budget <= 0 {
+		return watchCtx, func() { cancel(context.Canceled) }
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-watchCtx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Reserve(0); errors.Is(err, ErrDiskBudgetExceeded) {
+					cancel(err)
+					return
+				}
+			}
+		}
+	}()
+	return watchCtx, func() { cancel(context.Canceled) }
+}
+
+// Remove removes the directory and all of its files, it's safe to call more than once.
+func (s *ScratchDir) Remove() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.removed {
+		return nil
+	}
+	s.removed = true
+	return os.RemoveAll(s.path)
+}
+
+// Run executes a command and always closes the context afterward, removing its temp
+// files and scratch directory. A panic of the command is recovered and recorded as an
+// error of the context. Run is used by the entry points of executions, such as listeners.
+func Run(command Command, chCtx Context) {
+	run(command, chCtx, command.Execute)
+}
+
+// RunOrResume is Run resuming the checkpointed execution of the CheckpointIDKey of the context
+// when the command is Resumable and the execution has a checkpoint. A checkpoint that can't be
+// restored is logged and the command executed from the start.
+func RunOrResume(command Command, chCtx Context) {
+	run(command, chCtx, func(chCtx Context) {
+		if resumable, ok := command.(Resumable); ok {
+			resumed, err := resumable.ResumeContext(chCtx)
+			if err != nil {
+				log.Printf("failed to resume %s, executing from the start: %v", command.GetName(), err)
+			} else if resumed {
+				return
+			}
+		}
+		command.Execute(chCtx)
+	})
+}
+
+func run(command Command, chCtx Context, execute func(chCtx Context)) {
+	defer chCtx.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			chCtx.AddError(command.GetName(), fmt.Errorf("%w: %v\n%s", ErrPanic, r, debug.Stack()))
+		}
+	}()
+	execute(chCtx)
+}
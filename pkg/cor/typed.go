@@ -0,0 +1,64 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrKeyNotFound is returned when a key is not present in the context.
+	ErrKeyNotFound = errors.New("key not found in context")
+	// ErrTypeMismatch is returned when a value is not of the requested type.
+	ErrTypeMismatch = errors.New("unexpected value type in context")
+)
+
+// Key is a context key bound to the type of its value, for example:
+//
+//	const MediaKey cor.Key[*model.Media] = "__MEDIA__"
+type Key[T any] string
+
+func (k Key[T]) String() string {
+	return string(k)
+}
+
+// Get returns the value of a key as type T, or an error when the key is missing
+// or the value is of a different type.
+func Get[T any](context Context, key string) (T, error) {
+	var zero T
+	if context == nil {
+		return zero, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	value := context.Get(key)
+	if value == nil {
+		return zero, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	out, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: %s is %T, expected %T", ErrTypeMismatch, key, value, zero)
+	}
+	return out, nil
+}
+
+// GetKey returns the value of a typed key.
+func GetKey[T any](context Context, key Key[T]) (T, error) {
+	return Get[T](context, string(key))
+}
+
+// Set adds the value of a typed key to the context.
+func Set[T any](context Context, key Key[T], value T) Context {
+	return context.Add(string(key), value)
+}
@@ -39,19 +39,22 @@ type Actor struct {
 
 // Media capture the highest level of metadata about a media file.
 type Media struct {
-	Id              string        `json:"id" bigquery:"id"`
-	CreateDate      time.Time     `json:"create_date" bigquery:"create_date"`
-	Title           string        `json:"title" bigquery:"title"`
-	Category        string        `json:"category" bigquery:"category"`
-	Summary         string        `json:"summary" bigquery:"summary"`
-	LengthInSeconds int           `json:"length_in_seconds" bigquery:"length_in_seconds"`
-	MediaUrl        string        `json:"media_url" bigquery:"media_url"`
-	Director        string        `json:"director,omitempty" bigquery:"director"`
-	ReleaseYear     int           `json:"release_year,omitempty" bigquery:"release_year"`
-	Genre           string        `json:"genre,omitempty" bigquery:"genre"`
-	Rating          string        `json:"rating,omitempty" bigquery:"rating"`
-	Cast            []*CastMember `json:"cast,omitempty" bigquery:"cast"`
-	Segments        []*Segment    `json:"segments,omitempty" bigquery:"segments"`
+	Id              string          `json:"id" bigquery:"id"`
+	CreateDate      time.Time       `json:"create_date" bigquery:"create_date"`
+	Title           string          `json:"title" bigquery:"title"`
+	Category        string          `json:"category" bigquery:"category"`
+	Summary         string          `json:"summary" bigquery:"summary"`
+	LengthInSeconds int             `json:"length_in_seconds" bigquery:"length_in_seconds"`
+	MediaUrl        string          `json:"media_url" bigquery:"media_url"`
+	Director        string          `json:"director,omitempty" bigquery:"director"`
+	ReleaseYear     int             `json:"release_year,omitempty" bigquery:"release_year"`
+	Genre           string          `json:"genre,omitempty" bigquery:"genre"`
+	Rating          string          `json:"rating,omitempty" bigquery:"rating"`
+	Cast            []*CastMember   `json:"cast,omitempty" bigquery:"cast"`
+	Segments        []*Segment      `json:"segments,omitempty" bigquery:"segments"`
+	ConfigVersion   string          `json:"config_version,omitempty" bigquery:"config_version"` // The version label of the configuration used by the analysis.
+	ConfigHash      string          `json:"config_hash,omitempty" bigquery:"config_hash"`       // The content hash of the configuration used by the analysis.
+	AnalysisSteps   []*AnalysisStep `json:"analysis_steps,omitempty" bigquery:"analysis_steps"` // The prompts and models used by each analysis step.
 }
 
 func NewMedia(fileName string) *Media {
@@ -76,6 +79,19 @@ type Segment struct {
 	Script           string `json:"script" bigquery:"script"`
 }
 
+// AnalysisStep records the prompt and the agent model an analysis step was executed with,
+// so media analyzed with an outdated prompt can be found and reprocessed.
+type AnalysisStep struct {
+	Step        string  `json:"step" bigquery:"step"`
+	PromptHash  string  `json:"prompt_hash" bigquery:"prompt_hash"`
+	AgentModel  string  `json:"agent_model" bigquery:"agent_model"`
+	Model       string  `json:"model" bigquery:"model"`
+	Temperature float64 `json:"temperature" bigquery:"temperature"`
+	TopP        float64 `json:"top_p" bigquery:"top_p"`
+	TopK        float64 `json:"top_k" bigquery:"top_k"`
+	MaxTokens   int     `json:"max_tokens" bigquery:"max_tokens"`
+}
+
 // CastMember is a mapping object from a character to an actor
 type CastMember struct {
 	CharacterName string `json:"character_name" bigquery:"character_name"`
@@ -0,0 +1,142 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+// GCSStore is the Google Cloud Storage implementation of ObjectStore.
+type GCSStore struct {
+	client *storage.Client
+}
+
+func NewGCSStore(client *storage.Client) *GCSStore {
+	return &GCSStore{client: client}
+}
+
+// GetClient returns the underlying storage client.
+func (s *GCSStore) GetClient() *storage.Client {
+	return s.client
+}
+
+func (s *GCSStore) NewReader(ctx context.Context, bucket string, name string) (io.ReadCloser, error) {
+	reader, err := s.client.Bucket(bucket).Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, convertError(bucket, name, err)
+	}
+	return reader, nil
+}
+
+func (s *GCSStore) Write(ctx context.Context, bucket string, name string, contentType string, content io.Reader) (*ObjectAttrs, error) {
+	return s.write(ctx, s.client.Bucket(bucket).Object(name), contentType, content)
+}
+
+func (s *GCSStore) WriteIfGenerationMatch(ctx context.Context, bucket string, name string, contentType string, content io.Reader, generation int64) (*ObjectAttrs, error) {
+	conditions := storage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		conditions = storage.Conditions{DoesNotExist: true}
+	}
+	attrs, err := s.write(ctx, s.client.Bucket(bucket).Object(name).If(conditions), contentType, content)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return nil, fmt.Errorf("%w: gs://%s/%s", ErrPreconditionFailed, bucket, name)
+	}
+	return attrs, err
+}
+
+func (s *GCSStore) write(ctx context.Context, object *storage.ObjectHandle, contentType string, content io.Reader) (*ObjectAttrs, error) {
+	writer := object.NewWriter(ctx)
+	writer.ContentType = contentType
+	if _, err := io.Copy(writer, content); err != nil {
+		_ = writer.Close()
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return fromGCSAttrs(writer.Attrs()), nil
+}
+
+func (s *GCSStore) Attrs(ctx context.Context, bucket string, name string) (*ObjectAttrs, error) {
+	attrs, err := s.client.Bucket(bucket).Object(name).Attrs(ctx)
+	if err != nil {
+		return nil, convertError(bucket, name, err)
+	}
+	return fromGCSAttrs(attrs), nil
+}
+
+func (s *GCSStore) UpdateMetadata(ctx context.Context, bucket string, name string, metadata map[string]string) (*ObjectAttrs, error) {
+	attrs, err := s.client.Bucket(bucket).Object(name).Update(ctx, storage.ObjectAttrsToUpdate{Metadata: metadata})
+	if err != nil {
+		return nil, convertError(bucket, name, err)
+	}
+	return fromGCSAttrs(attrs), nil
+}
+
+func (s *GCSStore) List(ctx context.Context, bucket string, prefix string) ([]*ObjectAttrs, error) {
+	it := s.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	var out []*ObjectAttrs
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, fromGCSAttrs(attrs))
+	}
+	return out, nil
+}
+
+func (s *GCSStore) Delete(ctx context.Context, bucket string, name string) error {
+	return convertError(bucket, name, s.client.Bucket(bucket).Object(name).Delete(ctx))
+}
+
+func (s *GCSStore) Close() error {
+	return s.client.Close()
+}
+
+func fromGCSAttrs(attrs *storage.ObjectAttrs) *ObjectAttrs {
+	if attrs == nil {
+		return nil
+	}
+	return &ObjectAttrs{
+		Bucket:         attrs.Bucket,
+		Name:           attrs.Name,
+		ContentType:    attrs.ContentType,
+		Size:           attrs.Size,
+		Generation:     attrs.Generation,
+		Metageneration: attrs.Metageneration,
+		Updated:        attrs.Updated,
+		Metadata:       attrs.Metadata,
+	}
+}
+
+func convertError(bucket string, name string, err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%w: gs://%s/%s", ErrObjectNotExist, bucket, name)
+	}
+	return err
+}
@@ -0,0 +1,343 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MetadataSuffix is the suffix of the sidecar files holding the attributes of local objects.
+const MetadataSuffix = ".metadata.json"
+
+// sidecar is the content of the metadata sidecar file of a local object.
+type sidecar struct {
+	ContentType    string            `json:"content_type"`
+	Generation     int64             `json:"generation"`
+	Metageneration int64             `json:"metageneration"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+}
+
+// LocalStore is an ObjectStore backed by a local directory, objects are stored in
+// <root>/<bucket>/<name> and their attributes in a <name>.metadata.json sidecar file.
+// The layout matches a GCS Fuse mount, so the root may be the mount point of the jobs.
+type LocalStore struct {
+	mu   sync.Mutex
+	root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if root == "" {
+		return nil, errors.New("the root directory of the local store is required")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{root: root}, nil
+}
+
+func (s *LocalStore) GetRoot() string {
+	return s.root
+}
+
+// bucketDir returns the local directory of a bucket, rejecting names that escape the root.
+func (s *LocalStore) bucketDir(bucket string) (string, error) {
+	if bucket == "" || strings.ContainsAny(bucket, `/\`) || bucket == "." || bucket == ".." {
+		return "", fmt.Errorf("invalid bucket name: %q", bucket)
+	}
+	return filepath.Join(s.root, bucket), nil
+}
+
+// path returns the local path of an object, rejecting names that escape the bucket directory.
+func (s *LocalStore) path(bucket string, name string) (string, error) {
+	bucketDir, err := s.bucketDir(bucket)
+	if err != nil {
+		return "", err
+	}
+	if name == "" || strings.HasSuffix(name, MetadataSuffix) || strings.HasSuffix(name, MetadataSuffix+".tmp") {
+		return "", fmt.Errorf("invalid object name: %q", name)
+	}
+	path := filepath.Join(bucketDir, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, bucketDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid object name: %q", name)
+	}
+	return path, nil
+}
+
+func (s *LocalStore) NewReader(_ context.Context, bucket string, name string) (io.ReadCloser, error) {
+	path, err := s.path(bucket, name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, convertLocalError(bucket, name, err)
+	}
+	return file, nil
+}
+
+func (s *LocalStore) Write(_ context.Context, bucket string, name string, contentType string, content io.Reader) (*ObjectAttrs, error) {
+	return s.write(bucket, name, contentType, content, anyGeneration)
+}
+
+func (s *LocalStore) WriteIfGenerationMatch(_ context.Context, bucket string, name string, contentType string, content io.Reader, generation int64) (*ObjectAttrs, error) {
+	return s.write(bucket, name, contentType, content, generation)
+}
+
+// anyGeneration makes write replace the object whatever its current generation.
+const anyGeneration = -1
+
+func (s *LocalStore) write(bucket string, name string, contentType string, content io.Reader, generation int64) (*ObjectAttrs, error) {
+	path, err := s.path(bucket, name)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	// Write to a temp file and rename, so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, content); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta, err := s.readSidecar(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if generation != anyGeneration {
+		current := int64(0)
+		if _, err = os.Stat(path); err == nil {
+			current = max(meta.Generation, 1)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if current != generation {
+			return nil, fmt.Errorf("%w: %s/%s", ErrPreconditionFailed, bucket, name)
+		}
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	// A new generation of an object starts without metadata, as in GCS
+	meta = &sidecar{ContentType: contentType, Generation: meta.Generation + 1, Metageneration: 1}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	if err = s.writeSidecar(path, meta); err != nil {
+		return nil, err
+	}
+	return s.attrs(bucket, name, path, meta)
+}
+
+func (s *LocalStore) Attrs(_ context.Context, bucket string, name string) (*ObjectAttrs, error) {
+	path, err := s.path(bucket, name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta, err := s.readSidecar(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return s.attrs(bucket, name, path, meta)
+}
+
+func (s *LocalStore) UpdateMetadata(_ context.Context, bucket string, name string, metadata map[string]string) (*ObjectAttrs, error) {
+	path, err := s.path(bucket, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(path); err != nil {
+		return nil, convertLocalError(bucket, name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta, err := s.readSidecar(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if meta.Metadata == nil {
+		meta.Metadata = make(map[string]string)
+	}
+	for key, value := range metadata {
+		if value == "" {
+			delete(meta.Metadata, key)
+		} else {
+			meta.Metadata[key] = value
+		}
+	}
+	meta.Metageneration++
+	if err = s.writeSidecar(path, meta); err != nil {
+		return nil, err
+	}
+	return s.attrs(bucket, name, path, meta)
+}
+
+func (s *LocalStore) List(_ context.Context, bucket string, prefix string) ([]*ObjectAttrs, error) {
+	bucketDir, err := s.bucketDir(bucket)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	err = filepath.WalkDir(bucketDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip the sidecars and the temp files of writes in progress
+		if entry.IsDir() || strings.HasSuffix(path, MetadataSuffix) || strings.HasSuffix(path, MetadataSuffix+".tmp") ||
+			strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(bucketDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*ObjectAttrs, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(bucketDir, filepath.FromSlash(name))
+		meta, err := s.readSidecar(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		attrs, err := s.attrs(bucket, name, path, meta)
+		if err != nil {
+			// The object was deleted while listing
+			if errors.Is(err, ErrObjectNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		out = append(out, attrs)
+	}
+	return out, nil
+}
+
+func (s *LocalStore) Delete(_ context.Context, bucket string, name string) error {
+	path, err := s.path(bucket, name)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = os.Remove(path); err != nil {
+		return convertLocalError(bucket, name, err)
+	}
+	if err = os.Remove(path + MetadataSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) Close() error {
+	return nil
+}
+
+// readSidecar reads the sidecar of an object, returning empty attributes and
+// fs.ErrNotExist for objects created outside the store, e.g. copied into the directory.
+func (s *LocalStore) readSidecar(path string) (*sidecar, error) {
+	data, err := os.ReadFile(path + MetadataSuffix)
+	if err != nil {
+		return &sidecar{}, err
+	}
+	meta := &sidecar{}
+	if err = json.Unmarshal(data, meta); err != nil {
+		return &sidecar{}, fmt.Errorf("invalid metadata file %s: %w", path+MetadataSuffix, err)
+	}
+	return meta, nil
+}
+
+func (s *LocalStore) writeSidecar(path string, meta *sidecar) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + MetadataSuffix + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path+MetadataSuffix)
+}
+
+func (s *LocalStore) attrs(bucket string, name string, path string, meta *sidecar) (*ObjectAttrs, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, convertLocalError(bucket, name, err)
+	}
+	contentType := meta.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	metadata := make(map[string]string, len(meta.Metadata))
+	for key, value := range meta.Metadata {
+		metadata[key] = value
+	}
+	return &ObjectAttrs{
+		Bucket:         bucket,
+		Name:           name,
+		ContentType:    contentType,
+		Size:           info.Size(),
+		Generation:     max(meta.Generation, 1),
+		Metageneration: max(meta.Metageneration, 1),
+		Updated:        info.ModTime(),
+		Metadata:       metadata,
+	}, nil
+}
+
+func convertLocalError(bucket string, name string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s/%s", ErrObjectNotExist, bucket, name)
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package objectstore abstracts the object storage used by the solution, so the
+// jobs and the API server can run against Google Cloud Storage or a local directory.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// Supported storage backends.
+const (
+	BackendGCS   = "gcs"
+	BackendLocal = "local"
+)
+
+var (
+	// ErrObjectNotExist is returned when an object does not exist.
+	ErrObjectNotExist = errors.New("object does not exist")
+	// ErrPreconditionFailed is returned by a conditional write when the generation doesn't match.
+	ErrPreconditionFailed = errors.New("object generation precondition failed")
+)
+
+// ObjectAttrs are the attributes of a stored object.
+type ObjectAttrs struct {
+	Bucket         string
+	Name           string
+	ContentType    string
+	Size           int64
+	Generation     int64
+	Metageneration int64
+	Updated        time.Time
+	Metadata       map[string]string
+}
+
+// ObjectStore is the interface of an object storage backend.
+type ObjectStore interface {
+	// NewReader returns a reader of the object content, it must be closed by the caller.
+	NewReader(ctx context.Context, bucket string, name string) (io.ReadCloser, error)
+	// Write creates or replaces an object with the content of the reader.
+	Write(ctx context.Context, bucket string, name string, contentType string, content io.Reader) (*ObjectAttrs, error)
+	// WriteIfGenerationMatch writes the object only if its current generation is the given
+	// generation, 0 only creates the object, otherwise it returns ErrPreconditionFailed.
+	WriteIfGenerationMatch(ctx context.Context, bucket string, name string, contentType string, content io.Reader, generation int64) (*ObjectAttrs, error)
+	// Attrs returns the attributes of an object.
+	Attrs(ctx context.Context, bucket string, name string) (*ObjectAttrs, error)
+	// UpdateMetadata merges the metadata into the metadata of an object, keys with an empty value are removed.
+	UpdateMetadata(ctx context.Context, bucket string, name string, metadata map[string]string) (*ObjectAttrs, error)
+	// List returns the attributes of the objects whose name starts with the prefix, ordered by name.
+	List(ctx context.Context, bucket string, prefix string) ([]*ObjectAttrs, error)
+	// Delete removes an object.
+	Delete(ctx context.Context, bucket string, name string) error
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// New creates the object store of a backend, localRoot is the root directory of the local backend.
+func New(ctx context.Context, backend string, localRoot string) (ObjectStore, error) {
+	switch backend {
+	case "", BackendGCS:
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return NewGCSStore(client), nil
+	case BackendLocal:
+		return NewLocalStore(localRoot)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// ReadAll reads the content of an object.
+func ReadAll(ctx context.Context, store ObjectStore, bucket string, name string) ([]byte, error) {
+	reader, err := store.NewReader(ctx, bucket, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -23,6 +23,7 @@ import (
 
 	"cloud.google.com/go/bigquery"
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/model"
+	"google.golang.org/api/iterator"
 )
 
 type MediaService struct {
@@ -64,3 +65,30 @@ func (s *MediaService) GetSegment(ctx context.Context, id string, segmentSequenc
 	err = itr.Next(segment)
 	return segment, err
 }
+
+// FindOutdated returns the media whose analysis step wasn't executed with the prompt of the hash,
+// including media analyzed before provenance was recorded, so they can be reprocessed.
+// Only the id, create date, title, media url and configuration version fields are set.
+func (s *MediaService) FindOutdated(ctx context.Context, step string, promptHash string) (out []*model.Media, err error) {
+	q := s.BigqueryClient.Query(fmt.Sprintf(QryFindOutdated, s.GetFQN()))
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "step", Value: step},
+		{Name: "prompt_hash", Value: promptHash},
+	}
+	itr, err := q.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out = make([]*model.Media, 0)
+	for {
+		media := &model.Media{}
+		err = itr.Next(media)
+		if err == iterator.Done {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, media)
+	}
+}
@@ -20,4 +20,5 @@ const (
 	QrySequenceKnn   = "SELECT base.media_id, base.sequence_number FROM VECTOR_SEARCH(TABLE `%s`, 'embeddings', (SELECT [ %s ] as embed), top_k => %d, distance_type => 'EUCLIDEAN') ORDER BY distance asc"
 	QryFindMediaById = "SELECT * from `%s` WHERE id = '%s'"
 	QryGetSegment    = "SELECT sequence, start, `end`, script FROM `%s`, UNNEST(segments) as s WHERE id = '%s' and s.sequence = %d"
+	QryFindOutdated  = "SELECT id, create_date, title, media_url, config_version, config_hash FROM `%s` WHERE NOT EXISTS (SELECT 1 FROM UNNEST(analysis_steps) as a WHERE a.step = @step and a.prompt_hash = @prompt_hash) ORDER BY create_date"
 )
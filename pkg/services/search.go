@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"cloud.google.com/go/bigquery"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/model"
 	"google.golang.org/api/iterator"
 	"google.golang.org/genai"
@@ -31,7 +32,7 @@ import (
 
 type SearchService struct {
 	BigqueryClient *bigquery.Client
-	EmbeddingModel *genai.Models
+	EmbeddingModel cloud.EmbeddingModel
 	ModelName      string
 	DatasetName    string
 	MediaTable     string
@@ -45,7 +46,10 @@ func (s *SearchService) FindSegments(ctx context.Context, query string, maxResul
 	contents := []*genai.Content{
 		genai.NewContentFromText(query, genai.RoleUser),
 	}
-	searchEmbeddings, _ := s.EmbeddingModel.EmbedContent(ctx, s.ModelName, contents, nil)
+	searchEmbeddings, err := s.EmbeddingModel.EmbedContent(ctx, s.ModelName, contents, nil)
+	if err != nil {
+		return out, err
+	}
 
 	fqEmbeddingTable := strings.Replace(s.BigqueryClient.Dataset(s.DatasetName).Table(s.EmbeddingTable).FullyQualifiedName(), ":", ".", -1)
 
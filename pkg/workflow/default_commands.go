@@ -0,0 +1,66 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workflow
+
+import (
+	"log"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/commands"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+)
+
+// Registered names of the default commands.
+const (
+	MediaTriggerToGCSObjectCommand = "media-trigger-to-gcs-object"
+	MediaConfigUpdateCommand       = "media-config-update"
+)
+
+// RegisterDefaultCommands registers the commands of the commands package, the config update
+// command reloads the given snapshot.
+func RegisterDefaultCommands(registry *Registry, snapshot *cloud.ConfigSnapshot) {
+	registry.Register(MediaTriggerToGCSObjectCommand, func(step StepParams) (cor.Command, error) {
+		command := commands.NewMediaTriggerToGCSObject(step.Name)
+		step.Apply(&command.BaseCommand)
+		return command, nil
+	})
+
+	registry.Register(MediaConfigUpdateCommand, func(step StepParams) (cor.Command, error) {
+		command := commands.NewMediaConfigUpdateCommand(step.Name, snapshot)
+		step.Apply(&command.BaseCommand)
+		return command, nil
+	})
+}
+
+// NewRegistryFromConfig creates a registry with the default commands and builds the workflows of the
+// current config, checkpointed workflows use the given checkpoint store which may be nil. A reloaded
+// config is rejected when its workflows are invalid, otherwise the workflows are rebuilt.
+func NewRegistryFromConfig(snapshot *cloud.ConfigSnapshot, checkpointStore cor.CheckpointStore) (*Registry, error) {
+	registry := NewRegistry()
+	registry.SetCheckpointStore(checkpointStore)
+	RegisterDefaultCommands(registry, snapshot)
+	if err := registry.Build(snapshot.Get().Workflows); err != nil {
+		return nil, err
+	}
+	snapshot.AddValidator(func(config *cloud.Config) error {
+		return registry.Validate(config.Workflows)
+	})
+	snapshot.AddListener(func(config *cloud.Config) {
+		if err := registry.Build(config.Workflows); err != nil {
+			log.Printf("failed to rebuild workflows, keeping previous workflows: %v", err)
+		}
+	})
+	return registry, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workflow
+
+import (
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/commands"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+)
+
+// MediaAnalysisWorkflow analyzes each proxy written to the low resolution bucket.
+type MediaAnalysisWorkflow struct {
+	cor.BaseCommand
+	chain    cor.Chain
+	snapshot *cloud.ConfigSnapshot
+	clients  *cloud.ServiceClients
+}
+
+func (m *MediaAnalysisWorkflow) Execute(context cor.Context) {
+	m.chain.Execute(context)
+}
+
+func (m *MediaAnalysisWorkflow) initializeChain() {
+	out := cor.NewBaseChain(m.GetName())
+
+	out.AddCommand(commands.NewMediaTriggerToGCSObject("gcs-topic-listener"))
+
+	out.AddCommand(commands.NewMediaAnalysisCommand("analysis-command", m.snapshot, m.clients))
+
+	m.chain = out
+}
+
+func NewMediaAnalysisWorkflow(snapshot *cloud.ConfigSnapshot, clients *cloud.ServiceClients) *MediaAnalysisWorkflow {
+	out := &MediaAnalysisWorkflow{
+		BaseCommand: *cor.NewBaseCommand("media-analysis-workflow"),
+		snapshot:    snapshot,
+		clients:     clients}
+	out.initializeChain()
+	return out
+}
@@ -24,9 +24,8 @@ import (
 
 type MediaConfigUpdateWorkflow struct {
 	cor.BaseCommand
-	chain           cor.Chain
-	config          *cloud.Config
-	templateService *cloud.TemplateService
+	chain    cor.Chain
+	snapshot *cloud.ConfigSnapshot
 }
 
 func (m *MediaConfigUpdateWorkflow) Execute(context cor.Context) {
@@ -37,16 +36,15 @@ func (m *MediaConfigUpdateWorkflow) initializeChain() {
 
 	out.AddCommand(commands.NewMediaTriggerToGCSObject("gcs-topic-listener"))
 
-	out.AddCommand(commands.NewMediaConfigUpdateCommand("config-update-command", m.config, m.templateService))
+	out.AddCommand(commands.NewMediaConfigUpdateCommand("config-update-command", m.snapshot))
 
 	m.chain = out
 }
 
-func NewMediaConfigUpdateWorkflow(config *cloud.Config, templateService *cloud.TemplateService) *MediaConfigUpdateWorkflow {
+func NewMediaConfigUpdateWorkflow(snapshot *cloud.ConfigSnapshot) *MediaConfigUpdateWorkflow {
 	out := &MediaConfigUpdateWorkflow{
-		BaseCommand:     *cor.NewBaseCommand("media-config-update-workflow"),
-		config:          config,
-		templateService: templateService}
+		BaseCommand: *cor.NewBaseCommand("media-config-update-workflow"),
+		snapshot:    snapshot}
 	out.initializeChain()
 	return out
 }
@@ -0,0 +1,356 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+)
+
+// StepParams are the values of a workflow step passed to a command factory.
+type StepParams struct {
+	Name        string
+	InputParam  string
+	OutputParam string
+}
+
+// Apply sets the name and the input and output parameter names of the step on a base command.
+func (p StepParams) Apply(command *cor.BaseCommand) {
+	command.Name = p.Name
+	command.InputParamName = p.InputParam
+	command.OutputParamName = p.OutputParam
+}
+
+// CommandFactory creates a new instance of a registered command.
+type CommandFactory func(step StepParams) (cor.Command, error)
+
+// Registry holds the command factories available to declarative workflows
+// and the workflows built from the configuration.
+type Registry struct {
+	mu              sync.RWMutex
+	factories       map[string]CommandFactory
+	workflows       map[string]cor.Chain
+	checkpointStore cor.CheckpointStore
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[string]CommandFactory),
+		workflows: make(map[string]cor.Chain),
+	}
+}
+
+// Register adds a command factory under a name, replacing any previous registration.
+func (r *Registry) Register(name string, factory CommandFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[name] = factory
+}
+
+// SetCheckpointStore sets the store used by workflows declared with checkpoint = true,
+// it applies to workflows built after it's set.
+func (r *Registry) SetCheckpointStore(store cor.CheckpointStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkpointStore = store
+}
+
+// GetCommandNames returns the sorted names of the registered commands.
+func (r *Registry) GetCommandNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the definitions for unknown commands, unknown workflow references,
+// malformed steps and reference cycles, returning all problems found.
+func (r *Registry) Validate(definitions map[string]cloud.WorkflowDefinition) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs []error
+	for _, name := range sortedWorkflowNames(definitions) {
+		definition := definitions[name]
+		path := "workflows." + name
+		if len(definition.Steps) == 0 {
+			errs = append(errs, fmt.Errorf("%s: workflow has no steps", path))
+		}
+		if definition.Checkpoint && definition.Parallel {
+			errs = append(errs, fmt.Errorf("%s: checkpoint is only supported on sequential workflows", path))
+		}
+		errs = append(errs, r.validateSteps(path, definition.Steps, definitions)...)
+	}
+
+	// Detect cycles in workflow references
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(name string, trail []string)
+	visit = func(name string, trail []string) {
+		switch state[name] {
+		case visiting:
+			errs = append(errs, fmt.Errorf("workflows.%s: cycle detected: %v", name, append(trail, name)))
+			return
+		case visited:
+			return
+		}
+		state[name] = visiting
+		for _, ref := range workflowReferences(definitions[name].Steps) {
+			if _, ok := definitions[ref]; ok {
+				visit(ref, append(trail, name))
+			}
+		}
+		state[name] = visited
+	}
+	for _, name := range sortedWorkflowNames(definitions) {
+		visit(name, nil)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Registry) validateSteps(path string, steps []cloud.WorkflowDefinition, definitions map[string]cloud.WorkflowDefinition) []error {
+	var errs []error
+	for i, step := range steps {
+		stepPath := fmt.Sprintf("%s.steps[%d]", path, i)
+		kinds := 0
+		if step.Command != "" {
+			kinds++
+			if _, ok := r.factories[step.Command]; !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown command %q", stepPath, step.Command))
+			}
+		}
+		if step.Workflow != "" {
+			kinds++
+			if _, ok := definitions[step.Workflow]; !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown workflow %q", stepPath, step.Workflow))
+			}
+		}
+		if len(step.Steps) > 0 {
+			kinds++
+			errs = append(errs, r.validateSteps(stepPath, step.Steps, definitions)...)
+		}
+		if kinds != 1 {
+			errs = append(errs, fmt.Errorf("%s: exactly one of command, workflow or steps must be set", stepPath))
+		}
+	}
+	return errs
+}
+
+// Build validates the definitions and replaces the built workflows. When validation
+// or construction fails the previously built workflows are kept.
+func (r *Registry) Build(definitions map[string]cloud.WorkflowDefinition) error {
+	if err := r.Validate(definitions); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	workflows := make(map[string]cor.Chain, len(definitions))
+	var err error
+	for name, definition := range definitions {
+		var chain cor.Chain
+		chain, err = r.buildChain(name, definition, definitions)
+		if err != nil {
+			break
+		}
+		workflows[name] = chain
+	}
+	r.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.workflows = workflows
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Registry) buildChain(name string, definition cloud.WorkflowDefinition, definitions map[string]cloud.WorkflowDefinition) (cor.Chain, error) {
+	var chain cor.Chain
+	var base *cor.BaseCommand
+	if definition.Parallel {
+		parallel := cor.NewParallelChain(name, definition.MaxConcurrency)
+		chain, base = parallel, &parallel.BaseCommand
+	} else {
+		sequential := cor.NewBaseChain(name)
+		if definition.Checkpoint {
+			sequential.SetCheckpointStore(r.checkpointStore)
+		}
+		chain, base = sequential, &sequential.BaseCommand
+	}
+	base.InputParamName = definition.InputParam
+	base.OutputParamName = definition.OutputParam
+	chain.ContinueOnFailure(definition.ContinueOnFailure)
+
+	for i, step := range definition.Steps {
+		if step.Name == "" && len(step.Steps) > 0 {
+			step.Name = fmt.Sprintf("%s_%d", name, i)
+		}
+		command, err := r.buildStep(step, definitions)
+		if err != nil {
+			return nil, fmt.Errorf("workflow %s: %w", name, err)
+		}
+		chain.AddCommand(command)
+	}
+	return chain, nil
+}
+
+func (r *Registry) buildStep(step cloud.WorkflowDefinition, definitions map[string]cloud.WorkflowDefinition) (cor.Command, error) {
+	switch {
+	case step.Command != "":
+		name := step.Name
+		if name == "" {
+			name = step.Command
+		}
+		return r.factories[step.Command](StepParams{Name: name, InputParam: step.InputParam, OutputParam: step.OutputParam})
+	case step.Workflow != "":
+		// A referenced workflow may override the parameter names of its definition,
+		// only top level workflows are checkpointed.
+		referenced := definitions[step.Workflow]
+		referenced.Checkpoint = false
+		if step.InputParam != "" {
+			referenced.InputParam = step.InputParam
+		}
+		if step.OutputParam != "" {
+			referenced.OutputParam = step.OutputParam
+		}
+		name := step.Name
+		if name == "" {
+			name = step.Workflow
+		}
+		return r.buildChain(name, referenced, definitions)
+	default:
+		return r.buildChain(step.Name, step, definitions)
+	}
+}
+
+// Explain returns the execution plan of a built workflow for a sample context.
+func (r *Registry) Explain(name string, sample cor.Context) (*cor.Plan, error) {
+	chain := r.Get(name)
+	if chain == nil {
+		return nil, fmt.Errorf("workflow not found: %s", name)
+	}
+	return chain.Explain(sample), nil
+}
+
+// Get returns the currently built workflow, or nil if it does not exist.
+func (r *Registry) Get(name string) cor.Chain {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.workflows[name]
+}
+
+// Workflow returns a command that executes the most recently built workflow of the given name,
+// this allows listeners to keep a single command while the workflows are rebuilt on config reload.
+func (r *Registry) Workflow(name string) cor.Command {
+	return &registryWorkflow{BaseCommand: *cor.NewBaseCommand(name), registry: r}
+}
+
+type registryWorkflow struct {
+	cor.BaseCommand
+	registry *Registry
+}
+
+func (w *registryWorkflow) IsExecutable(context cor.Context) bool {
+	return context != nil && context.GetContext() != nil
+}
+
+// Unwrap returns the most recently built workflow, or nil if it does not exist.
+func (w *registryWorkflow) Unwrap() cor.Command {
+	if chain := w.registry.Get(w.GetName()); chain != nil {
+		return chain
+	}
+	return nil
+}
+
+func (w *registryWorkflow) Execute(context cor.Context) {
+	chain := w.registry.Get(w.GetName())
+	if chain == nil {
+		w.GetErrorCounter().Add(context.GetContext(), 1)
+		context.AddError(w.GetName(), fmt.Errorf("workflow not found: %s", w.GetName()))
+		return
+	}
+	chain.Execute(context)
+}
+
+// PendingCheckpoints returns the IDs of the incomplete executions of the workflow,
+// when the workflow is checkpointed.
+func (w *registryWorkflow) PendingCheckpoints(ctx context.Context) ([]string, error) {
+	if resumable, ok := w.registry.Get(w.GetName()).(cor.Resumable); ok {
+		return resumable.PendingCheckpoints(ctx)
+	}
+	return nil, nil
+}
+
+// Resume resumes a checkpointed execution of the most recently built workflow.
+func (w *registryWorkflow) Resume(ctx context.Context, id string) (cor.Context, error) {
+	resumable, ok := w.registry.Get(w.GetName()).(cor.Resumable)
+	if !ok {
+		return nil, fmt.Errorf("workflow can't be resumed: %s", w.GetName())
+	}
+	return resumable.Resume(ctx, id)
+}
+
+// ResumeContext resumes a checkpointed execution of the most recently built workflow on the
+// context, it returns false when the workflow isn't checkpointed or has no checkpoint.
+func (w *registryWorkflow) ResumeContext(chCtx cor.Context) (bool, error) {
+	if resumable, ok := w.registry.Get(w.GetName()).(cor.Resumable); ok {
+		return resumable.ResumeContext(chCtx)
+	}
+	return false, nil
+}
+
+// DeleteCheckpoint deletes a checkpoint of the workflow, when the workflow is checkpointed.
+func (w *registryWorkflow) DeleteCheckpoint(ctx context.Context, id string) error {
+	if resumable, ok := w.registry.Get(w.GetName()).(cor.Resumable); ok {
+		return resumable.DeleteCheckpoint(ctx, id)
+	}
+	return nil
+}
+
+func sortedWorkflowNames(definitions map[string]cloud.WorkflowDefinition) []string {
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func workflowReferences(steps []cloud.WorkflowDefinition) []string {
+	var out []string
+	for _, step := range steps {
+		if step.Workflow != "" {
+			out = append(out, step.Workflow)
+		}
+		out = append(out, workflowReferences(step.Steps)...)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	err := cloud.ApplyEnvOverrides(config, []string{
+		"PATH=/usr/bin",
+		"MSS__APPLICATION__GOOGLE_PROJECT_ID=other-project",
+		"MSS__STORAGE__HIGH_RES_INPUT_BUCKET=other-bucket",
+		"MSS__APPLICATION__WATCH_CONFIG=true",
+		"MSS__AGENT_MODELS__CREATIVE_FLASH__MODEL=gemini-2.5-pro",
+		"MSS__AGENT_MODELS__CREATIVE_FLASH__TEMPERATURE=0.5",
+		"MSS__AGENT_MODELS__CREATIVE_FLASH__RETRY__RETRY_ON=quota, server",
+		"MSS__AGENT_MODELS__CRITICAL_FLASH__MODEL=gemini-2.5-flash",
+		"MSS__CONTENT_TYPE__TYPES=trailer",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "other-project", config.Application.GoogleProjectId)
+	assert.Equal(t, "other-bucket", config.Storage.HiResInputBucket)
+	assert.True(t, config.Application.WatchConfig)
+	assert.Equal(t, "gemini-2.5-pro", config.AgentModels["creative-flash"].Model)
+	assert.Equal(t, float32(0.5), config.AgentModels["creative-flash"].Temperature)
+	assert.Equal(t, 200, config.AgentModels["creative-flash"].RequestsPerMinute)
+	assert.Equal(t, []string{"quota", "server"}, config.AgentModels["creative-flash"].Retry.RetryOn)
+	assert.Equal(t, "gemini-2.5-flash", config.AgentModels["critical_flash"].Model)
+	assert.Nil(t, config.Validate())
+}
+
+func TestApplyEnvOverridesReportsUnknownKeys(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	err := cloud.ApplyEnvOverrides(config, []string{
+		"MSS__STORAGE__HIRES_INPUT_BUCKET=other-bucket",
+		"MSS__STORAGE__LOW_RES_OUTPUT_BUCKET=other-bucket",
+	})
+	assert.ErrorIs(t, err, cloud.ErrUndecodedKey)
+	assert.Contains(t, err.Error(), "MSS__STORAGE__HIRES_INPUT_BUCKET")
+	assert.Equal(t, "other-bucket", config.Storage.LowResOutputBucket)
+
+	err = cloud.ApplyEnvOverrides(config, []string{"MSS__APPLICATION__THREAD_POOL_SIZE=many"})
+	assert.NotNil(t, err)
+	assert.NotErrorIs(t, err, cloud.ErrUndecodedKey)
+}
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "openai-key"), []byte("secret-value\n"), 0600))
+
+	config := loadConfig(t, validConfig)
+	llm := config.AgentModels[cloud.DefaultAgentModel]
+	llm.APIKey = "${secret:openai-key}"
+	config.AgentModels[cloud.DefaultAgentModel] = llm
+	config.Application.Name = "prefix-${secret:openai-key}"
+
+	assert.Nil(t, cloud.ResolveSecrets(context.Background(), config, cloud.NewFileSecretProvider(dir)))
+	assert.Equal(t, "secret-value", config.AgentModels[cloud.DefaultAgentModel].APIKey)
+	assert.Equal(t, "prefix-secret-value", config.Application.Name)
+
+	config.Storage.HiResInputBucket = "${secret:missing}"
+	assert.NotNil(t, cloud.ResolveSecrets(context.Background(), config, cloud.NewFileSecretProvider(dir)))
+	config.Storage.HiResInputBucket = "${secret:../openai-key}"
+	assert.NotNil(t, cloud.ResolveSecrets(context.Background(), config, cloud.NewFileSecretProvider(dir)))
+	assert.NotNil(t, cloud.ResolveSecrets(context.Background(), config, nil))
+}
+
+func TestLoadConfigFilesAppliesEnvOverridesAndSecrets(t *testing.T) {
+	secretDir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(secretDir, "bucket"), []byte("secret-bucket"), 0600))
+	dir := t.TempDir()
+	writeConfig(t, dir, ".env.toml", validConfig)
+	t.Setenv("MSS__SECRETS__PROVIDER", cloud.SecretProviderFile)
+	t.Setenv("MSS__SECRETS__PATH", secretDir)
+	t.Setenv("MSS__STORAGE__LOW_RES_OUTPUT_BUCKET", "${secret:bucket}")
+
+	config := cloud.NewConfig()
+	assert.Nil(t, cloud.LoadConfigFiles(dir, "local", config))
+	assert.Equal(t, "secret-bucket", config.Storage.LowResOutputBucket)
+
+	t.Setenv("MSS__SECRETS__PROVIDER", "vault")
+	assert.NotNil(t, cloud.LoadConfigFiles(dir, "local", cloud.NewConfig()))
+}
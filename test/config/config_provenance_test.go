@@ -0,0 +1,96 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigHashAndVersion(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	hash := config.Hash()
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, loadConfig(t, validConfig).Hash())
+	assert.Equal(t, hash[:cloud.HashLength], config.Version())
+
+	config.Application.ConfigVersion = "2025-06-prompts"
+	assert.Equal(t, "2025-06-prompts", config.Version())
+	assert.NotEqual(t, hash, config.Hash())
+}
+
+func TestConfigHashDoesNotDependOnSecrets(t *testing.T) {
+	withSecret := func(value string) *cloud.Config {
+		dir := t.TempDir()
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "openai-key"), []byte(value), 0600))
+		config := loadConfig(t, validConfig)
+		llm := config.AgentModels[cloud.DefaultAgentModel]
+		llm.APIKey = "${secret:openai-key}"
+		config.AgentModels[cloud.DefaultAgentModel] = llm
+		assert.Nil(t, cloud.ResolveSecrets(context.Background(), config, cloud.NewFileSecretProvider(dir)))
+		return config
+	}
+	first := withSecret("first-key")
+	assert.Equal(t, "first-key", first.AgentModels[cloud.DefaultAgentModel].APIKey)
+	assert.Equal(t, first.Hash(), withSecret("rotated-key").Hash())
+
+	// The hash is the one of the unresolved configuration
+	unresolved := loadConfig(t, validConfig)
+	llm := unresolved.AgentModels[cloud.DefaultAgentModel]
+	llm.APIKey = "${secret:openai-key}"
+	unresolved.AgentModels[cloud.DefaultAgentModel] = llm
+	assert.Equal(t, unresolved.Hash(), first.Hash())
+
+	// Other keys still change the hash
+	first.Storage.HiResInputBucket = "other"
+	assert.NotEqual(t, unresolved.Hash(), first.Hash())
+}
+
+func TestPromptHashChangesWithPrompt(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	summary := config.PromptHash(cloud.PromptSummary, "trailer", cloud.DefaultAgentModel)
+	segment := config.PromptHash(cloud.PromptSegment, "trailer", cloud.DefaultAgentModel)
+	contentType := config.PromptHash(cloud.PromptContentType, "trailer", cloud.DefaultAgentModel)
+	assert.NotEqual(t, summary, segment)
+	assert.NotEqual(t, summary, contentType)
+
+	templates := config.PromptTemplates["trailer"]
+	templates.SegmentPrompt = "Describe {{ .SEGMENT }} in detail"
+	config.PromptTemplates["trailer"] = templates
+	assert.Equal(t, summary, config.PromptHash(cloud.PromptSummary, "trailer", cloud.DefaultAgentModel))
+	assert.NotEqual(t, segment, config.PromptHash(cloud.PromptSegment, "trailer", cloud.DefaultAgentModel))
+	assert.Equal(t, contentType, config.PromptHash(cloud.PromptContentType, "sports", cloud.DefaultAgentModel))
+}
+
+func TestNewAnalysisStepRecordsModel(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	llm := config.AgentModels[cloud.DefaultAgentModel]
+	llm.Temperature = 0.8
+	llm.MaxTokens = 1024
+	config.AgentModels[cloud.DefaultAgentModel] = llm
+
+	step := config.NewAnalysisStep("ims_content_summary", cloud.PromptSummary, "trailer", cloud.DefaultAgentModel)
+	assert.Equal(t, "ims_content_summary", step.Step)
+	assert.Equal(t, config.PromptHash(cloud.PromptSummary, "trailer", cloud.DefaultAgentModel), step.PromptHash)
+	assert.Equal(t, cloud.DefaultAgentModel, step.AgentModel)
+	assert.Equal(t, "gemini-2.5-flash", step.Model)
+	assert.Equal(t, 0.8, step.Temperature)
+	assert.Equal(t, 1024, step.MaxTokens)
+}
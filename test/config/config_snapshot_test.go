@@ -0,0 +1,119 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotSwapKeepsConfigWhenInvalid(t *testing.T) {
+	current := loadConfig(t, validConfig)
+	snapshot := cloud.NewConfigSnapshot(current)
+	var notified []*cloud.Config
+	snapshot.AddListener(func(config *cloud.Config) {
+		notified = append(notified, config)
+	})
+
+	invalid := loadConfig(t, validConfig)
+	invalid.Storage.HiResInputBucket = ""
+	assert.NotNil(t, snapshot.Swap(invalid))
+	assert.Same(t, current, snapshot.Get())
+	assert.Empty(t, notified)
+
+	updated := loadConfig(t, validConfig)
+	assert.Nil(t, snapshot.Swap(updated))
+	assert.Same(t, updated, snapshot.Get())
+	assert.Equal(t, []*cloud.Config{updated}, notified)
+	// The previous config isn't modified by a swap
+	assert.Equal(t, "high", current.Storage.HiResInputBucket)
+}
+
+func TestTemplateServiceFollowsSnapshot(t *testing.T) {
+	snapshot := cloud.NewConfigSnapshot(loadConfig(t, validConfig))
+	service := cloud.NewTemplateServiceFromSnapshot(snapshot)
+	assert.NotNil(t, service.GetTemplateBy("trailer"))
+
+	updated := loadConfig(t, validConfig)
+	updated.PromptTemplates["trailer"] = cloud.PromptTemplates{SystemInstructions: "edited", SummaryPrompt: "summary", SegmentPrompt: "segment"}
+	assert.Nil(t, snapshot.Swap(updated))
+	assert.Equal(t, "edited", service.GetTemplateBy("trailer").SystemInstructions)
+}
+
+func TestSnapshotValidators(t *testing.T) {
+	current := loadConfig(t, validConfig)
+	snapshot := cloud.NewConfigSnapshot(current)
+	snapshot.AddValidator(func(config *cloud.Config) error {
+		if config.Application.Name == "" {
+			return errors.New("application.name: value is required")
+		}
+		return nil
+	})
+
+	assert.ErrorContains(t, snapshot.Swap(loadConfig(t, validConfig)), "application.name")
+	assert.Same(t, current, snapshot.Get())
+}
+
+func TestSnapshotReload(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, ".env.toml", validConfig)
+	writeConfig(t, dir, ".env.local.toml", "[storage]\nhigh_res_input_bucket = \"override\"\n")
+
+	snapshot := cloud.NewConfigSnapshot(cloud.NewConfig())
+	assert.Nil(t, snapshot.Reload(dir, "local"))
+	assert.Equal(t, "override", snapshot.Get().Storage.HiResInputBucket)
+
+	// A malformed file is rejected and the current config is kept
+	writeConfig(t, dir, ".env.local.toml", "[storage\n")
+	assert.NotNil(t, snapshot.Reload(dir, "local"))
+	assert.Equal(t, "override", snapshot.Get().Storage.HiResInputBucket)
+
+	// So is a file with unknown keys
+	writeConfig(t, dir, ".env.local.toml", "[storage]\nhigh_res_input_bucket = \"unknown\"\nunknown = 1\n")
+	assert.ErrorIs(t, snapshot.Reload(dir, "local"), cloud.ErrUndecodedKey)
+	assert.Equal(t, "override", snapshot.Get().Storage.HiResInputBucket)
+}
+
+func TestWatchConfigFilesReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, ".env.toml", validConfig)
+	snapshot := cloud.NewConfigSnapshot(loadConfig(t, validConfig))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.Nil(t, cloud.WatchConfigFiles(ctx, snapshot, dir, "local"))
+
+	// An invalid change is ignored
+	writeConfig(t, dir, ".env.local.toml", "[storage]\nhigh_res_input_bucket = \"\"\n")
+	time.Sleep(2 * cloud.ConfigWatchDelay)
+	assert.Equal(t, "high", snapshot.Get().Storage.HiResInputBucket)
+
+	writeConfig(t, dir, ".env.local.toml", "[storage]\nhigh_res_input_bucket = \"watched\"\n")
+	assert.Eventually(t, func() bool {
+		return snapshot.Get().Storage.HiResInputBucket == "watched"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Other files of the directory are ignored
+	writeConfig(t, dir, "notes.txt", strings.Repeat("x", 10))
+	writeConfig(t, dir, ".env.prod.toml", "[storage]\nhigh_res_input_bucket = \"prod\"\n")
+	time.Sleep(2 * cloud.ConfigWatchDelay)
+	assert.Equal(t, "watched", snapshot.Get().Storage.HiResInputBucket)
+}
@@ -0,0 +1,196 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/stretchr/testify/assert"
+)
+
+const validConfig = `
+[application]
+google_project_id = "project"
+
+[storage]
+high_res_input_bucket = "high"
+low_res_output_bucket = "low"
+
+[big_query_data_source]
+dataset = "media"
+media_table = "media"
+embedding_table = "embeddings"
+
+[embedding_models.multi-lingual]
+model = "text-embedding-005"
+max_requests_per_minute = 100
+
+[agent_models.creative-flash]
+model = "gemini-2.5-flash"
+requests_per_minute = 200
+
+[prompt_templates.trailer]
+summary = "Summarize {{ .CATEGORIES }}"
+segment = "Describe {{ .SEGMENT }}"
+
+[content_type]
+types = ["trailer"]
+default_type = "trailer"
+prompt_template = "One of {{ .CONTENT_TYPES }}"
+`
+
+func writeConfig(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func loadConfig(t *testing.T, content string) *cloud.Config {
+	config := cloud.NewConfig()
+	assert.Nil(t, cloud.DecodeConfigFile(writeConfig(t, t.TempDir(), ".env.toml", content), config))
+	return config
+}
+
+func TestValidConfig(t *testing.T) {
+	assert.Nil(t, loadConfig(t, validConfig).Validate())
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	config.Storage.HiResInputBucket = ""
+	config.ContentType.Types = append(config.ContentType.Types, "sports")
+	config.ContentType.DefaultType = "news"
+	config.PromptTemplates["trailer"] = cloud.PromptTemplates{SummaryPrompt: "{{ .Broken", SegmentPrompt: "segment"}
+	delete(config.AgentModels, cloud.DefaultAgentModel)
+	config.AgentModels["pro"] = cloud.VertexAiLLMModel{Model: "gemini-2.5-pro", Provider: "unknown", Retry: cloud.RetryPolicy{FallbackModel: "missing"}}
+
+	err := config.Validate()
+	assert.NotNil(t, err)
+	problems := strings.Split(err.Error(), "\n")
+	assert.Contains(t, problems, "storage.high_res_input_bucket: value is required")
+	assert.Contains(t, problems, `content_type.types[1]: content type "sports" has no prompt template prompt_templates.sports`)
+	assert.Contains(t, problems, `content_type.default_type: default type "news" is not one of the content types`)
+	assert.Contains(t, problems, "agent_models.creative-flash: agent model is required")
+	assert.Contains(t, problems, `agent_models.pro.provider: unknown provider "unknown"`)
+	assert.Contains(t, problems, `agent_models.pro.retry: unknown fallback model "missing"`)
+	assert.Contains(t, err.Error(), "prompt_templates.trailer.summary: invalid template")
+}
+
+func TestValidateRejectsRateLimit(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	model := config.AgentModels[cloud.DefaultAgentModel]
+	model.RateLimit = 200
+	config.AgentModels[cloud.DefaultAgentModel] = model
+	assert.EqualError(t, config.Validate(), "agent_models.creative-flash.rate_limit: is no longer supported, use requests_per_minute")
+}
+
+func TestValidateCheckpoints(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	config.Workflows = map[string]cloud.WorkflowDefinition{
+		"media": {Checkpoint: true, Steps: []cloud.WorkflowDefinition{{Command: "proxy"}, {Checkpoint: true, Steps: []cloud.WorkflowDefinition{{Command: "analyze"}}}}},
+	}
+	assert.EqualError(t, config.Validate(), "workflows.media.checkpoint: checkpoints.store is required to checkpoint the workflow\n"+
+		"workflows.media.steps[1].checkpoint: checkpoints.store is required to checkpoint the workflow")
+
+	config.Checkpoints.Store = cloud.CheckpointStoreFile
+	assert.EqualError(t, config.Validate(), "checkpoints.path: value is required")
+
+	config.Checkpoints.Path = t.TempDir()
+	assert.Nil(t, config.Validate())
+}
+
+func TestValidateEventSource(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	config.EventSource.Type = cloud.EventSourceSpool
+	assert.EqualError(t, config.Validate(), "event_source.path: value is required")
+
+	config.EventSource.Path = t.TempDir()
+	assert.Nil(t, config.Validate())
+
+	config.EventSource.Type = "kafka"
+	assert.EqualError(t, config.Validate(), `event_source.type: unknown event source "kafka"`)
+}
+
+func TestValidateMediaWorker(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	config.MediaWorker.Enabled = true
+	assert.EqualError(t, config.Validate(), "topic_subscriptions.HiResTopic: subscription is required by the media worker\n"+
+		"topic_subscriptions.LowResTopic: subscription is required by the media worker\n"+
+		"storage.gcs_fuse_mount_point: value is required")
+
+	config.TopicSubscriptions = map[string]cloud.TopicSubscription{
+		"HiResTopic":  {Name: "media_high_res_resources_subscription"},
+		"LowResTopic": {Name: "media_low_res_resources_subscription"},
+	}
+	config.Storage.GCSFuseMountPoint = t.TempDir()
+	assert.Nil(t, config.Validate())
+	assert.Equal(t, config.Storage.GCSFuseMountPoint, config.Storage.GetMountPoint())
+
+	config.MediaWorker.ProxyFormat = "mp4"
+	assert.EqualError(t, config.Validate(), `media_worker.proxy_format: file extension "mp4" must start with a dot`)
+}
+
+func TestDecodeReportsUndecodedKeys(t *testing.T) {
+	config := cloud.NewConfig()
+	path := writeConfig(t, t.TempDir(), ".env.toml", `
+[storage]
+hires_input_bucket = "high"
+
+[embedding_models.multi-lingual]
+model = "text-embedding-005"
+MaxRequestsPerMinute = 100
+`)
+	err := cloud.DecodeConfigFile(path, config)
+	assert.ErrorIs(t, err, cloud.ErrUndecodedKey)
+	assert.Contains(t, err.Error(), "storage.hires_input_bucket")
+	assert.Contains(t, err.Error(), "embedding_models.multi-lingual.MaxRequestsPerMinute")
+	// Known keys are still decoded
+	assert.Equal(t, "text-embedding-005", config.EmbeddingModels["multi-lingual"].Model)
+}
+
+func TestLoadConfigFilesAppliesOverrides(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, ".env.toml", validConfig)
+	writeConfig(t, dir, ".env.local.toml", "[storage]\nhigh_res_input_bucket = \"override\"\nunknown = 1\n")
+
+	config := cloud.NewConfig()
+	err := cloud.LoadConfigFiles(dir, "local", config)
+	assert.ErrorIs(t, err, cloud.ErrUndecodedKey)
+	assert.Contains(t, err.Error(), "storage.unknown")
+	assert.Equal(t, "override", config.Storage.HiResInputBucket)
+
+	assert.NotNil(t, cloud.LoadConfigFiles(t.TempDir(), "local", cloud.NewConfig()))
+}
+
+func TestTemplateServiceKeepsValidTemplates(t *testing.T) {
+	config := loadConfig(t, validConfig)
+	config.PromptTemplates["sports"] = cloud.PromptTemplates{SummaryPrompt: "{{ .Broken", SegmentPrompt: "segment"}
+
+	service := cloud.NewTemplateService(config)
+	assert.NotNil(t, service.GetTemplateBy("trailer"))
+	assert.Nil(t, service.GetTemplateBy("sports"))
+	assert.NotNil(t, service.GetContentTypeTemplate())
+
+	// An invalid update keeps the current templates
+	config.PromptTemplates["trailer"] = cloud.PromptTemplates{SummaryPrompt: "{{ .Broken", SegmentPrompt: "segment"}
+	config.ContentType.PromptTemplate = "{{ .Broken"
+	assert.NotNil(t, service.UpdateTemplates(config))
+	assert.NotNil(t, service.GetTemplateBy("trailer"))
+	assert.NotNil(t, service.GetContentTypeTemplate())
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/commands"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedGet(t *testing.T) {
+	chCtx := newTestContext("in")
+	obj := &cloud.GCSObject{Bucket: "bucket", Name: "video.mp4"}
+	cor.Set(chCtx, cloud.GCSObjectKey, obj)
+
+	got, err := cor.GetKey(chCtx, cloud.GCSObjectKey)
+	assert.Nil(t, err)
+	assert.Equal(t, obj, got)
+
+	in, err := cor.Get[string](chCtx, cor.CtxIn)
+	assert.Nil(t, err)
+	assert.Equal(t, "in", in)
+
+	_, err = cor.Get[int](chCtx, cor.CtxIn)
+	assert.ErrorIs(t, err, cor.ErrTypeMismatch)
+
+	_, err = cor.Get[string](chCtx, "missing")
+	assert.ErrorIs(t, err, cor.ErrKeyNotFound)
+}
+
+func TestAddErrorKeepsAllErrors(t *testing.T) {
+	chCtx := newTestContext("in")
+	first, second := errors.New("first"), errors.New("second")
+	chCtx.AddError("command", first)
+	chCtx.AddError("command", second)
+
+	assert.Equal(t, []error{first, second}, chCtx.GetAllErrors()["command"])
+	assert.ErrorIs(t, chCtx.GetErrors()["command"], first)
+	assert.ErrorIs(t, chCtx.GetErrors()["command"], second)
+}
+
+func TestSnapshotAndMerge(t *testing.T) {
+	chCtx := newTestContext("in")
+	chCtx.AddError("previous", errors.New("previous"))
+
+	snapshot := chCtx.Snapshot()
+	assert.False(t, snapshot.HasErrors())
+	assert.Equal(t, "in", snapshot.Get(cor.CtxIn))
+
+	snapshot.Add("key", "value")
+	snapshot.AddError("previous", errors.New("again"))
+	snapshot.AddTempFile("/tmp/file")
+	assert.Nil(t, chCtx.Get("key"))
+
+	chCtx.Merge(snapshot)
+	assert.Equal(t, "value", chCtx.Get("key"))
+	assert.Len(t, chCtx.GetAllErrors()["previous"], 2)
+	assert.Equal(t, []string{"/tmp/file"}, chCtx.GetTempFiles())
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	chCtx := newTestContext("in")
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprintf("key-%d", i)
+			chCtx.Add(key, i)
+			chCtx.AddError("shared", errors.New(key))
+			_ = chCtx.Get(key)
+			_ = chCtx.GetErrors()
+			chCtx.Merge(chCtx.Snapshot())
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, chCtx.GetAllErrors()["shared"], 16)
+}
+
+func TestMediaTriggerWithInvalidInput(t *testing.T) {
+	command := commands.NewMediaTriggerToGCSObject("trigger")
+	command.InputParamName = cor.CtxIn
+	chCtx := newTestContext(42)
+
+	assert.NotPanics(t, func() { command.Execute(chCtx) })
+	assert.ErrorIs(t, chCtx.GetErrors()["trigger"], cor.ErrTypeMismatch)
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/commands"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointAndResume(t *testing.T) {
+	store, err := cor.NewFileCheckpointStore(t.TempDir())
+	assert.Nil(t, err)
+
+	var firstRuns, secondRuns int
+	fail := true
+	chain := cor.NewBaseChain("checkpointed")
+	chain.SetCheckpointStore(store)
+	chain.AddCommand(NewFuncCommand("first", func(context cor.Context) {
+		firstRuns++
+		cor.Set(context, cloud.GCSObjectKey, &cloud.GCSObject{Bucket: "bucket", Name: "video.mp4"})
+		context.Add(cor.CtxOut, "first-out")
+	}))
+	chain.AddCommand(NewFuncCommand("second", func(context cor.Context) {
+		secondRuns++
+		if fail {
+			context.AddError("second", errors.New("interrupted"))
+			return
+		}
+		obj, err := cor.GetKey(context, cloud.GCSObjectKey)
+		assert.Nil(t, err)
+		context.Add(cor.CtxOut, obj.Name+":"+context.Get(cor.CtxIn).(string))
+	}))
+
+	chCtx := newTestContext("in")
+	cor.Set(chCtx, cor.CheckpointIDKey, "message-1")
+	chain.Execute(chCtx)
+	assert.True(t, chCtx.HasErrors())
+
+	pending, err := chain.PendingCheckpoints(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"message-1"}, pending)
+
+	fail = false
+	resumed, err := chain.Resume(context.Background(), "message-1")
+	assert.Nil(t, err)
+	assert.False(t, resumed.HasErrors())
+	assert.Equal(t, "video.mp4:first-out", resumed.Get(cor.CtxIn))
+	assert.Equal(t, 1, firstRuns)
+	assert.Equal(t, 2, secondRuns)
+
+	pending, err = chain.PendingCheckpoints(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, pending)
+
+	_, err = chain.Resume(context.Background(), "message-1")
+	assert.ErrorIs(t, err, cor.ErrCheckpointNotFound)
+}
+
+func TestResumeThroughMediaTrigger(t *testing.T) {
+	store, err := cor.NewFileCheckpointStore(t.TempDir())
+	assert.Nil(t, err)
+	tempFile := filepath.Join(t.TempDir(), "proxy.mp4")
+
+	fail := true
+	chain := cor.NewBaseChain("media")
+	chain.SetCheckpointStore(store)
+	chain.AddCommand(commands.NewMediaTriggerToGCSObject("trigger"))
+	chain.AddCommand(NewFuncCommand("proxy", func(context cor.Context) {
+		assert.Nil(t, os.WriteFile(tempFile, []byte("proxy"), 0644))
+		context.AddTempFile(tempFile)
+		context.Add("proxy", tempFile)
+		// An unregistered type isn't checkpointed
+		context.Add("unregistered", struct{ Name string }{"value"})
+		context.Add(cor.CtxOut, context.Get(cor.CtxIn))
+	}))
+	chain.AddCommand(NewFuncCommand("analyze", func(context cor.Context) {
+		if fail {
+			context.AddError("analyze", errors.New("interrupted"))
+			return
+		}
+		obj, err := cor.Get[*cloud.GCSObject](context, cor.CtxIn)
+		assert.Nil(t, err)
+		proxy, err := cor.Get[string](context, "proxy")
+		assert.Nil(t, err)
+		data, err := os.ReadFile(proxy)
+		assert.Nil(t, err)
+		context.Add(cor.CtxOut, obj.Name+":"+string(data))
+	}))
+
+	chCtx := newTestContext(`{"bucket":"hi-res","name":"video.mp4","generation":"1"}`)
+	cor.Set(chCtx, cor.CheckpointIDKey, "message-1")
+	cor.Run(chain, chCtx)
+	assert.True(t, chCtx.HasErrors())
+	// The temp file of the checkpoint is kept for the resume
+	assert.FileExists(t, tempFile)
+
+	fail = false
+	resumed, err := chain.Resume(context.Background(), "message-1")
+	assert.Nil(t, err)
+	assert.False(t, resumed.HasErrors(), "%v", resumed.GetErrors())
+	assert.Equal(t, "video.mp4:proxy", resumed.Get(cor.CtxIn))
+	assert.Nil(t, resumed.Get("unregistered"))
+	resumed.Close()
+	assert.NoFileExists(t, tempFile)
+}
+
+func TestRunOrResumeResumesTheCheckpointOfTheContext(t *testing.T) {
+	store, err := cor.NewFileCheckpointStore(t.TempDir())
+	assert.Nil(t, err)
+
+	var firstRuns int
+	fail := true
+	chain := cor.NewBaseChain("checkpointed")
+	chain.SetCheckpointStore(store)
+	chain.AddCommand(NewFuncCommand("first", func(context cor.Context) {
+		firstRuns++
+		context.Add(cor.CtxOut, "first-out")
+	}))
+	chain.AddCommand(NewFuncCommand("second", func(context cor.Context) {
+		if fail {
+			context.AddError("second", errors.New("interrupted"))
+			return
+		}
+		context.Add(cor.CtxOut, context.Get("attribute").(string)+":"+context.Get(cor.CtxIn).(string))
+	}))
+
+	newContext := func() cor.Context {
+		chCtx := newTestContext("in")
+		chCtx.Add("attribute", "value")
+		cor.Set(chCtx, cor.CheckpointIDKey, "message-1")
+		return chCtx
+	}
+
+	// Without a checkpoint the chain is executed from the start
+	chCtx := newContext()
+	cor.RunOrResume(chain, chCtx)
+	assert.True(t, chCtx.HasErrors())
+	assert.Equal(t, 1, firstRuns)
+
+	// The checkpoint is restored into the context, keeping its other values
+	fail = false
+	chCtx = newContext()
+	cor.RunOrResume(chain, chCtx)
+	assert.False(t, chCtx.HasErrors())
+	assert.Equal(t, "value:first-out", chCtx.Get(cor.CtxIn))
+	assert.Equal(t, 1, firstRuns)
+
+	resumed, err := chain.ResumeContext(newContext())
+	assert.Nil(t, err)
+	assert.False(t, resumed)
+}
+
+func TestDeleteCheckpoint(t *testing.T) {
+	store, err := cor.NewFileCheckpointStore(t.TempDir())
+	assert.Nil(t, err)
+	chain := cor.NewBaseChain("checkpointed")
+	chain.SetCheckpointStore(store)
+	chain.AddCommand(NewFuncCommand("first", func(context cor.Context) {
+		context.Add(cor.CtxOut, "first-out")
+	}))
+	chain.AddCommand(NewFuncCommand("second", func(context cor.Context) {
+		context.AddError("second", errors.New("interrupted"))
+	}))
+
+	chCtx := newTestContext("in")
+	cor.Set(chCtx, cor.CheckpointIDKey, "message-1")
+	cor.Run(chain, chCtx)
+
+	pending, err := chain.PendingCheckpoints(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"message-1"}, pending)
+
+	assert.Nil(t, chain.DeleteCheckpoint(context.Background(), "message-1"))
+	pending, err = chain.PendingCheckpoints(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, pending)
+}
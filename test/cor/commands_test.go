@@ -0,0 +1,46 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"context"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+)
+
+// FuncCommand is a test command delegating execution to a function.
+type FuncCommand struct {
+	cor.BaseCommand
+	fn func(context cor.Context)
+}
+
+func NewFuncCommand(name string, fn func(context cor.Context)) *FuncCommand {
+	return &FuncCommand{BaseCommand: *cor.NewBaseCommand(name), fn: fn}
+}
+
+func (c *FuncCommand) IsExecutable(context cor.Context) bool {
+	return context != nil && context.GetContext() != nil
+}
+
+func (c *FuncCommand) Execute(context cor.Context) {
+	c.fn(context)
+}
+
+func newTestContext(in interface{}) cor.Context {
+	chCtx := cor.NewBaseContext()
+	chCtx.SetContext(context.Background())
+	chCtx.Add(cor.CtxIn, in)
+	return chCtx
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+// CompensableCommand is a test command recording its compensation.
+type CompensableCommand struct {
+	*FuncCommand
+	compensate func(context cor.Context)
+}
+
+func (c *CompensableCommand) Compensate(context cor.Context) {
+	c.compensate(context)
+}
+
+func TestChainCompensatesInReverseOrder(t *testing.T) {
+	var compensated []string
+	compensable := func(name string) cor.Command {
+		return &CompensableCommand{
+			FuncCommand: NewFuncCommand(name, func(context cor.Context) {}),
+			compensate: func(context cor.Context) {
+				compensated = append(compensated, name)
+			},
+		}
+	}
+
+	nested := cor.NewBaseChain("nested")
+	nested.AddCommand(compensable("nested-first"))
+	nested.AddCommand(compensable("nested-second"))
+
+	chain := cor.NewBaseChain("saga")
+	chain.AddCommand(compensable("first"))
+	chain.AddCommand(NewFuncCommand("not-compensable", func(context cor.Context) {}))
+	chain.AddCommand(nested)
+	chain.AddCommand(cor.WithRetry(compensable("retried"), &cor.RetryPolicy{MaxAttempts: 1}))
+	chain.AddCommand(NewFuncCommand("fail", func(context cor.Context) {
+		context.AddError("fail", errors.New("failed"))
+	}))
+	chain.AddCommand(compensable("never-executed"))
+
+	chCtx := newTestContext("in")
+	chain.Execute(chCtx)
+
+	assert.Equal(t, []string{"retried", "nested-second", "nested-first", "first"}, compensated)
+	outcomes := chCtx.GetAllErrors()["saga_compensate"]
+	assert.Len(t, outcomes, 3)
+	for _, outcome := range outcomes {
+		assert.ErrorIs(t, outcome, cor.ErrCompensated)
+	}
+	assert.Nil(t, chCtx.Get(string(cor.CompensationKey)))
+}
+
+func TestFailedCompensationIsRecorded(t *testing.T) {
+	chain := cor.NewBaseChain("saga")
+	chain.AddCommand(&CompensableCommand{
+		FuncCommand: NewFuncCommand("first", func(context cor.Context) {}),
+		compensate: func(context cor.Context) {
+			context.AddError("first", errors.New("rollback failed"))
+		},
+	})
+	chain.AddCommand(NewFuncCommand("fail", func(context cor.Context) {
+		context.AddError("fail", errors.New("failed"))
+	}))
+
+	chCtx := newTestContext("in")
+	chain.Execute(chCtx)
+
+	assert.ErrorIs(t, chCtx.GetErrors()["saga_compensate"], cor.ErrCompensationFailed)
+	assert.Contains(t, chCtx.GetErrors(), "first")
+}
+
+func TestSuccessfulChainIsNotCompensated(t *testing.T) {
+	compensated := false
+	chain := cor.NewBaseChain("saga")
+	chain.AddCommand(&CompensableCommand{
+		FuncCommand: NewFuncCommand("first", func(context cor.Context) {}),
+		compensate: func(context cor.Context) {
+			compensated = true
+		},
+	})
+
+	chCtx := newTestContext("in")
+	chain.Execute(chCtx)
+
+	assert.False(t, chCtx.HasErrors())
+	assert.False(t, compensated)
+}
+
+func TestCompensationDeletesTheCheckpoint(t *testing.T) {
+	store, err := cor.NewFileCheckpointStore(t.TempDir())
+	assert.Nil(t, err)
+
+	var firstRuns, compensations int
+	fail := true
+	chain := cor.NewBaseChain("saga")
+	chain.SetCheckpointStore(store)
+	chain.AddCommand(&CompensableCommand{
+		FuncCommand: NewFuncCommand("insert", func(context cor.Context) {
+			firstRuns++
+			context.Add(cor.CtxOut, "inserted")
+		}),
+		compensate: func(context cor.Context) { compensations++ },
+	})
+	chain.AddCommand(NewFuncCommand("fail", func(context cor.Context) {
+		if fail {
+			context.AddError("fail", errors.New("failed"))
+		}
+	}))
+
+	chCtx := newTestContext("in")
+	cor.Set(chCtx, cor.CheckpointIDKey, "message-1")
+	cor.Run(chain, chCtx)
+	assert.Equal(t, 1, compensations)
+
+	// The compensated command isn't skipped by a later execution of the message
+	pending, err := chain.PendingCheckpoints(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, pending)
+
+	fail = false
+	chCtx = newTestContext("in")
+	cor.Set(chCtx, cor.CheckpointIDKey, "message-1")
+	cor.RunOrResume(chain, chCtx)
+	assert.False(t, chCtx.HasErrors())
+	assert.Equal(t, 2, firstRuns)
+	assert.Equal(t, 1, compensations)
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var errTransient = errors.New("transient")
+var errPermanent = errors.New("permanent")
+
+func testRetryPolicy(maxAttempts int) *cor.RetryPolicy {
+	return &cor.RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         0.5,
+		Retryable:      cor.RetryOn(errTransient),
+	}
+}
+
+func TestRetryRecovers(t *testing.T) {
+	attempts := 0
+	command := cor.WithRetry(NewFuncCommand("flaky", func(context cor.Context) {
+		attempts++
+		if attempts < 3 {
+			context.AddError("flaky", errTransient)
+			return
+		}
+		context.Add(cor.CtxOut, "done")
+	}), testRetryPolicy(3))
+
+	chCtx := newTestContext("in")
+	command.Execute(chCtx)
+
+	assert.Equal(t, 3, attempts)
+	assert.False(t, chCtx.HasErrors())
+	assert.Equal(t, "done", chCtx.Get(cor.CtxOut))
+}
+
+func TestRetryCountsAttempts(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	defer otel.SetMeterProvider(previous)
+
+	attempts := 0
+	inner := NewFuncCommand("counted", func(context cor.Context) {
+		attempts++
+		if attempts < 3 {
+			context.AddError("counted", errTransient)
+		}
+	})
+	cor.WithRetry(inner, testRetryPolicy(3)).Execute(newTestContext("in"))
+
+	var data metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &data))
+	counts := make(map[string]int64)
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				outcome, _ := point.Attributes.Value(attribute.Key("outcome"))
+				counts[m.Name+"/"+outcome.AsString()] += point.Value
+			}
+		}
+	}
+	// The execution isn't counted again on the success and error counters of the command
+	assert.Equal(t, map[string]int64{
+		"counted.counter.retry_attempts/error":   2,
+		"counted.counter.retry_attempts/success": 1,
+	}, counts)
+}
+
+func TestRetryStopsOnPermanentError(t *testing.T) {
+	attempts := 0
+	command := cor.WithRetry(NewFuncCommand("broken", func(context cor.Context) {
+		attempts++
+		context.AddError("broken", errPermanent)
+	}), testRetryPolicy(5))
+
+	chCtx := newTestContext("in")
+	command.Execute(chCtx)
+
+	assert.Equal(t, 1, attempts)
+	assert.ErrorIs(t, chCtx.GetErrors()["broken"], errPermanent)
+}
+
+func TestRetryExhaustsAttempts(t *testing.T) {
+	attempts := 0
+	command := cor.WithRetry(NewFuncCommand("flaky", func(context cor.Context) {
+		attempts++
+		context.AddError("flaky", errTransient)
+	}), testRetryPolicy(4))
+
+	chCtx := newTestContext("in")
+	command.Execute(chCtx)
+
+	assert.Equal(t, 4, attempts)
+	assert.True(t, chCtx.HasErrors())
+}
+
+func TestRetryBackoffIsBounded(t *testing.T) {
+	policy := &cor.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 4 * time.Second, Multiplier: 2}
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 4*time.Second, policy.Backoff(3))
+	assert.Equal(t, 4*time.Second, policy.Backoff(10))
+}
+
+func TestTimeout(t *testing.T) {
+	command := cor.WithTimeout(NewFuncCommand("slow", func(context cor.Context) {
+		select {
+		case <-context.GetContext().Done():
+		case <-time.After(time.Second):
+		}
+		context.Add(cor.CtxOut, "late")
+	}), 10*time.Millisecond)
+
+	chCtx := newTestContext("in")
+	command.Execute(chCtx)
+
+	assert.ErrorIs(t, chCtx.GetErrors()["slow"], cor.ErrTimeout)
+	assert.Nil(t, chCtx.Get(cor.CtxOut))
+}
+
+func TestTimeoutReportsCancellation(t *testing.T) {
+	command := cor.WithTimeout(NewFuncCommand("cancelled", func(context cor.Context) {
+		<-context.GetContext().Done()
+	}), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chCtx := newTestContext("in")
+	chCtx.SetContext(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	command.Execute(chCtx)
+
+	assert.ErrorIs(t, chCtx.GetErrors()["cancelled"], cor.ErrCancelled)
+	assert.NotErrorIs(t, chCtx.GetErrors()["cancelled"], cor.ErrTimeout)
+}
+
+func TestTimeoutRecoversPanics(t *testing.T) {
+	command := cor.WithTimeout(NewFuncCommand("panics", func(context cor.Context) {
+		context.Add("partial", true)
+		panic("boom")
+	}), time.Second)
+
+	chCtx := newTestContext("in")
+	command.Execute(chCtx)
+
+	assert.ErrorIs(t, chCtx.GetErrors()["panics"], cor.ErrPanic)
+	assert.Equal(t, true, chCtx.Get("partial"))
+}
+
+func TestRetryWithTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	command := cor.WithRetry(cor.WithTimeout(NewFuncCommand("slow", func(context cor.Context) {
+		if attempts.Add(1) == 1 {
+			<-context.GetContext().Done()
+			return
+		}
+		context.Add(cor.CtxOut, "done")
+	}), 10*time.Millisecond), &cor.RetryPolicy{MaxAttempts: 2, Retryable: cor.RetryOn(cor.ErrTimeout)})
+
+	chCtx := newTestContext("in")
+	command.Execute(chCtx)
+
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.False(t, chCtx.HasErrors())
+	assert.Equal(t, "done", chCtx.Get(cor.CtxOut))
+}
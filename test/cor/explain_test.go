@@ -0,0 +1,83 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+// ParamCommand is a test command using the default IsExecutable of BaseCommand.
+type ParamCommand struct {
+	cor.BaseCommand
+}
+
+func (c *ParamCommand) Execute(context cor.Context) {
+	panic("unexpected execution")
+}
+
+func TestExplainChain(t *testing.T) {
+	executed := false
+	noop := func(context cor.Context) { executed = true }
+
+	needsObject := &ParamCommand{BaseCommand: *cor.NewBaseCommand("needs-object")}
+	needsObject.InputParamName = cloud.GetGCSObjectName()
+
+	nested := cor.NewBaseChain("nested")
+	nested.AddCommand(NewFuncCommand("nested-step", noop))
+	nested.AddCommand(needsObject)
+
+	router := cor.NewRouter("router").
+		AddRoute("video", cloud.MIMETypePrefix("video/"), NewFuncCommand("video", noop)).
+		SetDefault(NewFuncCommand("other", noop))
+
+	chain := cor.NewBaseChain("main")
+	chain.AddCommand(cor.WithRetry(NewFuncCommand("first", noop), nil))
+	chain.AddCommand(router)
+	chain.AddCommand(nested)
+
+	plan := chain.Explain(newTestContext("in"))
+
+	assert.False(t, executed)
+	assert.Equal(t, cor.StepKindChain, plan.Root.Kind)
+	assert.Len(t, plan.Root.Steps, 3)
+	assert.Equal(t, cor.StepKindRetry, plan.Root.Steps[0].Kind)
+	assert.True(t, plan.Root.Steps[0].Steps[0].Executable)
+
+	routes := plan.Root.Steps[1].Steps
+	assert.False(t, routes[0].Selected)
+	assert.True(t, routes[1].Selected)
+
+	skipped := plan.Skipped()
+	assert.Len(t, skipped, 2)
+	assert.Equal(t, "route not selected", skipped[0].SkipReason)
+	assert.Equal(t, "needs-object", skipped[1].Name)
+	assert.Equal(t, "missing input param __GCS__OBJ__", skipped[1].SkipReason)
+
+	text := plan.String()
+	assert.Contains(t, text, "main [chain]")
+	assert.Contains(t, text, "├── first [retry]")
+	assert.Contains(t, text, "└── needs-object [command] in=__GCS__OBJ__ out=__OUT__ SKIPPED: missing input param __GCS__OBJ__")
+
+	data, err := plan.JSON()
+	assert.Nil(t, err)
+	var decoded cor.Plan
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "main", decoded.Root.Name)
+}
@@ -0,0 +1,113 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptorOrderAndInvocation(t *testing.T) {
+	var calls []string
+	var invocations []*cor.Invocation
+	trace := func(name string) cor.Interceptor {
+		return cor.InterceptorFuncs{
+			BeforeFunc: func(invocation *cor.Invocation) bool {
+				calls = append(calls, name+".before:"+invocation.Command.GetName())
+				return true
+			},
+			AfterFunc: func(invocation *cor.Invocation) {
+				calls = append(calls, name+".after:"+invocation.Command.GetName())
+				invocations = append(invocations, invocation)
+			},
+		}
+	}
+
+	chain := cor.NewBaseChain("intercepted")
+	chain.ContinueOnFailure(true)
+	chain.AddInterceptor(trace("outer")).AddInterceptor(trace("inner"))
+	chain.AddCommand(NewFuncCommand("slow", func(context cor.Context) {
+		time.Sleep(5 * time.Millisecond)
+		context.Add(cor.CtxOut, "out")
+	}))
+	chain.AddCommand(NewFuncCommand("fail", func(context cor.Context) {
+		context.AddError("fail", errors.New("failed"))
+	}))
+
+	chain.Execute(newTestContext("in"))
+
+	assert.Equal(t, []string{
+		"outer.before:slow", "inner.before:slow", "inner.after:slow", "outer.after:slow",
+		"outer.before:fail", "inner.before:fail", "inner.after:fail", "outer.after:fail",
+	}, calls)
+	assert.Equal(t, "intercepted", invocations[0].Chain)
+	assert.GreaterOrEqual(t, invocations[0].Duration, 5*time.Millisecond)
+	assert.Empty(t, invocations[0].Errors)
+	assert.Len(t, invocations[2].Errors, 1)
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	executed := false
+	chain := cor.NewBaseChain("flagged")
+	chain.AddInterceptor(cor.InterceptorFuncs{BeforeFunc: func(invocation *cor.Invocation) bool {
+		return invocation.Command.GetName() != "disabled"
+	}})
+	chain.AddCommand(NewFuncCommand("disabled", func(context cor.Context) {
+		executed = true
+	}))
+	chain.AddCommand(NewFuncCommand("echo", func(context cor.Context) {
+		context.Add(cor.CtxOut, context.Get(cor.CtxIn))
+	}))
+
+	chCtx := newTestContext("in")
+	chain.Execute(chCtx)
+
+	assert.False(t, executed)
+	assert.False(t, chCtx.HasErrors())
+	assert.Equal(t, "in", chCtx.Get(cor.CtxIn))
+}
+
+func TestBuiltInInterceptors(t *testing.T) {
+	var buffer bytes.Buffer
+	var diffs []cor.ContextDiff
+	chain := cor.NewBaseChain("built-in")
+	chain.AddInterceptor(cor.NewSlogInterceptor(slog.New(slog.NewTextHandler(&buffer, nil))))
+	chain.AddInterceptor(cor.NewContextDiffInterceptor(func(diff cor.ContextDiff) {
+		diffs = append(diffs, diff)
+	}))
+	chain.AddCommand(NewFuncCommand("mutate", func(context cor.Context) {
+		context.Add("added", 1)
+		context.Add("changed", "new")
+		context.Remove("removed")
+	}))
+
+	chCtx := newTestContext("in")
+	chCtx.Add("changed", "old")
+	chCtx.Add("removed", true)
+	chain.Execute(chCtx)
+
+	assert.Contains(t, buffer.String(), "command executed")
+	assert.Contains(t, buffer.String(), "command=mutate")
+	assert.Len(t, diffs, 1)
+	assert.Equal(t, []string{"added"}, diffs[0].Added)
+	assert.Equal(t, []string{"changed"}, diffs[0].Changed)
+	assert.Equal(t, []string{"removed"}, diffs[0].Removed)
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelChainMergesOutputs(t *testing.T) {
+	chain := cor.NewParallelChain("parallel", 2)
+	chain.AddCommand(NewFuncCommand("upper", func(context cor.Context) {
+		context.Add("upper", "HELLO")
+		context.Add(cor.CtxOut, 1)
+	}))
+	chain.AddCommand(NewFuncCommand("lower", func(context cor.Context) {
+		context.Add("lower", "hello")
+		context.Add(cor.CtxOut, 2)
+	}))
+
+	chCtx := newTestContext("hello")
+	chain.Execute(chCtx)
+
+	assert.False(t, chCtx.HasErrors())
+	assert.Equal(t, "HELLO", chCtx.Get("upper"))
+	assert.Equal(t, "hello", chCtx.Get("lower"))
+	assert.Equal(t, "hello", chCtx.Get(cor.CtxIn))
+	outputs := chCtx.Get(cor.CtxOut).(map[string]interface{})
+	assert.Equal(t, 1, outputs["upper"])
+	assert.Equal(t, 2, outputs["lower"])
+}
+
+func TestParallelChainAppliesOnlyChangedKeys(t *testing.T) {
+	chain := cor.NewParallelChain("parallel", 2)
+	chain.AddCommand(NewFuncCommand("writer", func(context cor.Context) {
+		context.Add("shared", "changed")
+		context.Remove("removed")
+	}))
+	chain.AddCommand(NewFuncCommand("reader", func(context cor.Context) {
+		context.Add("reader", context.Get("shared"))
+	}))
+
+	chCtx := newTestContext("in")
+	chCtx.Add("shared", "initial")
+	chCtx.Add("removed", "value")
+	chain.Execute(chCtx)
+
+	assert.False(t, chCtx.HasErrors())
+	// The stale copies of the reader don't overwrite or restore the keys of the writer
+	assert.Equal(t, "changed", chCtx.Get("shared"))
+	assert.Nil(t, chCtx.Get("removed"))
+	assert.Equal(t, "initial", chCtx.Get("reader"))
+}
+
+func TestParallelChainReportsConflictingWrites(t *testing.T) {
+	chain := cor.NewParallelChain("parallel", 2)
+	chain.AddCommand(NewFuncCommand("first", func(context cor.Context) {
+		context.Add("shared", "first")
+	}))
+	chain.AddCommand(NewFuncCommand("second", func(context cor.Context) {
+		context.Add("shared", "second")
+	}))
+
+	chCtx := newTestContext("in")
+	chain.Execute(chCtx)
+
+	assert.ErrorIs(t, chCtx.GetErrors()["parallel"], cor.ErrConflictingWrites)
+	assert.Equal(t, "second", chCtx.Get("shared"))
+}
+
+func TestParallelChainBoundsConcurrency(t *testing.T) {
+	var running, maxRunning int32
+	chain := cor.NewParallelChain("bounded", 2)
+	for range 6 {
+		chain.AddCommand(NewFuncCommand("worker", func(context cor.Context) {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				seen := atomic.LoadInt32(&maxRunning)
+				if current <= seen || atomic.CompareAndSwapInt32(&maxRunning, seen, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}))
+	}
+
+	chain.Execute(newTestContext("in"))
+	assert.LessOrEqual(t, maxRunning, int32(2))
+}
+
+func TestParallelChainFailFast(t *testing.T) {
+	var executed int32
+	chain := cor.NewParallelChain("fail-fast", 1)
+	chain.AddCommand(NewFuncCommand("fail", func(context cor.Context) {
+		context.AddError("fail", errors.New("failed"))
+	}))
+	chain.AddCommand(NewFuncCommand("skipped", func(context cor.Context) {
+		atomic.AddInt32(&executed, 1)
+	}))
+
+	chCtx := newTestContext("in")
+	chain.Execute(chCtx)
+
+	assert.True(t, chCtx.HasErrors())
+	assert.Equal(t, int32(0), executed)
+}
+
+func TestParallelChainWaitForAll(t *testing.T) {
+	var executed int32
+	chain := cor.NewParallelChain("wait-for-all", 1)
+	chain.ContinueOnFailure(true)
+	chain.AddCommand(NewFuncCommand("fail", func(context cor.Context) {
+		context.AddError("fail", errors.New("failed"))
+	}))
+	chain.AddCommand(NewFuncCommand("executed", func(context cor.Context) {
+		atomic.AddInt32(&executed, 1)
+	}))
+
+	chCtx := newTestContext("in")
+	chain.Execute(chCtx)
+
+	assert.True(t, chCtx.HasErrors())
+	assert.Contains(t, chCtx.GetErrors(), "fail")
+	assert.Equal(t, int32(1), executed)
+}
+
+func TestParallelChainRecoversPanics(t *testing.T) {
+	chain := cor.NewParallelChain("parallel", 2)
+	chain.ContinueOnFailure(true)
+	chain.AddCommand(NewFuncCommand("panics", func(context cor.Context) {
+		panic("boom")
+	}))
+	chain.AddCommand(NewFuncCommand("completes", func(context cor.Context) {
+		context.Add("completed", true)
+	}))
+
+	tempFile := filepath.Join(t.TempDir(), "temp.txt")
+	assert.Nil(t, os.WriteFile(tempFile, []byte("temp"), 0o644))
+	chCtx := newTestContext("in")
+	chCtx.AddTempFile(tempFile)
+	cor.Run(chain, chCtx)
+
+	errs := chCtx.GetAllErrors()["panics"]
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], cor.ErrPanic)
+	assert.Equal(t, true, chCtx.Get("completed"))
+	// The context is closed by Run
+	assert.NoFileExists(t, tempFile)
+}
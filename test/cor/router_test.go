@@ -0,0 +1,76 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRoutedContext(obj *cloud.GCSObject, eventType string) cor.Context {
+	chCtx := newTestContext("in")
+	chCtx.Add(cloud.GetGCSObjectName(), obj)
+	chCtx.Add(cloud.GetPubSubAttributesName(), map[string]string{cloud.GCSEventTypeAttribute: eventType})
+	return chCtx
+}
+
+func newRecordingCommand(name string) *FuncCommand {
+	return NewFuncCommand(name, func(context cor.Context) {
+		context.Add("route", name)
+	})
+}
+
+func TestRouter(t *testing.T) {
+	router := cor.NewRouter("media-router").
+		AddRoute("deletions", cloud.EventType(cloud.GCSEventObjectDelete), newRecordingCommand("delete")).
+		AddRoute("configs", cloud.FileExtension(".toml"), newRecordingCommand("config")).
+		AddRoute("videos", cor.And(cloud.MIMETypePrefix("video/"), cloud.BucketEquals("hi-res")), newRecordingCommand("video")).
+		AddRoute("audio", cloud.MIMETypePrefix("audio/"), newRecordingCommand("audio")).
+		SetDefault(newRecordingCommand("default"))
+
+	tests := map[string]struct {
+		obj       *cloud.GCSObject
+		eventType string
+		expected  string
+	}{
+		"video":     {&cloud.GCSObject{Bucket: "hi-res", Name: "trailer.mp4", MIMEType: "video/mp4"}, cloud.GCSEventObjectFinalize, "video"},
+		"audio":     {&cloud.GCSObject{Bucket: "hi-res", Name: "track.mp3", MIMEType: "audio/mpeg"}, cloud.GCSEventObjectFinalize, "audio"},
+		"config":    {&cloud.GCSObject{Bucket: "configs", Name: ".env.TOML", MIMEType: "application/toml"}, cloud.GCSEventObjectFinalize, "config"},
+		"delete":    {&cloud.GCSObject{Bucket: "hi-res", Name: "trailer.mp4", MIMEType: "video/mp4"}, cloud.GCSEventObjectDelete, "delete"},
+		"unmatched": {&cloud.GCSObject{Bucket: "low-res", Name: "trailer.mp4", MIMEType: "video/mp4"}, cloud.GCSEventObjectFinalize, "default"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			chCtx := newRoutedContext(tc.obj, tc.eventType)
+			router.Execute(chCtx)
+			assert.Equal(t, tc.expected, chCtx.Get("route"))
+		})
+	}
+}
+
+func TestRouterWithoutDefault(t *testing.T) {
+	router := cor.NewRouter("no-default").
+		AddRoute("videos", cloud.MIMETypePrefix("video/"), newRecordingCommand("video"))
+
+	chCtx := newTestContext("in")
+	router.Execute(chCtx)
+
+	assert.Nil(t, chCtx.Get("route"))
+	assert.False(t, chCtx.HasErrors())
+}
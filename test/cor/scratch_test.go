@@ -0,0 +1,84 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunRemovesScratchDirAfterPanic(t *testing.T) {
+	chCtx := cor.NewBaseContextWithScratch(cor.ScratchConfig{Root: t.TempDir()})
+	chCtx.SetContext(context.Background())
+	chCtx.Add(cor.CtxIn, "in")
+
+	var scratchPath, tempFile string
+	chain := cor.NewBaseChain("scratch")
+	chain.AddCommand(NewFuncCommand("write", func(context cor.Context) {
+		scratch, err := context.GetScratchDir()
+		assert.Nil(t, err)
+		scratchPath = scratch.Path()
+		file, err := scratch.CreateTemp("out-")
+		assert.Nil(t, err)
+		_ = file.Close()
+
+		other, err := os.CreateTemp(t.TempDir(), "temp-")
+		assert.Nil(t, err)
+		_ = other.Close()
+		tempFile = other.Name()
+		context.AddTempFile(tempFile)
+
+		panic("boom")
+	}))
+
+	assert.NotPanics(t, func() { cor.Run(chain, chCtx) })
+
+	assert.ErrorIs(t, chCtx.GetErrors()["scratch"], cor.ErrPanic)
+	assert.NoDirExists(t, scratchPath)
+	assert.NoFileExists(t, tempFile)
+	assert.Empty(t, chCtx.GetTempFiles())
+}
+
+func TestScratchDirBudget(t *testing.T) {
+	scratch, err := cor.NewScratchDir(t.TempDir(), "budget-", 10)
+	assert.Nil(t, err)
+	defer func() { _ = scratch.Remove() }()
+
+	assert.Nil(t, scratch.Reserve(10))
+	assert.ErrorIs(t, scratch.Reserve(11), cor.ErrDiskBudgetExceeded)
+
+	watchCtx, cancel := scratch.Watch(context.Background(), time.Millisecond)
+	defer cancel()
+
+	assert.Nil(t, os.WriteFile(filepath.Join(scratch.Path(), "large"), make([]byte, 20), 0o644))
+	select {
+	case <-watchCtx.Done():
+		assert.ErrorIs(t, context.Cause(watchCtx), cor.ErrDiskBudgetExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("watch did not detect the exceeded budget")
+	}
+
+	_, err = scratch.CreateTemp("more-")
+	assert.ErrorIs(t, err, cor.ErrDiskBudgetExceeded)
+
+	assert.Nil(t, scratch.Remove())
+	assert.NoDirExists(t, scratch.Path())
+}
@@ -0,0 +1,248 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listeners_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingCommand returns a command sending the GCS object of each execution to the channel.
+func recordingCommand(objects chan *cloud.GCSObject) cor.Command {
+	return NewFuncCommand("record", func(context cor.Context) {
+		in, err := cor.Get[string](context, cor.CtxIn)
+		if err != nil {
+			context.AddError("record", err)
+			return
+		}
+		notification, err := cloud.ParseGCSPubSubNotification([]byte(in))
+		if err != nil {
+			context.AddError("record", err)
+			return
+		}
+		objects <- &cloud.GCSObject{Bucket: notification.Bucket, Name: notification.Name, Generation: notification.Generation}
+	})
+}
+
+func TestMemoryEventSourceSkipsDuplicates(t *testing.T) {
+	source := cloud.NewMemoryEventSource("HiResTopic", 10, 1)
+	objects := make(chan *cloud.GCSObject, 10)
+	listener := cloud.NewListener(source, recordingCommand(objects))
+	listener.SetIdempotencyStore(cloud.NewMemoryIdempotencyStore(), time.Hour)
+	controller := listener.Listen(context.Background())
+	defer controller.Stop(context.Background())
+
+	notification := []byte(`{"bucket":"hi-res","name":"video.mp4","generation":"1","metageneration":"1"}`)
+	ctx := context.Background()
+	assert.Nil(t, source.Publish(ctx, &cloud.Message{Data: notification}))
+	// A duplicate delivery of the notification
+	assert.Nil(t, source.Publish(ctx, &cloud.Message{Data: notification}))
+	// A new upload of the file
+	assert.Nil(t, source.Publish(ctx, &cloud.Message{Data: []byte(`{"bucket":"hi-res","name":"video.mp4","generation":"2","metageneration":"1"}`)}))
+
+	var generations []string
+	for range 2 {
+		select {
+		case object := <-objects:
+			generations = append(generations, object.Generation)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the notification was not handled")
+		}
+	}
+	assert.ElementsMatch(t, []string{"1", "2"}, generations)
+	select {
+	case object := <-objects:
+		t.Fatalf("the duplicate delivery was handled: %v", object)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSpoolEventSourceDeliversFiles(t *testing.T) {
+	dir := t.TempDir()
+	source, err := cloud.NewSpoolEventSource("HiResTopic", dir, 10*time.Millisecond, 1)
+	assert.Nil(t, err)
+	objects := make(chan *cloud.GCSObject, 10)
+	controller := cloud.NewListener(source, recordingCommand(objects)).Listen(context.Background())
+	defer controller.Stop(context.Background())
+
+	// Partially written files aren't delivered
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "upload.json.tmp"), []byte(`{`), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "upload.json"), []byte(`{"bucket":"hi-res","name":"video.mp4","generation":"1"}`), 0o644))
+
+	select {
+	case object := <-objects:
+		assert.Equal(t, "hi-res", object.Bucket)
+		assert.Equal(t, "video.mp4", object.Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the spooled file was not delivered")
+	}
+	// The file is acknowledged once the command is executed
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "upload.json"))
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.FileExists(t, filepath.Join(dir, "upload.json.tmp"))
+}
+
+func TestSpoolEventSourceDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	source, err := cloud.NewSpoolEventSource("LowResTopic", dir, 10*time.Millisecond, 1)
+	assert.Nil(t, err)
+	command := NewFuncCommand("broken", func(context cor.Context) {
+		context.AddError("broken", errors.New("permanent"))
+	})
+	listener := cloud.NewListener(source, command)
+	queue, err := cloud.NewWorkQueue("LowResTopic", "")
+	assert.Nil(t, err)
+	listener.SetWorkQueue(queue, 1, testRetry(2))
+	controller := listener.Listen(context.Background())
+	defer controller.Stop(context.Background())
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{}`), 0o644))
+	deadLetter := filepath.Join(dir, cloud.SpoolDeadLetterDir, "broken.json")
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(deadLetter)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	data, err := os.ReadFile(deadLetter)
+	assert.Nil(t, err)
+	item := &cloud.WorkItem{}
+	assert.Nil(t, json.Unmarshal(data, item))
+	assert.Equal(t, "broken", item.ID)
+	assert.Equal(t, 2, item.Attempts)
+	assert.Contains(t, item.LastError, "permanent")
+}
+
+// blockingCommand returns a command signalling its start and blocking until release is closed.
+func blockingCommand(started chan struct{}, release chan struct{}) cor.Command {
+	return NewFuncCommand("blocking", func(context cor.Context) {
+		started <- struct{}{}
+		<-release
+	})
+}
+
+// awaitStarts waits for count executions to start.
+func awaitStarts(t *testing.T, started chan struct{}, count int) {
+	for range count {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the messages were not executed concurrently")
+		}
+	}
+}
+
+func TestMemoryEventSourceHandlesMessagesConcurrently(t *testing.T) {
+	source := cloud.NewMemoryEventSource("HiResTopic", 10, 2)
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	listener := cloud.NewListener(source, blockingCommand(started, release))
+	queue, err := cloud.NewWorkQueue("HiResTopic", "")
+	assert.Nil(t, err)
+	listener.SetWorkQueue(queue, 2, testRetry(1))
+	controller := listener.Listen(context.Background())
+	defer controller.Stop(context.Background())
+	defer close(release)
+
+	assert.Nil(t, source.Publish(context.Background(), &cloud.Message{Data: []byte("first")}))
+	assert.Nil(t, source.Publish(context.Background(), &cloud.Message{Data: []byte("second")}))
+	awaitStarts(t, started, 2)
+}
+
+func TestSpoolEventSourceHandlesFilesConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	source, err := cloud.NewSpoolEventSource("HiResTopic", dir, 10*time.Millisecond, 2)
+	assert.Nil(t, err)
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	listener := cloud.NewListener(source, blockingCommand(started, release))
+	queue, err := cloud.NewWorkQueue("HiResTopic", "")
+	assert.Nil(t, err)
+	listener.SetWorkQueue(queue, 2, testRetry(1))
+	controller := listener.Listen(context.Background())
+	defer controller.Stop(context.Background())
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "first.json"), []byte(`{}`), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "second.json"), []byte(`{}`), 0o644))
+	awaitStarts(t, started, 2)
+	// A file being handled isn't delivered again by the following scans
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, started, 0)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		entries, err := os.ReadDir(dir)
+		return err == nil && len(entries) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// handlerSource delivers a single message and reports the result of the handler, i.e. whether
+// the message is acknowledged.
+type handlerSource struct {
+	results chan error
+}
+
+func (s *handlerSource) Name() string {
+	return "handler"
+}
+
+func (s *handlerSource) Receive(ctx context.Context, handler cloud.MessageHandler) error {
+	s.results <- handler(ctx, &cloud.Message{ID: "message-1", Data: []byte("data")})
+	<-ctx.Done()
+	return nil
+}
+
+func TestInMemoryQueueAcknowledgesAfterExecution(t *testing.T) {
+	for _, persistent := range []bool{false, true} {
+		source := &handlerSource{results: make(chan error, 1)}
+		release := make(chan struct{})
+		listener := cloud.NewListener(source, NewFuncCommand("blocked", func(context cor.Context) {
+			<-release
+		}))
+		if persistent {
+			queue, err := cloud.NewWorkQueue("handler", t.TempDir())
+			assert.Nil(t, err)
+			listener.SetWorkQueue(queue, 1, testRetry(1))
+		}
+		controller := listener.Listen(context.Background())
+
+		select {
+		case err := <-source.results:
+			// A persistent queue acknowledges the message once it's queued
+			assert.True(t, persistent, "the message was acknowledged before its execution")
+			assert.Nil(t, err)
+		case <-time.After(100 * time.Millisecond):
+			assert.False(t, persistent, "the queued message was not acknowledged")
+		}
+		close(release)
+		if !persistent {
+			select {
+			case err := <-source.results:
+				assert.Nil(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("the message was not acknowledged after its execution")
+			}
+		}
+		controller.Stop(context.Background())
+	}
+}
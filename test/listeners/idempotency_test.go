@@ -0,0 +1,167 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listeners_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGCSNotificationIdempotencyKey(t *testing.T) {
+	notification, err := cloud.ParseGCSPubSubNotification([]byte(`{"bucket":"hi-res","name":"video.mp4","generation":"1700000000000001","metageneration":"1"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "hi-res/video.mp4#1700000000000001.1", notification.IdempotencyKey())
+
+	// A new upload of the object has a new generation
+	upload, err := cloud.ParseGCSPubSubNotification([]byte(`{"bucket":"hi-res","name":"video.mp4","generation":"1700000000000002","metageneration":"1"}`))
+	assert.Nil(t, err)
+	assert.NotEqual(t, notification.IdempotencyKey(), upload.IdempotencyKey())
+
+	// Messages that aren't object notifications have no key
+	other, err := cloud.ParseGCSPubSubNotification([]byte(`{"bucket":"configs"}`))
+	assert.Nil(t, err)
+	assert.Empty(t, other.IdempotencyKey())
+	_, err = cloud.ParseGCSPubSubNotification([]byte(`not json`))
+	assert.NotNil(t, err)
+}
+
+func TestIdempotencyStores(t *testing.T) {
+	fileStore, err := cloud.NewFileIdempotencyStore(t.TempDir())
+	assert.Nil(t, err)
+	local, err := objectstore.NewLocalStore(t.TempDir())
+	assert.Nil(t, err)
+	stores := map[string]cloud.IdempotencyStore{
+		"memory": cloud.NewMemoryIdempotencyStore(),
+		"file":   fileStore,
+		"gcs":    cloud.NewGCSIdempotencyStore(local, "state", "idempotency"),
+	}
+	ctx := context.Background()
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			key := "HiResTopic/hi-res/video.mp4#1.1"
+			claimed, err := store.Claim(ctx, key, time.Hour)
+			assert.Nil(t, err)
+			assert.True(t, claimed)
+
+			// A duplicate delivery is skipped
+			claimed, err = store.Claim(ctx, key, time.Hour)
+			assert.Nil(t, err)
+			assert.False(t, claimed)
+
+			// A released key is claimed again
+			assert.Nil(t, store.Release(ctx, key))
+			claimed, err = store.Claim(ctx, key, time.Hour)
+			assert.Nil(t, err)
+			assert.True(t, claimed)
+
+			// An expired key is claimed again
+			expiring := "HiResTopic/hi-res/video.mp4#2.1"
+			claimed, err = store.Claim(ctx, expiring, time.Millisecond)
+			assert.Nil(t, err)
+			assert.True(t, claimed)
+			time.Sleep(5 * time.Millisecond)
+			claimed, err = store.Claim(ctx, expiring, time.Hour)
+			assert.Nil(t, err)
+			assert.True(t, claimed)
+		})
+	}
+}
+
+func TestIdempotencyStoresConcurrentClaims(t *testing.T) {
+	dir := t.TempDir()
+	local, err := objectstore.NewLocalStore(t.TempDir())
+	assert.Nil(t, err)
+	stores := map[string]func() cloud.IdempotencyStore{
+		// Each claimer has its own store, as the processes sharing the directory or bucket
+		"file": func() cloud.IdempotencyStore {
+			store, err := cloud.NewFileIdempotencyStore(dir)
+			assert.Nil(t, err)
+			return store
+		},
+		"gcs": func() cloud.IdempotencyStore {
+			return cloud.NewGCSIdempotencyStore(local, "state", "idempotency")
+		},
+	}
+	ctx := context.Background()
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, ttl := range []time.Duration{time.Hour, time.Millisecond} {
+				key := "HiResTopic/hi-res/video.mp4#" + ttl.String()
+				if ttl == time.Millisecond {
+					// Every claimer finds the expired record, a single one replaces it
+					claimed, err := newStore().Claim(ctx, key, ttl)
+					assert.Nil(t, err)
+					assert.True(t, claimed)
+					time.Sleep(5 * time.Millisecond)
+				}
+				var claims atomic.Int32
+				var wg sync.WaitGroup
+				for range 20 {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						claimed, err := newStore().Claim(ctx, key, time.Hour)
+						assert.Nil(t, err)
+						if claimed {
+							claims.Add(1)
+						}
+					}()
+				}
+				wg.Wait()
+				assert.Equal(t, int32(1), claims.Load())
+			}
+		})
+	}
+}
+
+func TestFileIdempotencyStoreKeepsUnparsableRecords(t *testing.T) {
+	dir := t.TempDir()
+	store, err := cloud.NewFileIdempotencyStore(dir)
+	assert.Nil(t, err)
+	ctx := context.Background()
+	key := "HiResTopic/hi-res/video.mp4#1.1"
+	claimed, err := store.Claim(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	// A record which can't be parsed is kept until it's older than the ttl
+	assert.Nil(t, os.WriteFile(files[0], []byte("{"), 0o644))
+	claimed, err = store.Claim(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.False(t, claimed)
+
+	written := time.Now().Add(-2 * time.Hour)
+	assert.Nil(t, os.Chtimes(files[0], written, written))
+	claimed, err = store.Claim(ctx, key, time.Hour)
+	assert.Nil(t, err)
+	assert.True(t, claimed)
+
+	// Only the record is left in the directory
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+}
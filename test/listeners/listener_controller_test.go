@@ -0,0 +1,96 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listeners_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+// startListener starts a listener receiving the items of the channel.
+func startListener(t *testing.T, queue *cloud.WorkQueue, command cor.Command, items chan *cloud.WorkItem) *cloud.ListenerController {
+	receive := func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case item := <-items:
+				assert.Nil(t, queue.Enqueue(item))
+			}
+		}
+	}
+	pool := cloud.NewWorkerPool(queue, command, 1, testRetry(1))
+	return cloud.StartListener(context.Background(), "test", pool, receive)
+}
+
+func TestStopWaitsForRunningExecutions(t *testing.T) {
+	queue, err := cloud.NewWorkQueue("test", "")
+	assert.Nil(t, err)
+	started := make(chan struct{})
+	var completed atomic.Bool
+	command := NewFuncCommand("slow", func(context cor.Context) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		completed.Store(true)
+	})
+	items := make(chan *cloud.WorkItem, 1)
+	controller := startListener(t, queue, command, items)
+	items <- &cloud.WorkItem{ID: "1", Data: "{}"}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Empty(t, controller.Stop(ctx))
+	assert.True(t, completed.Load())
+	assert.Equal(t, 0, queue.Depth())
+}
+
+func TestStopAbandonsExecutionsAtTheDeadline(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := cloud.NewWorkQueue("test", dir)
+	assert.Nil(t, err)
+	started := make(chan struct{})
+	command := NewFuncCommand("blocked", func(context cor.Context) {
+		close(started)
+		<-context.GetContext().Done()
+		context.AddError("blocked", context.GetContext().Err())
+	})
+	items := make(chan *cloud.WorkItem, 1)
+	controller := startListener(t, queue, command, items)
+	items <- &cloud.WorkItem{ID: "1", Data: "{}"}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	abandoned := controller.Stop(ctx)
+	assert.Len(t, abandoned, 1)
+	assert.Equal(t, "1", abandoned[0].ID)
+	// A second call returns the result of the first one
+	assert.Equal(t, abandoned, controller.Stop(context.Background()))
+
+	// The abandoned item isn't a failed attempt, it is executed again after a restart
+	reopened, err := cloud.NewWorkQueue("test", dir)
+	assert.Nil(t, err)
+	item, err := reopened.Next(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "1", item.ID)
+	assert.Equal(t, 0, item.Attempts)
+}
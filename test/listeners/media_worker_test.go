@@ -0,0 +1,70 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listeners_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	common "github.com/GoogleCloudPlatform/media-search-solution/analyze/common"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/workflow"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFFmpeg copies the input file of the ffmpeg arguments to the output file, the last argument.
+const fakeFFmpeg = `#!/bin/sh
+while [ "$1" != "-i" ]; do shift; done
+input="$2"
+for output; do :; done
+cp "$input" "$output"
+`
+
+func TestMediaProxyWorkflowWritesProxy(t *testing.T) {
+	root := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "hi-res"), 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "low-res"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "hi-res", "video.mov"), []byte("video"), 0644))
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	assert.Nil(t, os.WriteFile(ffmpeg, []byte(fakeFFmpeg), 0755))
+
+	store, err := objectstore.NewLocalStore(root)
+	assert.Nil(t, err)
+	config := cloud.NewConfig()
+	config.Storage.Backend = objectstore.BackendLocal
+	config.Storage.LocalRoot = root
+	config.Storage.LowResOutputBucket = "low-res"
+	config.MediaWorker.FFmpegPath = ffmpeg
+	proxyWorkflow := workflow.NewMediaProxyWorkflow(cloud.NewConfigSnapshot(config), &cloud.ServiceClients{ObjectStore: store})
+
+	for range 2 {
+		chCtx := cor.NewBaseContext()
+		chCtx.SetContext(context.Background())
+		chCtx.Add(cor.CtxIn, `{"bucket":"hi-res","name":"video.mov","generation":"1"}`)
+		cor.Run(proxyWorkflow, chCtx)
+		assert.False(t, chCtx.HasErrors(), "%v", chCtx.GetErrors())
+	}
+
+	proxy, err := os.ReadFile(filepath.Join(root, "low-res", "video.mp4"))
+	assert.Nil(t, err)
+	assert.Equal(t, "video", string(proxy))
+	attrs, err := store.Attrs(context.Background(), "hi-res", "video.mov")
+	assert.Nil(t, err)
+	assert.Contains(t, attrs.Metadata[common.GENERATE_PROXY_STEP], `"output":"low-res/video.mp4"`)
+}
@@ -0,0 +1,232 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listeners_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/stretchr/testify/assert"
+)
+
+type FuncCommand struct {
+	cor.BaseCommand
+	fn func(context cor.Context)
+}
+
+func NewFuncCommand(name string, fn func(context cor.Context)) *FuncCommand {
+	return &FuncCommand{BaseCommand: *cor.NewBaseCommand(name), fn: fn}
+}
+
+func (c *FuncCommand) IsExecutable(context cor.Context) bool {
+	return context != nil && context.GetContext() != nil
+}
+
+func (c *FuncCommand) Execute(context cor.Context) {
+	c.fn(context)
+}
+
+func testRetry(maxAttempts int) cloud.RetryPolicy {
+	return cloud.RetryPolicy{MaxAttempts: maxAttempts, BackoffInMilliseconds: 1, MaxBackoffInMilliseconds: 5}
+}
+
+func TestWorkQueueSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := cloud.NewWorkQueue("test", dir)
+	assert.Nil(t, err)
+	assert.Nil(t, queue.Enqueue(&cloud.WorkItem{ID: "1", Data: "first"}))
+	assert.Nil(t, queue.Enqueue(&cloud.WorkItem{ID: "2", Data: "second"}))
+	// A duplicate delivery is queued once
+	assert.Nil(t, queue.Enqueue(&cloud.WorkItem{ID: "1", Data: "first"}))
+	assert.Equal(t, 2, queue.Depth())
+
+	item, err := queue.Next(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "1", item.ID)
+	assert.Nil(t, queue.Retry(item, time.Now().Add(time.Hour), errors.New("failed")))
+
+	// The items of a crashed process are loaded when the queue is reopened
+	reopened, err := cloud.NewWorkQueue("test", dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, reopened.Depth())
+	assert.Greater(t, reopened.OldestAge(), time.Duration(0))
+
+	item, err = reopened.Next(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "2", item.ID)
+	assert.Nil(t, reopened.Complete(item.ID))
+
+	// The retried item isn't due yet
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = reopened.Next(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, reopened.Depth())
+}
+
+func TestWorkerPoolRetriesFailedItems(t *testing.T) {
+	queue, err := cloud.NewWorkQueue("test", "")
+	assert.Nil(t, err)
+	var attempts atomic.Int32
+	done := make(chan struct{})
+	command := NewFuncCommand("flaky", func(context cor.Context) {
+		if attempts.Add(1) < 3 {
+			context.AddError("flaky", errors.New("transient"))
+			return
+		}
+		close(done)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := cloud.NewWorkerPool(queue, command, 2, testRetry(5))
+	go pool.Run(ctx)
+	assert.Nil(t, queue.Enqueue(&cloud.WorkItem{ID: "1", Data: "{}"}))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the item was not retried")
+	}
+	assert.Eventually(t, func() bool { return queue.Depth() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestWorkerPoolDeadLettersExhaustedItems(t *testing.T) {
+	queue, err := cloud.NewWorkQueue("test", "")
+	assert.Nil(t, err)
+	command := NewFuncCommand("broken", func(context cor.Context) {
+		context.AddError("broken", errors.New("permanent"))
+	})
+
+	var mu sync.Mutex
+	var deadLettered []*cloud.WorkItem
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := cloud.NewWorkerPool(queue, command, 1, testRetry(3))
+	pool.SetDeadLetter(func(_ context.Context, item *cloud.WorkItem) error {
+		mu.Lock()
+		defer mu.Unlock()
+		deadLettered = append(deadLettered, item)
+		return nil
+	})
+	go pool.Run(ctx)
+	assert.Nil(t, queue.Enqueue(&cloud.WorkItem{ID: "1", Data: "{}"}))
+
+	assert.Eventually(t, func() bool { return queue.Depth() == 0 }, 5*time.Second, time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, deadLettered, 1)
+	assert.Equal(t, 3, deadLettered[0].Attempts)
+	assert.Contains(t, deadLettered[0].LastError, "permanent")
+}
+
+// checkpointedChain returns a checkpointed chain whose second command fails while fail is set.
+func checkpointedChain(t *testing.T, firstRuns *atomic.Int32, fail *atomic.Bool) *cor.BaseChain {
+	store, err := cor.NewFileCheckpointStore(t.TempDir())
+	assert.Nil(t, err)
+	chain := cor.NewBaseChain("checkpointed")
+	chain.SetCheckpointStore(store)
+	chain.AddCommand(NewFuncCommand("first", func(context cor.Context) {
+		firstRuns.Add(1)
+		context.Add(cor.CtxOut, "first-out")
+	}))
+	chain.AddCommand(NewFuncCommand("second", func(context cor.Context) {
+		if fail.Load() {
+			context.AddError("second", errors.New("interrupted"))
+		}
+	}))
+	return chain
+}
+
+func TestWorkerPoolResumesCheckpointedItems(t *testing.T) {
+	var firstRuns atomic.Int32
+	var fail atomic.Bool
+	fail.Store(true)
+	chain := checkpointedChain(t, &firstRuns, &fail)
+
+	// An execution interrupted before a restart left a checkpoint and a queued item
+	chCtx := cor.NewBaseContext()
+	chCtx.SetContext(context.Background())
+	cor.Set(chCtx, cor.CheckpointIDKey, "1")
+	cor.Run(chain, chCtx)
+	queue, err := cloud.NewWorkQueue("test", t.TempDir())
+	assert.Nil(t, err)
+	assert.Nil(t, queue.Enqueue(&cloud.WorkItem{ID: "1", Data: "{}"}))
+
+	fail.Store(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cloud.NewWorkerPool(queue, chain, 2, testRetry(1)).Run(ctx)
+
+	assert.Eventually(t, func() bool { return queue.Depth() == 0 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, int32(1), firstRuns.Load())
+	pending, err := chain.PendingCheckpoints(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestWorkerPoolDeletesCheckpointsOfDeadLetteredItems(t *testing.T) {
+	var firstRuns atomic.Int32
+	var fail atomic.Bool
+	fail.Store(true)
+	chain := checkpointedChain(t, &firstRuns, &fail)
+	queue, err := cloud.NewWorkQueue("test", "")
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cloud.NewWorkerPool(queue, chain, 1, testRetry(3)).Run(ctx)
+	assert.Nil(t, queue.Enqueue(&cloud.WorkItem{ID: "1", Data: "{}"}))
+
+	assert.Eventually(t, func() bool { return queue.Depth() == 0 }, 5*time.Second, time.Millisecond)
+	// The retries are resumed from the checkpoint
+	assert.Equal(t, int32(1), firstRuns.Load())
+	pending, err := chain.PendingCheckpoints(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestWorkerPoolBoundsConcurrency(t *testing.T) {
+	queue, err := cloud.NewWorkQueue("test", "")
+	assert.Nil(t, err)
+	var running, peak atomic.Int32
+	command := NewFuncCommand("slow", func(context cor.Context) {
+		current := running.Add(1)
+		for {
+			observed := peak.Load()
+			if current <= observed || peak.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cloud.NewWorkerPool(queue, command, 2, testRetry(1)).Run(ctx)
+	for _, id := range []string{"1", "2", "3", "4", "5", "6"} {
+		assert.Nil(t, queue.Enqueue(&cloud.WorkItem{ID: id, Data: "{}"}))
+	}
+	assert.Eventually(t, func() bool { return queue.Depth() == 0 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), peak.Load())
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genai"
+)
+
+type fakeEmbeddingModel struct {
+	calls int
+}
+
+func (f *fakeEmbeddingModel) EmbedContent(_ context.Context, _ string, _ []*genai.Content, _ *genai.EmbedContentConfig) (*genai.EmbedContentResponse, error) {
+	f.calls++
+	return &genai.EmbedContentResponse{Embeddings: []*genai.ContentEmbedding{{Values: []float32{0.1, 0.2}}}}, nil
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	schema := &genai.Schema{Type: genai.TypeString}
+
+	recorder, err := cloud.LoadCassette(path, cloud.CassetteModeRecord)
+	assert.Nil(t, err)
+	scripted := cloud.NewScriptedModel(cloud.ScriptedResponse{Text: `"first"`}, cloud.ScriptedResponse{Text: `"second"`})
+	model := newTestModel(recorder.GenerativeModel(scripted))
+
+	value, err := generate(t, model, videoContents(), schema)
+	assert.Nil(t, err)
+	assert.Equal(t, `"first"`, value)
+	value, err = generate(t, model, videoContents(), schema)
+	assert.Nil(t, err)
+	assert.Equal(t, `"second"`, value)
+
+	// Created caches are recorded with their name
+	cache, err := model.CreateCachedContent(context.Background(), &genai.CreateCachedContentConfig{Contents: videoContents()})
+	assert.Nil(t, err)
+	assert.Equal(t, "cachedContents/test-model-1", cache.Name)
+
+	embedder := &fakeEmbeddingModel{}
+	resp, err := recorder.EmbeddingModel(embedder).EmbedContent(context.Background(), "embedding", genai.Text("query"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []float32{0.1, 0.2}, resp.Embeddings[0].Values)
+
+	// Replay serves the recordings in order without the wrapped models
+	player, err := cloud.LoadCassette(path, cloud.CassetteModeReplay)
+	assert.Nil(t, err)
+	assert.Len(t, player.Interactions, 4)
+	assert.Equal(t, cloud.InteractionCache, player.Interactions[2].Request.Kind)
+	assert.Equal(t, 10.0, player.Interactions[0].Request.Files[0].StartOffset)
+	assert.Equal(t, 20.0, player.Interactions[0].Request.Files[0].EndOffset)
+	assert.NotEmpty(t, player.Interactions[0].Request.PromptHash)
+
+	model = newTestModel(player.GenerativeModel(nil))
+	value, err = generate(t, model, videoContents(), schema)
+	assert.Nil(t, err)
+	assert.Equal(t, `"first"`, value)
+	value, err = generate(t, model, videoContents(), schema)
+	assert.Nil(t, err)
+	assert.Equal(t, `"second"`, value)
+	value, err = generate(t, model, videoContents(), schema)
+	assert.Nil(t, err)
+	assert.Equal(t, `"second"`, value)
+
+	// The replayed cache is valid from the replay for the recorded time to live
+	replayed, err := model.CreateCachedContent(context.Background(), &genai.CreateCachedContentConfig{Contents: videoContents()})
+	assert.Nil(t, err)
+	assert.Equal(t, cache.Name, replayed.Name)
+	assert.True(t, replayed.ExpireTime.After(time.Now().Add(50*time.Minute)))
+
+	resp, err = player.EmbeddingModel(nil).EmbedContent(context.Background(), "embedding", genai.Text("query"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []float32{0.1, 0.2}, resp.Embeddings[0].Values)
+}
+
+func TestCassetteReplayMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := cloud.LoadCassette(path, cloud.CassetteModeRecord)
+	assert.Nil(t, err)
+	model := recorder.GenerativeModel(cloud.NewScriptedModel(cloud.ScriptedResponse{Text: "recorded"}))
+	_, err = model.GenerateContent(context.Background(), "test-model", videoContents(), nil)
+	assert.Nil(t, err)
+
+	player, err := cloud.LoadCassette(path, cloud.CassetteModeReplay)
+	assert.Nil(t, err)
+	contents := videoContents()
+	contents[0].Parts[1].VideoMetadata.EndOffset = 30 * time.Second
+	_, err = player.GenerativeModel(nil).GenerateContent(context.Background(), "test-model", contents, nil)
+	assert.ErrorIs(t, err, cloud.ErrNoRecording)
+	assert.Contains(t, err.Error(), "files:")
+	assert.Contains(t, err.Error(), `"end_offset":20`)
+	assert.Contains(t, err.Error(), `"end_offset":30`)
+	assert.NotContains(t, err.Error(), "prompt:")
+
+	_, err = cloud.LoadCassette(filepath.Join(t.TempDir(), "missing.json"), cloud.CassetteModeReplay)
+	assert.NotNil(t, err)
+}
+
+func TestCassetteReplayRunsOffline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := cloud.LoadCassette(path, cloud.CassetteModeRecord)
+	assert.Nil(t, err)
+	_, err = recorder.GenerativeModel(cloud.NewScriptedModel(cloud.ScriptedResponse{Text: "recorded"})).
+		GenerateContent(context.Background(), "gemini", videoContents(), nil)
+	assert.Nil(t, err)
+
+	config := &cloud.Config{
+		Storage:     cloud.Storage{Backend: "local", LocalRoot: t.TempDir()},
+		EventSource: cloud.EventSourceConfig{Type: cloud.EventSourceMemory},
+		AgentModels: map[string]cloud.VertexAiLLMModel{"flash": {Model: "gemini"}},
+		Cassette:    cloud.CassetteConfig{Mode: cloud.CassetteModeReplay, Path: path},
+	}
+	clients, err := cloud.NewCloudServiceClients(context.Background(), config)
+	assert.Nil(t, err)
+	defer clients.Close()
+	assert.Nil(t, clients.GenAIClient)
+	assert.Nil(t, clients.BiqQueryClient)
+
+	resp, err := clients.AgentModels["flash"].Model.GenerateContent(context.Background(), "gemini", videoContents(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "recorded", resp.Text())
+
+	// The Pub/Sub event source isn't available offline
+	config.EventSource.Type = cloud.EventSourcePubSub
+	_, err = cloud.NewCloudServiceClients(context.Background(), config)
+	assert.NotNil(t, err)
+}
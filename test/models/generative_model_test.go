@@ -0,0 +1,209 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/metric/noop"
+	"google.golang.org/genai"
+)
+
+func newTestModel(model cloud.GenerativeModel) *cloud.QuotaAwareGenerativeAIModel {
+	config := &genai.GenerateContentConfig{
+		Temperature:       genai.Ptr[float32](0.2),
+		SystemInstruction: genai.NewContentFromText("default instruction", genai.RoleUser),
+	}
+	quotaAware := cloud.NewQuotaAwareModel(config, "test-model", model, 600, 0)
+	quotaAware.RetryPolicy = cloud.RetryPolicy{BackoffInMilliseconds: 1}
+	return quotaAware
+}
+
+func generate(t *testing.T, model *cloud.QuotaAwareGenerativeAIModel, contents []*genai.Content, schema *genai.Schema) (string, error) {
+	meter := noop.NewMeterProvider().Meter("test")
+	counter, err := meter.Int64Counter("counter")
+	assert.Nil(t, err)
+	return cloud.GenerateMultiModalResponse(context.Background(), counter, counter, counter, 0, model, "system", "", contents, schema)
+}
+
+func videoContents() []*genai.Content {
+	return []*genai.Content{{
+		Role: genai.RoleUser,
+		Parts: []*genai.Part{
+			genai.NewPartFromText("describe the video"),
+			{
+				FileData:      &genai.FileData{FileURI: "gs://bucket/video.mp4", MIMEType: "video/mp4"},
+				VideoMetadata: &genai.VideoMetadata{StartOffset: 10 * time.Second, EndOffset: 20 * time.Second},
+			},
+		},
+	}}
+}
+
+func TestScriptedModel(t *testing.T) {
+	scripted := cloud.NewScriptedModel(
+		cloud.ScriptedResponse{Text: ""},
+		cloud.ScriptedResponse{Text: `{"title":"test"}`},
+	)
+	schema := &genai.Schema{Type: genai.TypeObject, Properties: map[string]*genai.Schema{"title": {Type: genai.TypeString}}}
+
+	value, err := generate(t, newTestModel(scripted), videoContents(), schema)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"title":"test"}`, value)
+
+	// The empty response is retried
+	requests := scripted.GetRequests()
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "test-model", requests[0].Model)
+	assert.Equal(t, schema, requests[0].Config.ResponseSchema)
+	assert.Equal(t, "system", requests[0].Config.SystemInstruction.Parts[0].Text)
+
+	_, err = scripted.GenerateContent(context.Background(), "test-model", nil, nil)
+	assert.ErrorIs(t, err, cloud.ErrScriptExhausted)
+
+	cache, err := newTestModel(scripted).CreateCachedContent(context.Background(), &genai.CreateCachedContentConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, "test-model", cache.Model)
+}
+
+func TestOpenAICompatibleModel(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+		_, _ = w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": "{\"title\":\"test\"}"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17}
+		}`))
+	}))
+	defer server.Close()
+
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	assert.Nil(t, err)
+	_, err = store.Write(context.Background(), "bucket", "video.mp4", "video/mp4", strings.NewReader("video"))
+	assert.Nil(t, err)
+	model := cloud.NewOpenAICompatibleModel(server.URL+"/v1/", "secret", server.Client())
+	model.SetObjectStore(store)
+	schema := &genai.Schema{Type: genai.TypeObject, Properties: map[string]*genai.Schema{"title": {Type: genai.TypeString}}}
+
+	value, err := generate(t, newTestModel(model), videoContents(), schema)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"title":"test"}`, value)
+
+	assert.Equal(t, "test-model", request["model"])
+	messages := request["messages"].([]interface{})
+	assert.Len(t, messages, 2)
+	system := messages[0].(map[string]interface{})
+	assert.Equal(t, "system", system["role"])
+	user := messages[1].(map[string]interface{})["content"].([]interface{})
+	assert.Equal(t, "describe the video", user[0].(map[string]interface{})["text"])
+	// The gs:// file is read from the object store and sent inline, with the offsets as text
+	video := user[1].(map[string]interface{})["video_url"].(map[string]interface{})
+	assert.Equal(t, "data:video/mp4;base64,dmlkZW8=", video["url"])
+	assert.Equal(t, "Only consider the video from 10s to 20s.", user[2].(map[string]interface{})["text"])
+	format := request["response_format"].(map[string]interface{})
+	assert.Equal(t, "json_schema", format["type"])
+	jsonSchema := format["json_schema"].(map[string]interface{})["schema"].(map[string]interface{})
+	assert.Equal(t, "object", jsonSchema["type"])
+
+	_, err = model.CreateCachedContent(context.Background(), "test-model", nil)
+	assert.ErrorIs(t, err, cloud.ErrCachingNotSupported)
+}
+
+func TestOpenAICompatibleModelVideoOffsets(t *testing.T) {
+	var urls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+		content := request["messages"].([]interface{})[0].(map[string]interface{})["content"].([]interface{})
+		urls = append(urls, content[1].(map[string]interface{})["video_url"].(map[string]interface{})["url"].(string))
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}]}`))
+	}))
+	defer server.Close()
+
+	model := cloud.NewOpenAICompatibleModel(server.URL, "", server.Client())
+	for _, metadata := range []*genai.VideoMetadata{
+		{StartOffset: 30 * time.Second},
+		{StartOffset: 30 * time.Second, EndOffset: 45 * time.Second},
+		{},
+		nil,
+	} {
+		contents := videoContents()
+		contents[0].Parts[1].FileData.FileURI = "https://media.example.com/video.mp4"
+		contents[0].Parts[1].VideoMetadata = metadata
+		_, err := model.GenerateContent(context.Background(), "test-model", contents, nil)
+		assert.Nil(t, err)
+	}
+	// An unset end offset plays to the end of the video
+	assert.Equal(t, []string{
+		"https://media.example.com/video.mp4#t=30",
+		"https://media.example.com/video.mp4#t=30,45",
+		"https://media.example.com/video.mp4",
+		"https://media.example.com/video.mp4",
+	}, urls)
+}
+
+func TestOpenAICompatibleModelRequiresObjectStoreForGCSFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request was sent")
+	}))
+	defer server.Close()
+
+	model := cloud.NewOpenAICompatibleModel(server.URL, "", server.Client())
+	_, err := model.GenerateContent(context.Background(), "test-model", videoContents(), nil)
+	assert.ErrorContains(t, err, "gs://bucket/video.mp4")
+
+	// A missing object fails the request
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	assert.Nil(t, err)
+	model.SetObjectStore(store)
+	_, err = model.GenerateContent(context.Background(), "test-model", videoContents(), nil)
+	assert.ErrorIs(t, err, objectstore.ErrObjectNotExist)
+}
+
+func TestOpenAICompatibleModelError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	model := cloud.NewOpenAICompatibleModel(server.URL, "", server.Client())
+	contents := []*genai.Content{genai.NewContentFromText("describe the video", genai.RoleUser)}
+	_, err := model.GenerateContent(context.Background(), "test-model", contents, nil)
+	var openAIError *cloud.OpenAIError
+	assert.True(t, errors.As(err, &openAIError))
+	assert.Equal(t, http.StatusServiceUnavailable, openAIError.StatusCode)
+}
+
+func TestNewGenerativeModel(t *testing.T) {
+	model, err := cloud.NewGenerativeModel(cloud.VertexAiLLMModel{Provider: cloud.ModelProviderOpenAI, Endpoint: "http://localhost:8000/v1"}, nil, nil)
+	assert.Nil(t, err)
+	assert.IsType(t, &cloud.OpenAICompatibleModel{}, model)
+
+	_, err = cloud.NewGenerativeModel(cloud.VertexAiLLMModel{Provider: cloud.ModelProviderOpenAI}, nil, nil)
+	assert.NotNil(t, err)
+	_, err = cloud.NewGenerativeModel(cloud.VertexAiLLMModel{Provider: "unknown"}, nil, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/stretchr/testify/assert"
+)
+
+func leaseAll(t *testing.T, store cloud.LeaseStore, count int) []time.Duration {
+	request := cloud.LeaseRequest{Bucket: "model.requests", Amount: 1, Capacity: 10, RefillPerSecond: 1}
+	waits := make([]time.Duration, count)
+	var wg sync.WaitGroup
+	for i := range count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lease, err := store.Lease(context.Background(), request)
+			assert.Nil(t, err)
+			waits[i] = lease.Wait
+		}()
+	}
+	wg.Wait()
+	return waits
+}
+
+func countWaiting(waits []time.Duration) int {
+	waiting := 0
+	for _, wait := range waits {
+		if wait > 0 {
+			waiting++
+		}
+	}
+	return waiting
+}
+
+func TestMemoryLeaseStore(t *testing.T) {
+	store := cloud.NewMemoryLeaseStore()
+	waits := leaseAll(t, store, 12)
+	assert.Equal(t, 2, countWaiting(waits))
+
+	_, err := store.Lease(context.Background(), cloud.LeaseRequest{Bucket: "invalid"})
+	assert.NotNil(t, err)
+}
+
+func TestFileLeaseStoreIsShared(t *testing.T) {
+	dir := t.TempDir()
+	first, err := cloud.NewFileLeaseStore(dir)
+	assert.Nil(t, err)
+	second, err := cloud.NewFileLeaseStore(dir)
+	assert.Nil(t, err)
+
+	waits := append(leaseAll(t, first, 6), leaseAll(t, second, 6)...)
+	assert.Equal(t, 2, countWaiting(waits))
+}
+
+func TestHTTPLeaseStore(t *testing.T) {
+	server := httptest.NewServer(cloud.NewLeaseHandler(cloud.NewMemoryLeaseStore(), "shared-token"))
+	defer server.Close()
+
+	store := cloud.NewHTTPLeaseStore(server.URL, "shared-token", server.Client())
+	waits := leaseAll(t, store, 11)
+	assert.Equal(t, 1, countWaiting(waits))
+
+	_, err := store.Lease(context.Background(), cloud.LeaseRequest{Bucket: "invalid"})
+	assert.NotNil(t, err)
+}
+
+func TestLeaseHandlerRequiresToken(t *testing.T) {
+	request := cloud.LeaseRequest{Bucket: "model.requests", Amount: 1, Capacity: 10, RefillPerSecond: 1}
+	server := httptest.NewServer(cloud.NewLeaseHandler(cloud.NewMemoryLeaseStore(), "shared-token"))
+	defer server.Close()
+	for _, token := range []string{"", "wrong-token"} {
+		_, err := cloud.NewHTTPLeaseStore(server.URL, token, server.Client()).Lease(context.Background(), request)
+		assert.ErrorContains(t, err, "status 401")
+	}
+
+	// A handler without a token rejects every request
+	open := httptest.NewServer(cloud.NewLeaseHandler(cloud.NewMemoryLeaseStore(), ""))
+	defer open.Close()
+	_, err := cloud.NewHTTPLeaseStore(open.URL, "", open.Client()).Lease(context.Background(), request)
+	assert.ErrorContains(t, err, "status 401")
+}
+
+func TestLimitersShareCoordinatedBudget(t *testing.T) {
+	coordinator, err := cloud.NewQuotaCoordinator(cloud.Quota{Coordinator: cloud.QuotaCoordinatorLocal})
+	assert.Nil(t, err)
+
+	// Two jobs using the same model, each within its local limits
+	first := cloud.NewAdaptiveRateLimiter("shared-model", 60, 0)
+	first.SetCoordinator(coordinator)
+	second := cloud.NewAdaptiveRateLimiter("shared-model", 60, 0)
+	second.SetCoordinator(coordinator)
+
+	assert.Nil(t, first.Wait(context.Background(), 0))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, second.Wait(ctx, 0), context.DeadlineExceeded)
+
+	none, err := cloud.NewQuotaCoordinator(cloud.Quota{})
+	assert.Nil(t, err)
+	assert.Nil(t, none)
+	_, err = cloud.NewQuotaCoordinator(cloud.Quota{Coordinator: cloud.QuotaCoordinatorHTTP})
+	assert.NotNil(t, err)
+}
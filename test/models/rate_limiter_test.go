@@ -0,0 +1,134 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/genai"
+)
+
+func TestAdaptiveRateLimiterAIMD(t *testing.T) {
+	limiter := cloud.NewAdaptiveRateLimiter("aimd", 600, 0)
+	assert.Equal(t, 600.0, limiter.GetRequestsPerMinute())
+	assert.Equal(t, -1.0, limiter.GetTokensAvailable())
+
+	limiter.OnThrottle()
+	assert.Equal(t, 300.0, limiter.GetRequestsPerMinute())
+	limiter.OnThrottle()
+	assert.Equal(t, 150.0, limiter.GetRequestsPerMinute())
+
+	limiter.OnSuccess()
+	assert.Equal(t, 180.0, limiter.GetRequestsPerMinute())
+	for range 20 {
+		limiter.OnSuccess()
+	}
+	assert.Equal(t, 600.0, limiter.GetRequestsPerMinute())
+
+	for range 20 {
+		limiter.OnThrottle()
+	}
+	assert.Equal(t, float64(cloud.MinRequestsPerMinute), limiter.GetRequestsPerMinute())
+}
+
+func TestAdaptiveRateLimiterWait(t *testing.T) {
+	limiter := cloud.NewAdaptiveRateLimiter("wait", 60, 100)
+	assert.Nil(t, limiter.Wait(context.Background(), 100))
+
+	// The tokens budget is spent, the next request can't be allowed before the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.NotNil(t, limiter.Wait(ctx, 10))
+
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	assert.NotNil(t, cloud.NewAdaptiveRateLimiter("cancelled", 60, 0).Wait(cancelled, 0))
+}
+
+func TestIsResourceExhausted(t *testing.T) {
+	assert.True(t, cloud.IsResourceExhausted(genai.APIError{Code: http.StatusTooManyRequests}))
+	assert.True(t, cloud.IsResourceExhausted(genai.APIError{Code: 400, Status: "RESOURCE_EXHAUSTED"}))
+	assert.True(t, cloud.IsResourceExhausted(&cloud.OpenAIError{StatusCode: http.StatusTooManyRequests}))
+	assert.False(t, cloud.IsResourceExhausted(&cloud.OpenAIError{StatusCode: http.StatusInternalServerError}))
+	assert.False(t, cloud.IsResourceExhausted(errors.New("failed")))
+	assert.False(t, cloud.IsResourceExhausted(nil))
+}
+
+func TestQuotaAwareModelBacksOff(t *testing.T) {
+	scripted := cloud.NewScriptedModel(
+		cloud.ScriptedResponse{Err: genai.APIError{Code: http.StatusTooManyRequests, Status: "RESOURCE_EXHAUSTED"}},
+		cloud.ScriptedResponse{Err: errors.New("invalid argument")},
+	)
+	model := newTestModel(scripted)
+
+	// Exhausted quota reduces the rate, the retries are left to the retry policy
+	_, err := model.GenerateContent(context.Background(), "", "", genai.Text("prompt"), nil)
+	assert.True(t, cloud.IsResourceExhausted(err))
+	assert.Equal(t, 300.0, model.RateLimit.GetRequestsPerMinute())
+
+	_, err = model.GenerateContent(context.Background(), "", "", genai.Text("prompt"), nil)
+	assert.EqualError(t, err, "invalid argument")
+	assert.Equal(t, 300.0, model.RateLimit.GetRequestsPerMinute())
+	assert.Len(t, scripted.GetRequests(), 2)
+}
+
+func TestReplacedRateLimiterUnregistersGauges(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	defer otel.SetMeterProvider(previous)
+
+	// The models of the test observed by the requests per minute gauge, the limiters created
+	// by other tests before the provider was set are observed as well
+	observed := func() []string {
+		var data metricdata.ResourceMetrics
+		assert.Nil(t, reader.Collect(context.Background(), &data))
+		var models []string
+		for _, scope := range data.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				if m.Name != "agent_model.rate_limit.requests_per_minute" {
+					continue
+				}
+				for _, point := range m.Data.(metricdata.Gauge[float64]).DataPoints {
+					model, _ := point.Attributes.Value(attribute.Key("model"))
+					if strings.HasPrefix(model.AsString(), "replaced-") {
+						models = append(models, model.AsString())
+					}
+				}
+			}
+		}
+		return models
+	}
+
+	model := cloud.NewQuotaAwareModel(&genai.GenerateContentConfig{}, "replaced-first", cloud.NewScriptedModel(), 60, 0)
+	assert.Equal(t, []string{"replaced-first"}, observed())
+
+	model.SetRateLimit(cloud.NewAdaptiveRateLimiter("replaced-second", 60, 0))
+	assert.Equal(t, []string{"replaced-second"}, observed())
+
+	model.Close()
+	assert.Empty(t, observed())
+}
@@ -0,0 +1,124 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/genai"
+)
+
+// retryCounts generates a response with a metered retry counter, returning the counts by reason and outcome.
+func retryCounts(t *testing.T, model *cloud.QuotaAwareGenerativeAIModel, schema *genai.Schema) (string, error, map[string]int64) {
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	tokens, _ := meter.Int64Counter("tokens")
+	retries, _ := meter.Int64Counter("retries")
+
+	value, err := cloud.GenerateMultiModalResponse(context.Background(), tokens, tokens, retries, 0, model, "", "", genai.Text("prompt"), schema)
+
+	var data metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &data))
+	counts := make(map[string]int64)
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != "retries" {
+				continue
+			}
+			for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				reason, _ := point.Attributes.Value(attribute.Key("reason"))
+				outcome, _ := point.Attributes.Value(attribute.Key("outcome"))
+				counts[reason.AsString()+"/"+outcome.AsString()] += point.Value
+			}
+		}
+	}
+	return value, err, counts
+}
+
+func TestClassifyError(t *testing.T) {
+	assert.Equal(t, cloud.ErrorClassQuota, cloud.ClassifyError(genai.APIError{Code: http.StatusTooManyRequests}))
+	assert.Equal(t, cloud.ErrorClassServer, cloud.ClassifyError(genai.APIError{Code: http.StatusServiceUnavailable}))
+	assert.Equal(t, cloud.ErrorClassServer, cloud.ClassifyError(&cloud.OpenAIError{StatusCode: http.StatusBadGateway}))
+	assert.Equal(t, cloud.ErrorClassOther, cloud.ClassifyError(genai.APIError{Code: http.StatusBadRequest}))
+	assert.Equal(t, cloud.ErrorClassSafety, cloud.ClassifyError(&cloud.GenerationError{Class: cloud.ErrorClassSafety, Err: errors.New("blocked")}))
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	policy := cloud.RetryPolicy{}
+	assert.Equal(t, cloud.MaxRetries+1, policy.GetMaxAttempts())
+	assert.True(t, policy.Retries(cloud.ErrorClassQuota))
+	assert.False(t, policy.Retries(cloud.ErrorClassSafety))
+	assert.Equal(t, time.Second, policy.Backoff(0))
+	assert.Equal(t, 4*time.Second, policy.Backoff(2))
+	assert.Equal(t, time.Minute, policy.Backoff(10))
+
+	models := map[string]cloud.VertexAiLLMModel{"flash": {}}
+	assert.Nil(t, cloud.RetryPolicy{RetryOn: []string{"quota"}, FallbackModel: "flash"}.Validate(models))
+	assert.NotNil(t, cloud.RetryPolicy{RetryOn: []string{"unknown"}}.Validate(models))
+	assert.NotNil(t, cloud.RetryPolicy{FallbackModel: "pro"}.Validate(models))
+}
+
+func TestGenerateRetriesByClass(t *testing.T) {
+	scripted := cloud.NewScriptedModel(
+		cloud.ScriptedResponse{Err: genai.APIError{Code: http.StatusServiceUnavailable}},
+		cloud.ScriptedResponse{Text: ""},
+		cloud.ScriptedResponse{Text: "not json"},
+		cloud.ScriptedResponse{Text: `{"ok":true}`},
+	)
+	value, err, counts := retryCounts(t, newTestModel(scripted), &genai.Schema{Type: genai.TypeObject})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"ok":true}`, value)
+	assert.Equal(t, map[string]int64{"server/retry": 1, "empty/retry": 1, "invalid_json/retry": 1}, counts)
+}
+
+func TestGenerateDoesNotRetryUnlistedClass(t *testing.T) {
+	scripted := cloud.NewScriptedModel(cloud.ScriptedResponse{Err: genai.APIError{Code: http.StatusBadRequest}})
+	_, err, counts := retryCounts(t, newTestModel(scripted), nil)
+	assert.NotNil(t, err)
+	assert.Len(t, scripted.GetRequests(), 1)
+	assert.Equal(t, map[string]int64{"other/not_retryable": 1}, counts)
+}
+
+func TestGenerateFallsBack(t *testing.T) {
+	primary := cloud.NewScriptedModel(
+		cloud.ScriptedResponse{Err: genai.APIError{Code: http.StatusTooManyRequests}},
+		cloud.ScriptedResponse{Err: genai.APIError{Code: http.StatusTooManyRequests}},
+	)
+	secondary := cloud.NewScriptedModel(cloud.ScriptedResponse{Text: "fallback"})
+	model := newTestModel(primary)
+	model.RetryPolicy.MaxAttempts = 2
+	model.Fallback = newTestModel(secondary)
+
+	value, err, counts := retryCounts(t, model, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "fallback", value)
+	assert.Equal(t, map[string]int64{"quota/retry": 1, "quota/exhausted": 1, "quota/fallback": 1}, counts)
+}
+
+func TestGenerateSafetyBlock(t *testing.T) {
+	blocked := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonSafety}}}
+	assert.True(t, cloud.IsBlocked(blocked))
+	assert.False(t, cloud.IsBlocked(&genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonStop}}}))
+	assert.True(t, cloud.IsBlocked(&genai.GenerateContentResponse{PromptFeedback: &genai.GenerateContentResponsePromptFeedback{BlockReason: genai.BlockedReasonSafety}}))
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/objectstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store, err := objectstore.NewLocalStore(root)
+	assert.Nil(t, err)
+
+	attrs, err := store.Write(ctx, "media", "trailers/video.mp4", "video/mp4", strings.NewReader("content"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), attrs.Size)
+	assert.Equal(t, int64(1), attrs.Generation)
+	assert.FileExists(t, filepath.Join(root, "media", "trailers", "video.mp4"))
+
+	data, err := objectstore.ReadAll(ctx, store, "media", "trailers/video.mp4")
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(data))
+
+	attrs, err = store.UpdateMetadata(ctx, "media", "trailers/video.mp4", map[string]string{"step": "done", "other": "x"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), attrs.Metageneration)
+	attrs, err = store.UpdateMetadata(ctx, "media", "trailers/video.mp4", map[string]string{"other": ""})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"step": "done"}, attrs.Metadata)
+
+	attrs, err = store.Attrs(ctx, "media", "trailers/video.mp4")
+	assert.Nil(t, err)
+	assert.Equal(t, "video/mp4", attrs.ContentType)
+	assert.Equal(t, "done", attrs.Metadata["step"])
+
+	// A new generation replaces the metadata
+	attrs, err = store.Write(ctx, "media", "trailers/video.mp4", "video/mp4", strings.NewReader("new"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), attrs.Generation)
+	assert.Empty(t, attrs.Metadata)
+
+	_, err = store.Write(ctx, "media", "other.mp4", "", strings.NewReader("other"))
+	assert.Nil(t, err)
+	objects, err := store.List(ctx, "media", "trailers/")
+	assert.Nil(t, err)
+	assert.Len(t, objects, 1)
+	assert.Equal(t, "trailers/video.mp4", objects[0].Name)
+	objects, err = store.List(ctx, "media", "")
+	assert.Nil(t, err)
+	assert.Len(t, objects, 2)
+
+	assert.Nil(t, store.Delete(ctx, "media", "trailers/video.mp4"))
+	_, err = store.Attrs(ctx, "media", "trailers/video.mp4")
+	assert.ErrorIs(t, err, objectstore.ErrObjectNotExist)
+	assert.ErrorIs(t, store.Delete(ctx, "media", "trailers/video.mp4"), objectstore.ErrObjectNotExist)
+	assert.NoFileExists(t, filepath.Join(root, "media", "trailers", "video.mp4"+objectstore.MetadataSuffix))
+
+	_, err = store.Write(ctx, "media", "../escape", "", strings.NewReader("x"))
+	assert.NotNil(t, err)
+}
+
+func TestLocalStoreList(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "root")
+	store, err := objectstore.NewLocalStore(root)
+	assert.Nil(t, err)
+	_, err = store.Write(ctx, "media", "video.mp4", "video/mp4", strings.NewReader("content"))
+	assert.Nil(t, err)
+
+	// Sidecars being written aren't objects
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "media", "video.mp4"+objectstore.MetadataSuffix+".tmp"), []byte("{"), 0o644))
+	objects, err := store.List(ctx, "media", "")
+	assert.Nil(t, err)
+	assert.Len(t, objects, 1)
+	assert.Equal(t, "video.mp4", objects[0].Name)
+
+	// Buckets can't escape the root
+	for _, bucket := range []string{"..", "../..", "media/..", ""} {
+		_, err = store.List(ctx, bucket, "")
+		assert.NotNil(t, err, bucket)
+	}
+}
+
+func TestLocalStoreWriteIfGenerationMatch(t *testing.T) {
+	ctx := context.Background()
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	assert.Nil(t, err)
+
+	// Generation 0 only creates the object
+	attrs, err := store.WriteIfGenerationMatch(ctx, "state", "key.json", "", strings.NewReader("first"), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), attrs.Generation)
+	_, err = store.WriteIfGenerationMatch(ctx, "state", "key.json", "", strings.NewReader("second"), 0)
+	assert.ErrorIs(t, err, objectstore.ErrPreconditionFailed)
+
+	// A stale generation doesn't replace the object
+	attrs, err = store.WriteIfGenerationMatch(ctx, "state", "key.json", "", strings.NewReader("second"), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), attrs.Generation)
+	_, err = store.WriteIfGenerationMatch(ctx, "state", "key.json", "", strings.NewReader("third"), 1)
+	assert.ErrorIs(t, err, objectstore.ErrPreconditionFailed)
+
+	data, err := objectstore.ReadAll(ctx, store, "state", "key.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "second", string(data))
+}
+
+func TestLocalStoreWithoutSidecar(t *testing.T) {
+	root := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "media"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "media", "copied.mp4"), []byte("copied"), 0o644))
+
+	store, err := objectstore.NewLocalStore(root)
+	assert.Nil(t, err)
+	attrs, err := store.Attrs(context.Background(), "media", "copied.mp4")
+	assert.Nil(t, err)
+	assert.Equal(t, "video/mp4", attrs.ContentType)
+	assert.Empty(t, attrs.Metadata)
+}
+
+func TestCheckpointStoreOnLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := objectstore.NewLocalStore(t.TempDir())
+	assert.Nil(t, err)
+	checkpoints := cloud.NewGCSCheckpointStore(store, "state", "checkpoints")
+
+	chCtx := cor.NewBaseContext()
+	chCtx.Add(cor.CtxIn, "in")
+	cor.Set(chCtx, cloud.GCSObjectKey, &cloud.GCSObject{Bucket: "media", Name: "video.mp4"})
+	assert.Nil(t, checkpoints.Save(ctx, cor.NewCheckpoint("chain", "id-1", 1, chCtx)))
+
+	ids, err := checkpoints.List(ctx, "chain")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id-1"}, ids)
+
+	checkpoint, err := checkpoints.Load(ctx, "chain", "id-1")
+	assert.Nil(t, err)
+	restored, err := checkpoint.Restore(ctx)
+	assert.Nil(t, err)
+	obj, err := cor.GetKey(restored, cloud.GCSObjectKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "video.mp4", obj.Name)
+
+	assert.Nil(t, checkpoints.Delete(ctx, "chain", "id-1"))
+	_, err = checkpoints.Load(ctx, "chain", "id-1")
+	assert.ErrorIs(t, err, cor.ErrCheckpointNotFound)
+}
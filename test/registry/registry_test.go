@@ -0,0 +1,150 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/workflow"
+	"github.com/stretchr/testify/assert"
+)
+
+const workflowsToml = `
+[workflows.main]
+[[workflows.main.steps]]
+command = "append"
+output_param = "first"
+
+[[workflows.main.steps]]
+parallel = true
+max_concurrency = 2
+  [[workflows.main.steps.steps]]
+  command = "append"
+  [[workflows.main.steps.steps]]
+  workflow = "child"
+
+[workflows.child]
+continue_on_failure = true
+[[workflows.child.steps]]
+command = "append"
+`
+
+type appendCommand struct {
+	cor.BaseCommand
+}
+
+func (c *appendCommand) IsExecutable(context cor.Context) bool {
+	return context != nil && context.GetContext() != nil
+}
+
+func (c *appendCommand) Execute(context cor.Context) {
+	context.Add(c.GetOutputParam(), c.GetName())
+}
+
+func newRegistry() *workflow.Registry {
+	registry := workflow.NewRegistry()
+	registry.Register("append", func(step workflow.StepParams) (cor.Command, error) {
+		command := &appendCommand{BaseCommand: *cor.NewBaseCommand(step.Name)}
+		step.Apply(&command.BaseCommand)
+		return command, nil
+	})
+	return registry
+}
+
+func decode(t *testing.T, in string) *cloud.Config {
+	config := cloud.NewConfig()
+	_, err := toml.Decode(in, config)
+	assert.Nil(t, err)
+	return config
+}
+
+func TestBuildWorkflows(t *testing.T) {
+	registry := newRegistry()
+	config := decode(t, workflowsToml)
+
+	assert.Nil(t, registry.Build(config.Workflows))
+
+	main := registry.Get("main").(*cor.BaseChain)
+	assert.Len(t, main.GetCommands(), 2)
+	parallel := main.GetCommands()[1].(*cor.ParallelChain)
+	assert.Equal(t, 2, parallel.GetMaxConcurrency())
+	assert.Equal(t, "child", parallel.GetCommands()[1].GetName())
+
+	chCtx := cor.NewBaseContext()
+	chCtx.SetContext(context.Background())
+	chCtx.Add(cor.CtxIn, "in")
+	registry.Workflow("main").Execute(chCtx)
+
+	assert.False(t, chCtx.HasErrors())
+	assert.Equal(t, "append", chCtx.Get("first"))
+}
+
+func TestValidateWorkflows(t *testing.T) {
+	registry := newRegistry()
+	config := decode(t, `
+[workflows.a]
+[[workflows.a.steps]]
+workflow = "b"
+[[workflows.a.steps]]
+command = "unknown"
+
+[workflows.b]
+parallel = true
+checkpoint = true
+[[workflows.b.steps]]
+workflow = "a"
+[[workflows.b.steps]]
+command = "append"
+workflow = "a"
+`)
+
+	err := registry.Build(config.Workflows)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `workflows.a.steps[1]: unknown command "unknown"`)
+	assert.Contains(t, err.Error(), "cycle detected")
+	assert.Contains(t, err.Error(), "workflows.b.steps[1]: exactly one of command, workflow or steps must be set")
+	assert.Contains(t, err.Error(), "workflows.b: checkpoint is only supported on sequential workflows")
+}
+
+func TestRebuildKeepsPreviousWorkflowsOnFailure(t *testing.T) {
+	registry := newRegistry()
+	assert.Nil(t, registry.Build(decode(t, workflowsToml).Workflows))
+
+	invalid := decode(t, `
+[workflows.main]
+[[workflows.main.steps]]
+command = "unknown"
+`)
+	assert.NotNil(t, registry.Build(invalid.Workflows))
+	assert.NotNil(t, registry.Get("child"))
+}
+
+func TestExplainWorkflow(t *testing.T) {
+	registry := newRegistry()
+	assert.Nil(t, registry.Build(decode(t, workflowsToml).Workflows))
+
+	plan, err := registry.Explain("main", cor.NewBaseContext())
+	assert.Nil(t, err)
+	assert.Empty(t, plan.Skipped())
+	assert.Contains(t, plan.String(), "└── main_1 [parallel]")
+	assert.Contains(t, plan.String(), "└── child [chain]")
+
+	_, err = registry.Explain("unknown", cor.NewBaseContext())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// validate_config checks a configuration directory before it's uploaded to the config bucket:
+//
+//	go run ./tools/validate_config -dir configs -runtime local
+//
+// The base and runtime files are decoded strictly, then the configuration and its
+// declarative workflows are validated. All problems are printed and the exit code is 1.
+// A valid configuration prints its version and the prompt hashes recorded with analyzed
+// media, which find the media analyzed with other prompts.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/workflow"
+)
+
+func main() {
+	dir := flag.String("dir", "configs", "The configuration directory.")
+	runtime := flag.String("runtime", "local", "The runtime environment of the override file, e.g. local for .env.local.toml.")
+	flag.Parse()
+
+	config, problems := validate(*dir, *runtime)
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d configuration problem(s) found\n", len(problems))
+		os.Exit(1)
+	}
+	fmt.Println("configuration is valid")
+	printProvenance(config)
+}
+
+func printProvenance(config *cloud.Config) {
+	fmt.Printf("version: %s\nhash: %s\n", config.Version(), config.Hash())
+	for _, contentType := range config.ContentType.Types {
+		for _, prompt := range []string{cloud.PromptContentType, cloud.PromptSummary, cloud.PromptSegment} {
+			fmt.Printf("prompt hash %s %s: %s\n", contentType, prompt, config.PromptHash(prompt, contentType, cloud.DefaultAgentModel))
+		}
+	}
+}
+
+func validate(dir string, runtime string) (*cloud.Config, []error) {
+	config := cloud.NewConfig()
+	decodeErr := cloud.LoadConfigFiles(dir, runtime, config)
+	if decodeErr != nil && !errors.Is(decodeErr, cloud.ErrUndecodedKey) {
+		return config, []error{decodeErr}
+	}
+
+	registry := workflow.NewRegistry()
+	workflow.RegisterDefaultCommands(registry, cloud.NewConfigSnapshot(config))
+
+	var problems []error
+	for _, err := range []error{decodeErr, config.Validate(), registry.Validate(config.Workflows)} {
+		problems = append(problems, unjoin(err)...)
+	}
+	return config, problems
+}
+
+func unjoin(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []error
+		for _, e := range joined.Unwrap() {
+			out = append(out, unjoin(e)...)
+		}
+		return out
+	}
+	return []error{err}
+}
@@ -71,6 +71,8 @@ func main() {
 		MediaRouter(apiV1)
 		// Register "/api/v1/uploads"
 		FileUpload(apiV1)
+		// Register "/api/v1/quota"
+		QuotaRouter(apiV1)
 	}
 
 	// serving the front-end asset
@@ -104,13 +106,17 @@ func main() {
 	oCtx, oCancel := context.WithTimeout(ctx, 60*time.Second)
 	defer oCancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server Shutdown:", err)
+	if err := srv.Shutdown(oCtx); err != nil {
+		log.Println("Server Shutdown:", err)
 	}
 
-	select {
-	case <-oCtx.Done():
-		log.Println("Timeout, failed to shutdown gracefully")
+	// Stop receiving messages and wait for the running executions, the clients are closed once
+	// the listeners are drained. The abandoned executions are logged by the listeners.
+	dCtx, dCancel := context.WithTimeout(ctx, GetConfig().GetDrainTimeout())
+	defer dCancel()
+	if abandoned := state.cloud.Drain(dCtx); len(abandoned) > 0 {
+		log.Println("Timeout, failed to drain the listeners gracefully")
 	}
+	state.cloud.Close()
 	log.Println("Server exiting")
 }
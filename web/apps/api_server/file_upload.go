@@ -25,8 +25,6 @@ import (
 )
 
 func FileUpload(r *gin.RouterGroup) {
-	config := GetConfig()
-
 	upload := r.Group("/uploads")
 	{
 		upload.POST("", func(c *gin.Context) {
@@ -36,7 +34,8 @@ func FileUpload(r *gin.RouterGroup) {
 				return
 			}
 			files := form.File["files"]
-			bucket := state.cloud.StorageClient.Bucket(config.Storage.HiResInputBucket)
+			config := GetConfig()
+			store := state.cloud.ObjectStore
 
 			for _, file := range files {
 				localPath := filepath.Join(os.TempDir(), file.Filename)
@@ -46,24 +45,19 @@ func FileUpload(r *gin.RouterGroup) {
 					c.Status(400)
 					return
 				}
-				content, err := os.ReadFile(localPath)
+				content, err := os.Open(localPath)
 				if err != nil {
 					log.Println(err)
 					c.Status(400)
 					return
 				}
-				wc := bucket.Object(file.Filename).NewWriter(c)
-				wc.ContentType = "video/mp4"
-				_, err = wc.Write(content)
+				_, err = store.Write(c, config.Storage.HiResInputBucket, file.Filename, "video/mp4", content)
+				_ = content.Close()
 				if err != nil {
 					c.Status(500)
 					log.Printf("failed to write file to bucket: %v\n", err)
 					return
 				}
-				err = wc.Close()
-				if err != nil {
-					log.Printf("failed to close bucket handle: %v\n", err)
-				}
 				err = os.Remove(localPath)
 				if err != nil {
 					log.Printf("failed to remove file from server: %v\n", err)
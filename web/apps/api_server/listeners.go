@@ -19,13 +19,55 @@ package main
 
 import (
 	"context"
+	"log"
 
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cor"
 	"github.com/GoogleCloudPlatform/media-search-solution/pkg/workflow"
 )
 
-func SetupListeners(config *cloud.Config, cloudClients *cloud.ServiceClients, templateService *cloud.TemplateService, ctx context.Context) {
-	mediaConfigUpdateWorkflow := workflow.NewMediaConfigUpdateWorkflow(config, templateService)
-	cloudClients.PubSubListeners["ConfigTopic"].SetCommand(mediaConfigUpdateWorkflow)
-	cloudClients.PubSubListeners["ConfigTopic"].Listen(ctx)
+func SetupListeners(snapshot *cloud.ConfigSnapshot, cloudClients *cloud.ServiceClients, ctx context.Context) {
+	config := snapshot.Get()
+	checkpointStore, err := cloud.NewCheckpointStore(config, cloudClients.ObjectStore)
+	if err != nil {
+		log.Fatalf("invalid checkpoint configuration: %v", err)
+	}
+	registry, err := workflow.NewRegistryFromConfig(snapshot, checkpointStore)
+	if err != nil {
+		log.Fatalf("invalid workflow definitions: %v", err)
+	}
+
+	// Subscriptions declaring a workflow are handled by the declarative workflow
+	for name, subscription := range config.TopicSubscriptions {
+		if subscription.Workflow == "" {
+			continue
+		}
+		if registry.Get(subscription.Workflow) == nil {
+			log.Fatalf("subscription %s references unknown workflow: %s", name, subscription.Workflow)
+		}
+		cloudClients.Listeners[name].SetCommand(registry.Workflow(subscription.Workflow))
+		cloudClients.Listeners[name].Listen(ctx)
+	}
+
+	if config.TopicSubscriptions["ConfigTopic"].Workflow == "" {
+		mediaConfigUpdateWorkflow := workflow.NewMediaConfigUpdateWorkflow(snapshot)
+		cloudClients.Listeners["ConfigTopic"].SetCommand(mediaConfigUpdateWorkflow)
+		cloudClients.Listeners["ConfigTopic"].Listen(ctx)
+	}
+
+	// The media worker runs the proxy generation and analysis jobs of Cloud Workflows in process
+	if config.MediaWorker.Enabled {
+		mediaWorkflows := map[string]cor.Command{
+			"HiResTopic":  workflow.NewMediaProxyWorkflow(snapshot, cloudClients),
+			"LowResTopic": workflow.NewMediaAnalysisWorkflow(snapshot, cloudClients),
+		}
+		for name, command := range mediaWorkflows {
+			listener, ok := cloudClients.Listeners[name]
+			if !ok || config.TopicSubscriptions[name].Workflow != "" {
+				continue
+			}
+			listener.SetCommand(command)
+			listener.Listen(ctx)
+		}
+	}
 }
@@ -0,0 +1,36 @@
+// Copyright 2025 Google, LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+
+	"github.com/GoogleCloudPlatform/media-search-solution/pkg/cloud"
+	"github.com/gin-gonic/gin"
+)
+
+// QuotaRouter serves the shared model quota to analysis jobs using the http quota coordinator.
+// The jobs authenticate with the quota.token shared token, the endpoint isn't served without one.
+func QuotaRouter(r *gin.RouterGroup) {
+	token := GetConfig().Quota.Token
+	if token == "" {
+		log.Println("quota.token is not set, the quota lease endpoint is disabled")
+		return
+	}
+	quota := r.Group("/quota")
+	{
+		quota.POST("/leases", gin.WrapH(cloud.NewLeaseHandler(state.leaseStore, token)))
+	}
+}
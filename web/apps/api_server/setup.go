@@ -27,10 +27,11 @@ import (
 )
 
 type StateManager struct {
-	config        *cloud.Config
+	config        *cloud.ConfigSnapshot
 	cloud         *cloud.ServiceClients
 	searchService *services.SearchService
 	mediaService  *services.MediaService
+	leaseStore    cloud.LeaseStore
 }
 
 var state = &StateManager{}
@@ -55,6 +56,8 @@ func SetupOS() (err error) {
 	return err
 }
 
+// GetConfig returns the current configuration, it must not be modified since it's
+// shared with the request handlers and the listeners.
 func GetConfig() *cloud.Config {
 	if state.config == nil {
 		err := SetupOS()
@@ -65,9 +68,12 @@ func GetConfig() *cloud.Config {
 		config := cloud.NewConfig()
 		// Load it from the TOML files
 		cloud.LoadConfig(&config)
-		state.config = config
+		if err = config.Validate(); err != nil {
+			log.Fatalf("invalid configuration:\n%v\n", err)
+		}
+		state.config = cloud.NewConfigSnapshot(config)
 	}
-	return state.config
+	return state.config.Get()
 }
 
 func InitState(ctx context.Context) {
@@ -80,6 +86,7 @@ func InitState(ctx context.Context) {
 	}
 
 	state.cloud = cloudClients
+	state.leaseStore = cloud.NewMemoryLeaseStore()
 
 	datasetName := config.BigQueryDataSource.DatasetName
 	mediaTableName := config.BigQueryDataSource.MediaTable
@@ -87,11 +94,11 @@ func InitState(ctx context.Context) {
 
 	state.searchService = &services.SearchService{
 		BigqueryClient: cloudClients.BiqQueryClient,
-		EmbeddingModel: cloudClients.EmbeddingModels["multi-lingual"],
+		EmbeddingModel: cloudClients.EmbeddingModels[cloud.DefaultEmbeddingModel],
 		DatasetName:    datasetName,
 		MediaTable:     mediaTableName,
 		EmbeddingTable: embeddingTableName,
-		ModelName:      config.EmbeddingModels["multi-lingual"].Model,
+		ModelName:      config.EmbeddingModels[cloud.DefaultEmbeddingModel].Model,
 	}
 
 	state.mediaService = &services.MediaService{
@@ -100,6 +107,13 @@ func InitState(ctx context.Context) {
 		MediaTable:     mediaTableName,
 	}
 
-	SetupListeners(config, cloudClients, cloud.NewTemplateService(config), ctx)
+	SetupListeners(state.config, cloudClients, ctx)
+
+	if config.Application.WatchConfig {
+		err = cloud.WatchConfigFiles(ctx, state.config, os.Getenv(cloud.EnvConfigFilePrefix), os.Getenv(cloud.EnvConfigRuntime))
+		if err != nil {
+			log.Fatalf("failed to watch configuration files: %v", err)
+		}
+	}
 
 }